@@ -0,0 +1,183 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+	"github.com/fluxcd/source-watcher/osmops/cfg"
+	osmops "github.com/fluxcd/source-watcher/osmops/engine"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
+)
+
+// ArtifactSource is a Flux source object that produces an artifact
+// OsmOps can reconcile, e.g. a GitRepository or a Bucket.
+type ArtifactSource interface {
+	client.Object
+	sourcev1.Source
+}
+
+// reconcileArtifact downloads and extracts the artifact of the given source
+// into a temp dir and then runs the OsmOps engine on the extracted files.
+// The kind argument is the source kind, e.g. sourcev1.GitRepositoryKind.
+func reconcileArtifact(ctx context.Context, kind string,
+	source ArtifactSource) (ctrl.Result, error) {
+	log := logr.FromContext(ctx)
+
+	if source.GetArtifact() == nil {
+		return ctrl.Result{}, nil
+	}
+	log.Info("New revision detected", "kind", kind,
+		"revision", source.GetArtifact().Revision)
+
+	ctx, span := tracing.Start(ctx, "reconcileArtifact",
+		tracing.SourceKindKey.String(kind),
+		tracing.SourceNamespaceKey.String(source.GetNamespace()),
+		tracing.SourceNameKey.String(source.GetName()),
+		tracing.RevisionKey.String(source.GetArtifact().Revision))
+	defer span.End()
+
+	// create tmp dir
+	tmpDir, err := ioutil.TempDir("", source.GetName())
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to create temp dir, error: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// download and extract artifact
+	summary, err := fetchArtifact(ctx, kind, source, tmpDir)
+	if err != nil {
+		log.Error(err, "unable to fetch artifact")
+		return ctrl.Result{}, err
+	}
+	log.Info(summary)
+
+	if !isOsmOpsRepo(tmpDir) {
+		log.Info("Skipping source, no OsmOps config file found",
+			"file", cfg.OpsConfigFileName)
+		return ctrl.Result{}, nil
+	}
+
+	if engine, err := osmops.New(ctx, tmpDir); err != nil {
+		// no need to log engine init error, engine.New already does that.
+		return ctrl.Result{}, err
+	} else {
+		report := engine.Reconcile()
+		log.Info("Reconcile done",
+			"created", report.Count(osmops.Op.CREATED),
+			"updated", report.Count(osmops.Op.UPDATED),
+			"unchanged", report.Count(osmops.Op.UNCHANGED),
+			"skipped", report.Count(osmops.Op.SKIPPED),
+			"failed", report.Count(osmops.Op.FAILED))
+		// TODO figure out if we should actually return some kind of (partial)
+		// error if some of the reconciliation ops fail.
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func isOsmOpsRepo(dir string) bool {
+	rootDir, err := file.ParseAbsPath(dir)
+	return err == nil && cfg.HasOpsConfig(rootDir)
+}
+
+// fetchArtifact downloads the source's artifact and extracts it into dir.
+// Before extracting anything, it makes sure the download matches the
+// checksum source-controller declared for the artifact, isn't bigger than
+// MaxArtifactSize and doesn't contain entries or symlinks that would land
+// outside of dir. (See: spoolArtifact, verifyTarball)
+func fetchArtifact(ctx context.Context, kind string, source ArtifactSource,
+	dir string) (summary string, err error) {
+	ctx, span := tracing.Start(ctx, "fetchArtifact",
+		tracing.SourceKindKey.String(kind),
+		tracing.SourceNameKey.String(source.GetName()))
+	defer func() { tracing.End(span, err) }()
+
+	if source.GetArtifact() == nil {
+		return "", fmt.Errorf("%s %s does not containt an artifact",
+			strings.ToLower(kind), source.GetName())
+	}
+	span.SetAttributes(
+		tracing.RevisionKey.String(source.GetArtifact().Revision))
+
+	url := source.GetArtifact().URL
+
+	// for local run:
+	// kubectl -n flux-system port-forward svc/source-controller 8080:80
+	// export SOURCE_HOST=localhost:8080
+	if hostname := os.Getenv("SOURCE_HOST"); hostname != "" {
+		url = fmt.Sprintf("http://%s/%s/%s/%s/latest.tar.gz", hostname,
+			strings.ToLower(kind), source.GetNamespace(), source.GetName())
+	}
+
+	// download the tarball
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request, error: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to download artifact from %s, error: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	// check response
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download artifact, status: %s", resp.Status)
+	}
+
+	// spool the tarball to a temp file, checking size and checksum as we go
+	tarball, err := ioutil.TempFile("", "artifact-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file, error: %w", err)
+	}
+	defer os.Remove(tarball.Name())
+	defer tarball.Close()
+
+	checksum := source.GetArtifact().Checksum
+	if err := spoolArtifact(resp.Body, tarball, checksum); err != nil {
+		return "", err
+	}
+
+	// extract, but only if all the entries look kosher
+	return extractArtifact(tarball.Name(), dir)
+}
+
+func extractArtifact(tarballPath string, dir string) (string, error) {
+	if err := verifyTarball(tarballPath); err != nil {
+		return "", err
+	}
+
+	summary, err := untarArtifact(tarballPath, dir)
+	if err != nil {
+		return "", fmt.Errorf("faild to untar artifact, error: %w", err)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Artifact size limits. They default to sensible values but you can change
+// them on startup, before setting up the source watchers---e.g. main sets
+// them from the "--max-artifact-size" and "--max-artifact-extracted-size"
+// flags.
+var (
+	// MaxArtifactSize is the maximum number of bytes we're willing to
+	// download from source-controller.
+	MaxArtifactSize int64 = 100 << 20 // 100MiB
+	// MaxArtifactExtractedSize is the maximum number of bytes the files
+	// in an artifact may add up to once extracted. It guards against
+	// decompression bombs.
+	MaxArtifactExtractedSize int64 = 500 << 20 // 500MiB
+)
+
+// newChecksumHasher figures out which hash algorithm produced the given
+// artifact checksum and returns a hasher for it along with the expected
+// hex digest. The checksum is either a bare hex digest---SHA1 (what
+// source-controller uses at the moment) or SHA256, told apart by length---
+// or a digest prefixed by the algorithm name, e.g. "sha256:ab12...".
+func newChecksumHasher(checksum string) (hash.Hash, string, error) {
+	algo, digest := "", strings.ToLower(strings.TrimSpace(checksum))
+	if kv := strings.SplitN(digest, ":", 2); len(kv) == 2 {
+		algo, digest = kv[0], kv[1]
+	}
+	if algo == "" {
+		switch len(digest) {
+		case sha1.Size * 2:
+			algo = "sha1"
+		case sha256.Size * 2:
+			algo = "sha256"
+		}
+	}
+	switch algo {
+	case "sha1":
+		return sha1.New(), digest, nil
+	case "sha256":
+		return sha256.New(), digest, nil
+	}
+	return nil, "", fmt.Errorf("unsupported artifact checksum: '%s'", checksum)
+}
+
+// spoolArtifact streams the given artifact data into sink while computing
+// its checksum. It fails if there's more data than MaxArtifactSize or the
+// computed checksum doesn't match the expected one.
+func spoolArtifact(data io.Reader, sink io.Writer, checksum string) error {
+	hasher, want, err := newChecksumHasher(checksum)
+	if err != nil {
+		return err
+	}
+
+	limited := io.LimitReader(data, MaxArtifactSize+1)
+	n, err := io.Copy(io.MultiWriter(sink, hasher), limited)
+	if err != nil {
+		return fmt.Errorf("failed to download artifact, error: %w", err)
+	}
+	if n > MaxArtifactSize {
+		return fmt.Errorf("artifact exceeds max size of %d bytes",
+			MaxArtifactSize)
+	}
+
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != want {
+		return fmt.Errorf("artifact checksum mismatch, want: %s; got: %s",
+			want, got)
+	}
+	return nil
+}
+
+// isSafeArchivePath tells if extracting an archive entry with the given
+// name would land the entry within the extraction directory.
+func isSafeArchivePath(name string) bool {
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) {
+		return false
+	}
+	clean := path.Clean(name)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// isSafeLinkTarget tells if a symlink with the given archive name pointing
+// to target would resolve within the extraction directory. The target must
+// be a relative path which only climbs up (..) at the start, so the link
+// can't get out by going through another link---e.g. if "a/l" pointed to
+// "..", then "a/l/../.." would resolve to the parent of the extraction
+// directory even though it looks like "." on paper.
+func isSafeLinkTarget(name, target string) bool {
+	if target == "" || strings.Contains(target, `\`) || path.IsAbs(target) {
+		return false
+	}
+	climbing := true
+	for _, segment := range strings.Split(target, "/") {
+		if segment == ".." && !climbing {
+			return false
+		}
+		if segment != ".." && segment != "." && segment != "" {
+			climbing = false
+		}
+	}
+	return isSafeArchivePath(path.Join(path.Dir(name), target))
+}
+
+// isUnderLink tells if any of the parent directories of the given archive
+// entry is one of the given symlinks.
+func isUnderLink(links map[string]bool, name string) bool {
+	for dir := path.Dir(path.Clean(name)); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if links[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTarball checks every entry in the gzipped tar file at tarballPath
+// before we extract anything. It rejects entries that would end up outside
+// of the extraction directory, symlinks that would point outside of it,
+// entries under a symlinked directory, entries that aren't regular files,
+// directories or symlinks---e.g. hard links or devices---and archives whose
+// files add up to more than MaxArtifactExtractedSize.
+func verifyTarball(tarballPath string) error {
+	fd, err := os.Open(tarballPath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	zr, err := gzip.NewReader(fd)
+	if err != nil {
+		return fmt.Errorf("requires gzip-compressed artifact: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	var total int64
+	links := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("tar error: %w", err)
+		}
+		if !isSafeArchivePath(hdr.Name) {
+			return fmt.Errorf("artifact contains invalid path: %q", hdr.Name)
+		}
+		if isUnderLink(links, hdr.Name) {
+			return fmt.Errorf("artifact entry %s is under a symlink", hdr.Name)
+		}
+		mode := hdr.FileInfo().Mode()
+		if mode&os.ModeSymlink != 0 {
+			if !isSafeLinkTarget(hdr.Name, hdr.Linkname) {
+				return fmt.Errorf("artifact symlink %s points outside of the artifact: %q",
+					hdr.Name, hdr.Linkname)
+			}
+			links[path.Clean(hdr.Name)] = true
+			continue
+		}
+		if hdr.Typeflag == tar.TypeLink || (!mode.IsRegular() && !mode.IsDir()) { // (*)
+			return fmt.Errorf("artifact entry %s has unsupported type %v",
+				hdr.Name, mode)
+		}
+		if total += hdr.Size; hdr.Size < 0 || total > MaxArtifactExtractedSize {
+			return fmt.Errorf("artifact content exceeds max size of %d bytes",
+				MaxArtifactExtractedSize)
+		}
+	}
+
+	// (*) tar reports hard links as regular files, but there's no content
+	// to extract.
+}
+
+// untarArtifact extracts the gzipped tar file at tarballPath into dir and
+// returns a summary of what it extracted. It only handles the entries
+// verifyTarball lets through, so call that first. (*)
+func untarArtifact(tarballPath string, dir string) (string, error) {
+	fd, err := os.Open(tarballPath)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+
+	zr, err := gzip.NewReader(fd)
+	if err != nil {
+		return "", fmt.Errorf("requires gzip-compressed artifact: %w", err)
+	}
+	defer zr.Close()
+
+	tr := tar.NewReader(zr)
+	files, dirs, links := 0, 0, 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("tar error: %w", err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		mode := hdr.FileInfo().Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, 0755)
+			dirs++
+		case mode.IsRegular():
+			err = writeArtifactFile(target, mode.Perm(), tr)
+			files++
+		case mode&os.ModeSymlink != 0:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = os.Symlink(filepath.FromSlash(hdr.Linkname), target)
+			}
+			links++
+		default:
+			err = fmt.Errorf("unsupported type %v", mode)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to extract %s: %w", hdr.Name, err)
+		}
+	}
+	return fmt.Sprintf("Extracted tarball into %s: %d files, %d dirs, %d symlinks",
+		dir, files, dirs, links), nil
+
+	// (*) we used to extract artifacts with fluxcd/pkg/untar, but that
+	// rejects symlinks, even those pointing to files within the artifact.
+}
+
+func writeArtifactFile(target string, perm os.FileMode, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fd, content)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
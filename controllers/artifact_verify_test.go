@@ -0,0 +1,303 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+}
+
+func makeTarball(t *testing.T, entries ...tarEntry) []byte {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Mode:     0644,
+			Size:     int64(len(e.content)),
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if e.typeflag == tar.TypeSymlink {
+			hdr.Linkname = e.content
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte(e.content))
+		}
+	}
+	tw.Close()
+	zw.Close()
+	return buf.Bytes()
+}
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNewChecksumHasher(t *testing.T) {
+	data := []byte("some data")
+	fixtures := []struct {
+		checksum string
+		want     string
+	}{
+		{sha1Hex(data), sha1Hex(data)},
+		{sha256Hex(data), sha256Hex(data)},
+		{"sha1:" + sha1Hex(data), sha1Hex(data)},
+		{"SHA256:" + strings.ToUpper(sha256Hex(data)), sha256Hex(data)},
+	}
+	for k, d := range fixtures {
+		hasher, digest, err := newChecksumHasher(d.checksum)
+		if err != nil {
+			t.Fatalf("[%d] want: hasher; got: %v", k, err)
+		}
+		hasher.Write(data)
+		if got := hex.EncodeToString(hasher.Sum(nil)); got != d.want ||
+			digest != d.want {
+			t.Errorf("[%d] want: %s; got: %s, %s", k, d.want, got, digest)
+		}
+	}
+}
+
+func TestNewChecksumHasherErrors(t *testing.T) {
+	for k, checksum := range []string{"", "abc", "md5:abc"} {
+		if _, _, err := newChecksumHasher(checksum); err == nil {
+			t.Errorf("[%d] want: error; got: nil", k)
+		}
+	}
+}
+
+func TestSpoolArtifactChecksumMismatch(t *testing.T) {
+	data := []byte("some data")
+	sink := &bytes.Buffer{}
+	err := spoolArtifact(bytes.NewReader(data), sink, sha1Hex([]byte("x")))
+	if err == nil || !strings.Contains(err.Error(), "mismatch") {
+		t.Errorf("want: checksum mismatch error; got: %v", err)
+	}
+}
+
+func setMaxSizes(t *testing.T, download, extracted int64) {
+	oldDownload, oldExtracted := MaxArtifactSize, MaxArtifactExtractedSize
+	MaxArtifactSize, MaxArtifactExtractedSize = download, extracted
+	t.Cleanup(func() {
+		MaxArtifactSize, MaxArtifactExtractedSize = oldDownload, oldExtracted
+	})
+}
+
+func TestSpoolArtifactCapsSize(t *testing.T) {
+	setMaxSizes(t, 16, 16)
+	data := bytes.Repeat([]byte{0}, int(MaxArtifactSize)+1)
+	err := spoolArtifact(bytes.NewReader(data), ioutil.Discard, sha1Hex(data))
+	if err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Errorf("want: max size error; got: %v", err)
+	}
+}
+
+func TestIsSafeArchivePath(t *testing.T) {
+	fixtures := []struct {
+		name string
+		want bool
+	}{
+		{"a", true}, {"a/b", true}, {"./a/b/", true}, {"a/../b", true},
+		{"", false}, {"/a", false}, {"..", false}, {"../a", false},
+		{"a/../../b", false}, {"a/..", true}, {`a\b`, false},
+	}
+	for k, d := range fixtures {
+		if got := isSafeArchivePath(d.name); got != d.want {
+			t.Errorf("[%d] %s: want: %v; got: %v", k, d.name, d.want, got)
+		}
+	}
+}
+
+func TestIsSafeLinkTarget(t *testing.T) {
+	fixtures := []struct {
+		name   string
+		target string
+		want   bool
+	}{
+		{"l", "f", true}, {"l", "./d/f", true}, {"a/l", "../f", true},
+		{"a/b/l", "../../f", true}, {"a/l", ".", true}, {"a/l", "..", true},
+		{"l", "", false}, {"l", "/etc/passwd", false}, {"l", "..", false},
+		{"a/l", "../../f", false}, {"a/l", `..\f`, false},
+		{"l", "a/d/../..", false}, {"a/b/l", "../c/../f", false},
+	}
+	for k, d := range fixtures {
+		if got := isSafeLinkTarget(d.name, d.target); got != d.want {
+			t.Errorf("[%d] %s -> %s: want: %v; got: %v",
+				k, d.name, d.target, d.want, got)
+		}
+	}
+}
+
+func writeTempTarball(t *testing.T, data []byte) string {
+	fd, err := ioutil.TempFile("", "artifact-*.tar.gz")
+	if err != nil {
+		t.Fatalf("temp file: %v", err)
+	}
+	defer fd.Close()
+	fd.Write(data)
+	return fd.Name()
+}
+
+func TestVerifyTarballRejectsBadEntries(t *testing.T) {
+	fixtures := [][]tarEntry{
+		{{"../evil", tar.TypeReg, "x"}},
+		{{"a/../../evil", tar.TypeReg, "x"}},
+		{{"/etc/evil", tar.TypeReg, "x"}},
+		{{"a", tar.TypeDir, ""}, {"a/link", tar.TypeSymlink, "/etc/passwd"}},
+		{{"link", tar.TypeSymlink, "../etc/passwd"}},
+		{{"link", tar.TypeSymlink, "a"}, {"link/evil", tar.TypeReg, "x"}},
+		{{"a/d", tar.TypeSymlink, ".."}, {"l", tar.TypeSymlink, "a/d/../.."}},
+		{{"a", tar.TypeDir, ""}, {"a/hard", tar.TypeLink, ""}},
+	}
+	for k, entries := range fixtures {
+		tarball := writeTempTarball(t, makeTarball(t, entries...))
+		defer os.Remove(tarball)
+		if err := verifyTarball(tarball); err == nil {
+			t.Errorf("[%d] want: error; got: nil", k)
+		}
+	}
+}
+
+func TestVerifyTarballCapsExtractedSize(t *testing.T) {
+	setMaxSizes(t, MaxArtifactSize, 4)
+	tarball := writeTempTarball(t, makeTarball(t,
+		tarEntry{"f.txt", tar.TypeReg, "hello"}))
+	defer os.Remove(tarball)
+	err := verifyTarball(tarball)
+	if err == nil || !strings.Contains(err.Error(), "max size") {
+		t.Errorf("want: max size error; got: %v", err)
+	}
+}
+
+func TestVerifyTarballRejectsNonGzip(t *testing.T) {
+	tarball := writeTempTarball(t, []byte("not gzipped"))
+	defer os.Remove(tarball)
+	if err := verifyTarball(tarball); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func fetchFrom(t *testing.T, data []byte, checksum string) (string, error) {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+	defer srv.Close()
+
+	repo := newRepo("ns", nil, nil, "r1")
+	repo.Status.Artifact.URL = srv.URL
+	repo.Status.Artifact.Checksum = checksum
+
+	dir, err := ioutil.TempDir("", "fetch-artifact-test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	_, err = fetchArtifact(context.TODO(), sourcev1.GitRepositoryKind,
+		repo, dir)
+	return dir, err
+}
+
+func TestFetchArtifact(t *testing.T) {
+	data := makeTarball(t, tarEntry{"a", tar.TypeDir, ""},
+		tarEntry{"a/f.txt", tar.TypeReg, "hello"})
+
+	dir, err := fetchFrom(t, data, sha1Hex(data))
+	if err != nil {
+		t.Fatalf("want: extracted artifact; got: %v", err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, "a", "f.txt"))
+	if err != nil || string(got) != "hello" {
+		t.Errorf("want: hello; got: %s, %v", got, err)
+	}
+}
+
+func TestFetchArtifactExtractsNothingOnChecksumMismatch(t *testing.T) {
+	data := makeTarball(t, tarEntry{"f.txt", tar.TypeReg, "hello"})
+
+	dir, err := fetchFrom(t, data, sha256Hex([]byte("other")))
+	if err == nil {
+		t.Fatalf("want: checksum error; got: nil")
+	}
+	if fs, _ := ioutil.ReadDir(dir); len(fs) != 0 {
+		t.Errorf("want: empty dir; got: %d entries", len(fs))
+	}
+}
+
+func TestFetchArtifactExtractsNothingOnTraversal(t *testing.T) {
+	data := makeTarball(t, tarEntry{"f.txt", tar.TypeReg, "hello"},
+		tarEntry{"../evil.txt", tar.TypeReg, "evil"})
+
+	dir, err := fetchFrom(t, data, sha1Hex(data))
+	if err == nil {
+		t.Fatalf("want: traversal error; got: nil")
+	}
+	if fs, _ := ioutil.ReadDir(dir); len(fs) != 0 {
+		t.Errorf("want: empty dir; got: %d entries", len(fs))
+	}
+}
+
+func TestFetchArtifactKeepsSymlinksWithinArtifact(t *testing.T) {
+	data := makeTarball(t, tarEntry{"a", tar.TypeDir, ""},
+		tarEntry{"a/f.txt", tar.TypeReg, "hello"},
+		tarEntry{"b/link.txt", tar.TypeSymlink, "../a/f.txt"},
+		tarEntry{"dir-link", tar.TypeSymlink, "a"})
+
+	dir, err := fetchFrom(t, data, sha1Hex(data))
+	if err != nil {
+		t.Fatalf("want: extracted artifact; got: %v", err)
+	}
+	for _, p := range []string{"b/link.txt", "dir-link/f.txt"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil || string(got) != "hello" {
+			t.Errorf("%s: want: hello; got: %s, %v", p, got, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+func newBucketWatcher(t *testing.T, buckets ...*sourcev1.Bucket) *BucketWatcher {
+	scheme := runtime.NewScheme()
+	if err := sourcev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("want: scheme; got: %v", err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, b := range buckets {
+		builder = builder.WithObjects(b)
+	}
+	return &BucketWatcher{Client: builder.Build(), Scheme: scheme}
+}
+
+func newBucket(artifact *sourcev1.Artifact) *sourcev1.Bucket {
+	bucket := &sourcev1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "ns"},
+	}
+	bucket.Status.Artifact = artifact
+	return bucket
+}
+
+func bucketRequest() ctrl.Request {
+	return ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: "ns", Name: "bucket"},
+	}
+}
+
+func TestBucketWatcherIgnoresMissingBucket(t *testing.T) {
+	watcher := newBucketWatcher(t)
+	got, err := watcher.Reconcile(context.TODO(), bucketRequest())
+	if err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+	if got != (ctrl.Result{}) {
+		t.Errorf("want: empty result; got: %+v", got)
+	}
+}
+
+func TestBucketWatcherSkipsBucketWithoutArtifact(t *testing.T) {
+	watcher := newBucketWatcher(t, newBucket(nil))
+	got, err := watcher.Reconcile(context.TODO(), bucketRequest())
+	if err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+	if got != (ctrl.Result{}) {
+		t.Errorf("want: empty result; got: %+v", got)
+	}
+}
+
+func TestBucketWatcherReconcilesArtifact(t *testing.T) {
+	t.Setenv("TMPDIR", "/no/such/dir") // (*)
+	artifact := &sourcev1.Artifact{URL: "http://x/a.tgz", Revision: "r1"}
+	watcher := newBucketWatcher(t, newBucket(artifact))
+
+	ctx := ctrl.LoggerInto(context.TODO(), ctrl.Log)
+	_, err := watcher.Reconcile(ctx, bucketRequest())
+	if err == nil || !strings.Contains(err.Error(), "temp dir") {
+		t.Errorf("want: temp dir error; got: %v", err)
+	}
+
+	// (*) Stops reconcileArtifact right after it picks up the artifact,
+	// before it tries fetching it.
+}
@@ -17,49 +17,55 @@ limitations under the License.
 package controllers
 
 import (
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
 )
 
-// GitRepositoryRevisionChangePredicate triggers an update event
-// when a GitRepository revision changes.
-type GitRepositoryRevisionChangePredicate struct {
+// ArtifactRevisionChangePredicate triggers an update event when the
+// revision of a Flux source artifact changes. It works with any Flux source
+// that has an artifact, so all the source watchers use it.
+// Only sources matching the Selector trigger events.
+type ArtifactRevisionChangePredicate struct {
 	predicate.Funcs
+	Selector SourceSelector
 }
 
-func (GitRepositoryRevisionChangePredicate) Create(e event.CreateEvent) bool {
-	src, ok := e.Object.(sourcev1.Source)
+// artifactOf returns the artifact of the given Flux source or nil if the
+// object isn't a source or has no artifact yet.
+func artifactOf(obj client.Object) *sourcev1.Artifact {
+	if src, ok := asArtifactSource(obj); ok {
+		return src.GetArtifact()
+	}
+	return nil
+}
 
-	if !ok || src.GetArtifact() == nil {
+func (p ArtifactRevisionChangePredicate) Create(e event.CreateEvent) bool {
+	if !p.Selector.Matches(e.Object) {
 		return false
 	}
 
-	return true
+	return artifactOf(e.Object) != nil
 }
 
-func (GitRepositoryRevisionChangePredicate) Update(e event.UpdateEvent) bool {
+func (p ArtifactRevisionChangePredicate) Update(e event.UpdateEvent) bool {
 	if e.ObjectOld == nil || e.ObjectNew == nil {
 		return false
 	}
-
-	oldSource, ok := e.ObjectOld.(sourcev1.Source)
-	if !ok {
+	if !p.Selector.Matches(e.ObjectNew) {
 		return false
 	}
 
-	newSource, ok := e.ObjectNew.(sourcev1.Source)
-	if !ok {
-		return false
-	}
+	oldArtifact, newArtifact := artifactOf(e.ObjectOld), artifactOf(e.ObjectNew)
 
-	if oldSource.GetArtifact() == nil && newSource.GetArtifact() != nil {
+	if oldArtifact == nil && newArtifact != nil {
 		return true
 	}
 
-	if oldSource.GetArtifact() != nil && newSource.GetArtifact() != nil &&
-		oldSource.GetArtifact().Revision != newSource.GetArtifact().Revision {
+	if oldArtifact != nil && newArtifact != nil &&
+		oldArtifact.Revision != newArtifact.Revision {
 		return true
 	}
 
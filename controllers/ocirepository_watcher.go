@@ -0,0 +1,132 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+// OCIRepositoryKind is the kind of Flux OCI artifact sources.
+const OCIRepositoryKind = "OCIRepository"
+
+// OCIRepositoryGVK identifies Flux OCIRepository objects.
+var OCIRepositoryGVK = schema.GroupVersionKind{
+	Group:   sourcev1.GroupVersion.Group,
+	Version: "v1beta2",
+	Kind:    OCIRepositoryKind,
+}
+
+// NOTE. OCI artifacts. The Flux source API we depend on (v0.15.0) has no
+// OCIRepository type, so we handle OCIRepository objects as unstructured
+// data and only read the few fields reconcileArtifact needs. Once we
+// upgrade the source API, we can swap ociRepository for the real thing.
+
+// ociRepository adapts an unstructured OCIRepository to the ArtifactSource
+// interface.
+type ociRepository struct {
+	*unstructured.Unstructured
+}
+
+func newOCIRepositoryObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(OCIRepositoryGVK)
+	return obj
+}
+
+// asArtifactSource tells if the object is a Flux source with an artifact,
+// typed or unstructured, and if so returns it as an ArtifactSource.
+func asArtifactSource(obj client.Object) (ArtifactSource, bool) {
+	switch o := obj.(type) {
+	case ArtifactSource:
+		return o, true
+	case *unstructured.Unstructured:
+		if o.GroupVersionKind().GroupKind() == OCIRepositoryGVK.GroupKind() {
+			return &ociRepository{o}, true
+		}
+	}
+	return nil, false
+}
+
+func (r *ociRepository) artifactField(name string) string {
+	value, _, _ := unstructured.NestedString(r.Object, "status", "artifact",
+		name)
+	return value
+}
+
+// GetArtifact returns the OCIRepository artifact, if there's one. Newer
+// source-controllers call the artifact checksum digest.
+func (r *ociRepository) GetArtifact() *sourcev1.Artifact {
+	url := r.artifactField("url")
+	if url == "" {
+		return nil
+	}
+	checksum := r.artifactField("checksum")
+	if checksum == "" {
+		checksum = r.artifactField("digest")
+	}
+	return &sourcev1.Artifact{
+		Path:     r.artifactField("path"),
+		URL:      url,
+		Revision: r.artifactField("revision"),
+		Checksum: checksum,
+	}
+}
+
+// GetInterval returns the OCIRepository interval or zero if it isn't set
+// or is malformed.
+func (r *ociRepository) GetInterval() metav1.Duration {
+	value, _, _ := unstructured.NestedString(r.Object, "spec", "interval")
+	interval, _ := time.ParseDuration(value)
+	return metav1.Duration{Duration: interval}
+}
+
+// OCIRepositoryWatcher watches OCIRepository objects for revision changes
+type OCIRepositoryWatcher struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Selector SourceSelector
+}
+
+// +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=ocirepositories,verbs=get;list;watch
+// +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=ocirepositories/status,verbs=get
+
+func (r *OCIRepositoryWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// get source object
+	repository := newOCIRepositoryObject()
+	if err := r.Get(ctx, req.NamespacedName, repository); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return reconcileArtifact(ctx, OCIRepositoryKind, &ociRepository{repository})
+}
+
+func (r *OCIRepositoryWatcher) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(newOCIRepositoryObject(), builder.WithPredicates(ArtifactRevisionChangePredicate{Selector: r.Selector})).
+		Complete(r)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func newOCIRepo(revision string) *unstructured.Unstructured {
+	obj := newOCIRepositoryObject()
+	obj.SetName("oci")
+	obj.SetNamespace("ns")
+	unstructured.SetNestedField(obj.Object, "5m", "spec", "interval")
+	if revision != "" {
+		unstructured.SetNestedStringMap(obj.Object, map[string]string{
+			"url":      "http://x/oci.tgz",
+			"revision": revision,
+			"digest":   "sha256:abc",
+		}, "status", "artifact")
+	}
+	return obj
+}
+
+func TestOCIRepositoryAdapter(t *testing.T) {
+	source, ok := asArtifactSource(newOCIRepo("v1@sha256:abc"))
+	if !ok {
+		t.Fatalf("want: artifact source; got: not a source")
+	}
+	artifact := source.GetArtifact()
+	if artifact == nil {
+		t.Fatalf("want: artifact; got: nil")
+	}
+	if artifact.URL != "http://x/oci.tgz" {
+		t.Errorf("want: http://x/oci.tgz; got: %s", artifact.URL)
+	}
+	if artifact.Revision != "v1@sha256:abc" {
+		t.Errorf("want: v1@sha256:abc; got: %s", artifact.Revision)
+	}
+	if artifact.Checksum != "sha256:abc" {
+		t.Errorf("want: sha256:abc; got: %s", artifact.Checksum)
+	}
+	if got := source.GetInterval().Duration; got != 5*time.Minute {
+		t.Errorf("want: 5m; got: %v", got)
+	}
+}
+
+func TestOCIRepositoryAdapterWithoutArtifact(t *testing.T) {
+	source, ok := asArtifactSource(newOCIRepo(""))
+	if !ok {
+		t.Fatalf("want: artifact source; got: not a source")
+	}
+	if got := source.GetArtifact(); got != nil {
+		t.Errorf("want: nil; got: %+v", got)
+	}
+}
+
+func TestAsArtifactSourceRejectsOtherUnstructured(t *testing.T) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	if _, ok := asArtifactSource(obj); ok {
+		t.Errorf("want: not a source; got: source")
+	}
+}
+
+func TestPredicateOnOCIRepositoryRevisions(t *testing.T) {
+	p := ArtifactRevisionChangePredicate{}
+	if !p.Create(event.CreateEvent{Object: newOCIRepo("v1")}) {
+		t.Errorf("want: create with artifact; got: filtered out")
+	}
+	if p.Create(event.CreateEvent{Object: newOCIRepo("")}) {
+		t.Errorf("want: create without artifact filtered out; got: passed")
+	}
+	if p.Update(event.UpdateEvent{
+		ObjectOld: newOCIRepo("v1"), ObjectNew: newOCIRepo("v1")}) {
+		t.Errorf("want: same revision filtered out; got: passed")
+	}
+	if !p.Update(event.UpdateEvent{
+		ObjectOld: newOCIRepo("v1"), ObjectNew: newOCIRepo("v2")}) {
+		t.Errorf("want: new revision; got: filtered out")
+	}
+}
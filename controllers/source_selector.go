@@ -0,0 +1,117 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SourceSelector tells which Flux sources OsmOps should act on.
+// The zero value selects any source.
+type SourceSelector struct {
+	// Only select sources whose labels match this selector. Nil means
+	// any labels.
+	Labels labels.Selector
+	// Only select sources having this annotation key. Empty means any
+	// annotations.
+	AnnotationKey string
+	// If AnnotationKey is set and this field isn't empty, only select
+	// sources whose AnnotationKey annotation has this value.
+	AnnotationValue string
+	// Only select sources in these namespaces. Empty means any namespace.
+	Namespaces []string
+}
+
+// NewSourceSelector builds a SourceSelector from its command line
+// representation. The label selector uses the usual Kubernetes syntax,
+// e.g. "osmops=enabled,env!=dev"; the annotation is either a key or a
+// key=value pair. Empty strings and lists select anything.
+func NewSourceSelector(labelSelector, annotation string,
+	namespaces []string) (SourceSelector, error) {
+	sel := SourceSelector{}
+
+	if s := strings.TrimSpace(labelSelector); s != "" {
+		parsed, err := labels.Parse(s)
+		if err != nil {
+			return sel, fmt.Errorf("invalid label selector: %w", err)
+		}
+		sel.Labels = parsed
+	}
+
+	if a := strings.TrimSpace(annotation); a != "" {
+		kv := strings.SplitN(a, "=", 2)
+		sel.AnnotationKey = strings.TrimSpace(kv[0])
+		if len(kv) == 2 {
+			sel.AnnotationValue = strings.TrimSpace(kv[1])
+		}
+		if sel.AnnotationKey == "" {
+			return sel, fmt.Errorf("invalid annotation: %s", annotation)
+		}
+	}
+
+	for _, ns := range namespaces {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			sel.Namespaces = append(sel.Namespaces, ns)
+		}
+	}
+
+	return sel, nil
+}
+
+// Matches tells if the given object satisfies all the selector's criteria.
+func (s SourceSelector) Matches(obj client.Object) bool {
+	if obj == nil {
+		return false
+	}
+	return s.matchNamespace(obj.GetNamespace()) &&
+		s.matchLabels(obj.GetLabels()) &&
+		s.matchAnnotation(obj.GetAnnotations())
+}
+
+func (s SourceSelector) matchNamespace(namespace string) bool {
+	if len(s.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range s.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SourceSelector) matchLabels(objLabels map[string]string) bool {
+	if s.Labels == nil {
+		return true
+	}
+	return s.Labels.Matches(labels.Set(objLabels))
+}
+
+func (s SourceSelector) matchAnnotation(annotations map[string]string) bool {
+	if s.AnnotationKey == "" {
+		return true
+	}
+	value, ok := annotations[s.AnnotationKey]
+	if !ok {
+		return false
+	}
+	return s.AnnotationValue == "" || s.AnnotationValue == value
+}
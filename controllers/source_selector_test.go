@@ -0,0 +1,131 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+func newRepo(namespace string, labels, annotations map[string]string,
+	revision string) *sourcev1.GitRepository {
+	repo := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "repo",
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+	if revision != "" {
+		repo.Status.Artifact = &sourcev1.Artifact{Revision: revision}
+	}
+	return repo
+}
+
+func TestZeroSourceSelectorMatchesAnything(t *testing.T) {
+	sel, err := NewSourceSelector("", " ", []string{" "})
+	if err != nil {
+		t.Fatalf("want: selector; got: %v", err)
+	}
+	if !sel.Matches(newRepo("ns", nil, nil, "")) {
+		t.Errorf("want: match; got: no match")
+	}
+	if sel.Matches(nil) {
+		t.Errorf("want: no match on nil; got: match")
+	}
+}
+
+func TestSourceSelectorMatches(t *testing.T) {
+	sel, err := NewSourceSelector("osmops=enabled", "osmops.io/target=osm1",
+		[]string{"ns1", "ns2"})
+	if err != nil {
+		t.Fatalf("want: selector; got: %v", err)
+	}
+
+	lbl := map[string]string{"osmops": "enabled"}
+	ann := map[string]string{"osmops.io/target": "osm1"}
+	fixtures := []struct {
+		repo *sourcev1.GitRepository
+		want bool
+	}{
+		{newRepo("ns1", lbl, ann, ""), true},
+		{newRepo("ns2", lbl, ann, ""), true},
+		{newRepo("ns3", lbl, ann, ""), false},
+		{newRepo("ns1", nil, ann, ""), false},
+		{newRepo("ns1", map[string]string{"osmops": "off"}, ann, ""), false},
+		{newRepo("ns1", lbl, nil, ""), false},
+		{newRepo("ns1", lbl, map[string]string{"osmops.io/target": "x"}, ""),
+			false},
+	}
+	for k, d := range fixtures {
+		if got := sel.Matches(d.repo); got != d.want {
+			t.Errorf("[%d] want: %v; got: %v", k, d.want, got)
+		}
+	}
+}
+
+func TestSourceSelectorAnnotationKeyOnly(t *testing.T) {
+	sel, _ := NewSourceSelector("", "osmops.io/enabled", nil)
+	ann := map[string]string{"osmops.io/enabled": "whatever"}
+	if !sel.Matches(newRepo("ns", nil, ann, "")) {
+		t.Errorf("want: match; got: no match")
+	}
+	if sel.Matches(newRepo("ns", nil, nil, "")) {
+		t.Errorf("want: no match; got: match")
+	}
+}
+
+func TestNewSourceSelectorErrors(t *testing.T) {
+	if _, err := NewSourceSelector("osmops in (", "", nil); err == nil {
+		t.Errorf("want: label selector error; got: nil")
+	}
+	if _, err := NewSourceSelector("", "=v", nil); err == nil {
+		t.Errorf("want: annotation error; got: nil")
+	}
+}
+
+func TestPredicateFiltersOnSelector(t *testing.T) {
+	sel, _ := NewSourceSelector("", "", []string{"ns1"})
+	p := ArtifactRevisionChangePredicate{Selector: sel}
+
+	if !p.Create(event.CreateEvent{Object: newRepo("ns1", nil, nil, "r1")}) {
+		t.Errorf("want: create event; got: filtered out")
+	}
+	if p.Create(event.CreateEvent{Object: newRepo("ns2", nil, nil, "r1")}) {
+		t.Errorf("want: filtered out; got: create event")
+	}
+
+	update := event.UpdateEvent{
+		ObjectOld: newRepo("ns1", nil, nil, "r1"),
+		ObjectNew: newRepo("ns1", nil, nil, "r2"),
+	}
+	if !p.Update(update) {
+		t.Errorf("want: update event; got: filtered out")
+	}
+	update = event.UpdateEvent{
+		ObjectOld: newRepo("ns2", nil, nil, "r1"),
+		ObjectNew: newRepo("ns2", nil, nil, "r2"),
+	}
+	if p.Update(update) {
+		t.Errorf("want: filtered out; got: update event")
+	}
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+// sourceWatchers maps each supported Flux source kind, in lowercase, to a
+// function to set up the corresponding watcher.
+var sourceWatchers = map[string]func(ctrl.Manager, SourceSelector) error{
+	strings.ToLower(sourcev1.GitRepositoryKind): func(mgr ctrl.Manager,
+		sel SourceSelector) error {
+		return (&GitRepositoryWatcher{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Selector: sel,
+		}).SetupWithManager(mgr)
+	},
+	strings.ToLower(sourcev1.BucketKind): func(mgr ctrl.Manager,
+		sel SourceSelector) error {
+		return (&BucketWatcher{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Selector: sel,
+		}).SetupWithManager(mgr)
+	},
+	strings.ToLower(OCIRepositoryKind): func(mgr ctrl.Manager,
+		sel SourceSelector) error {
+		return (&OCIRepositoryWatcher{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Selector: sel,
+		}).SetupWithManager(mgr)
+	},
+}
+
+// ParseSourceKinds turns the given Flux source kinds into the lowercase
+// keys SetupSourceWatchers expects, skipping blanks. It errors out on the
+// first kind we've got no watcher for.
+func ParseSourceKinds(kinds []string) ([]string, error) {
+	parsed := []string{}
+	for _, kind := range kinds {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		if kind == "" {
+			continue
+		}
+		if _, ok := sourceWatchers[kind]; !ok {
+			return nil, fmt.Errorf("unsupported source kind: %s", kind)
+		}
+		parsed = append(parsed, kind)
+	}
+	return parsed, nil
+}
+
+// SetupSourceWatchers sets up a watcher for each of the given Flux source
+// kinds, all sharing the same selector. The kinds go through
+// ParseSourceKinds first, so nothing gets set up if any of them is
+// unsupported.
+func SetupSourceWatchers(mgr ctrl.Manager, kinds []string,
+	sel SourceSelector) error {
+	parsed, err := ParseSourceKinds(kinds)
+	if err != nil {
+		return err
+	}
+	for _, kind := range parsed {
+		if err := sourceWatchers[kind](mgr, sel); err != nil {
+			return fmt.Errorf("%s watcher: %w", kind, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020, 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestParseSourceKindsNormalisesNames(t *testing.T) {
+	got, err := ParseSourceKinds(
+		[]string{" GitRepository ", "BUCKET", "", "  ", "ocirepository"})
+	if err != nil {
+		t.Fatalf("want: kinds; got: %v", err)
+	}
+	want := []string{"gitrepository", "bucket", "ocirepository"}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestParseSourceKindsErrOnUnknownKind(t *testing.T) {
+	got, err := ParseSourceKinds([]string{"bucket", " HelmRepository "})
+	if err == nil {
+		t.Fatalf("want: error; got: %v", got)
+	}
+	want := "unsupported source kind: helmrepository"
+	if err.Error() != want {
+		t.Errorf("want: %s; got: %v", want, err)
+	}
+}
+
+func stubSourceWatchers(t *testing.T, fail string) *[]string {
+	saved := sourceWatchers
+	t.Cleanup(func() { sourceWatchers = saved })
+
+	called := []string{}
+	sourceWatchers = map[string]func(ctrl.Manager, SourceSelector) error{}
+	for kind := range saved {
+		kind := kind
+		sourceWatchers[kind] = func(ctrl.Manager, SourceSelector) error {
+			called = append(called, kind)
+			if kind == fail {
+				return errors.New("setup failed")
+			}
+			return nil
+		}
+	}
+	return &called
+}
+
+func TestSetupSourceWatchersDispatchesOnKind(t *testing.T) {
+	called := stubSourceWatchers(t, "")
+	err := SetupSourceWatchers(nil, []string{"Bucket ", " OCIRepository"},
+		SourceSelector{})
+	if err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+	want := []string{"bucket", "ocirepository"}
+	if !reflect.DeepEqual(want, *called) {
+		t.Errorf("want: %v; got: %v", want, *called)
+	}
+}
+
+func TestSetupSourceWatchersErrOnUnknownKindBeforeSetup(t *testing.T) {
+	called := stubSourceWatchers(t, "")
+	err := SetupSourceWatchers(nil, []string{"gitrepository", "nope"},
+		SourceSelector{})
+	if err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+	if len(*called) != 0 {
+		t.Errorf("want: no watcher set up; got: %v", *called)
+	}
+}
+
+func TestSetupSourceWatchersErrOnSetupFailure(t *testing.T) {
+	called := stubSourceWatchers(t, "gitrepository")
+	err := SetupSourceWatchers(nil, []string{"gitrepository", "bucket"},
+		SourceSelector{})
+	if err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+	want := []string{"gitrepository"}
+	if !reflect.DeepEqual(want, *called) {
+		t.Errorf("want: %v; got: %v", want, *called)
+	}
+}
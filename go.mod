@@ -4,13 +4,18 @@ go 1.16
 
 require (
 	github.com/fluxcd/pkg/runtime v0.12.0
-	github.com/fluxcd/pkg/untar v0.0.5
 	github.com/fluxcd/source-controller/api v0.15.0
 	github.com/go-logr/logr v0.4.0
 	github.com/go-ozzo/ozzo-validation v3.6.0+incompatible
 	github.com/json-iterator/go v1.1.11
 	github.com/spf13/pflag v1.0.5
+	go.opentelemetry.io/otel v1.2.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.2.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.2.0
+	go.opentelemetry.io/otel/sdk v1.2.0
+	go.opentelemetry.io/otel/trace v1.2.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 	k8s.io/apimachinery v0.21.1
 	k8s.io/client-go v0.21.1
 	sigs.k8s.io/controller-runtime v0.9.0
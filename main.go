@@ -17,7 +17,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	flag "github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -29,6 +33,11 @@ import (
 	"github.com/fluxcd/pkg/runtime/logger"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
 	"github.com/fluxcd/source-watcher/controllers"
+	"github.com/fluxcd/source-watcher/osmops/cfg"
+	"github.com/fluxcd/source-watcher/osmops/export"
+	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,6 +57,19 @@ func main() {
 	var (
 		metricsAddr          string
 		enableLeaderElection bool
+		watchSources         []string
+		sourceLabelSelector  string
+		sourceAnnotation     string
+		sourceNamespaces     []string
+		nbiTokenDir          string
+		traceNbi             bool
+		nbiLookup            string
+		nbiLookupTTL         time.Duration
+		otelExporter         string
+		otelEndpoint         string
+		exportTo             string
+		exportConnFile       string
+		exportTargetDir      string
 		logOptions           logger.Options
 	)
 
@@ -55,11 +77,94 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringSliceVar(&watchSources, "watch-sources",
+		[]string{strings.ToLower(sourcev1.GitRepositoryKind)},
+		"Kinds of Flux sources to reconcile with OSM, any of: gitrepository, bucket, ocirepository.")
+	flag.StringVar(&sourceLabelSelector, "source-label-selector", "",
+		"Only reconcile sources whose labels match this selector, e.g. 'osmops=enabled'.")
+	flag.StringVar(&sourceAnnotation, "source-annotation", "",
+		"Only reconcile sources with this annotation, given either as key or key=value.")
+	flag.StringSliceVar(&sourceNamespaces, "source-namespaces", []string{},
+		"Only reconcile sources in these namespaces. Defaults to all namespaces.")
+	flag.StringVar(&nbiTokenDir, "nbi-token-dir", "",
+		"Directory where to keep OSM NBI access tokens across restarts. Defaults to keeping them in memory.")
+	flag.BoolVar(&traceNbi, "trace-nbi", false,
+		"Log every OSM NBI request and response, with credentials and tokens redacted.")
+	flag.StringVar(&nbiLookup, "nbi-lookup", "filtered",
+		"How to look up OSM entities by name, one of: filtered (query NBI each time), cached (fetch whole collections once per reconcile).")
+	flag.DurationVar(&nbiLookupTTL, "nbi-lookup-ttl", 0,
+		"How long a session keeps the OSM IDs it looked up before fetching them again, e.g. 5m. Defaults to keeping them for the whole session.")
+	flag.Int64Var(&controllers.MaxArtifactSize, "max-artifact-size",
+		controllers.MaxArtifactSize,
+		"Maximum size, in bytes, of the source artifacts to download.")
+	flag.Int64Var(&controllers.MaxArtifactExtractedSize, "max-artifact-extracted-size",
+		controllers.MaxArtifactExtractedSize,
+		"Maximum size, in bytes, the files in a source artifact may add up to once extracted.")
+	flag.StringVar(&otelExporter, "otel-exporter", "none",
+		"Where to send OpenTelemetry spans, one of: none, stdout, otlp.")
+	flag.StringVar(&otelEndpoint, "otel-endpoint", "",
+		"Host and port of the OTLP/HTTP collector. Defaults to the OTEL_EXPORTER_OTLP_* environment variables.")
+	flag.StringVar(&exportTo, "export-to", "",
+		"Export the packages and NS instances in OSM to this repo directory, then exit instead of starting the manager.")
+	flag.StringVar(&exportConnFile, "export-connection-file", "",
+		"OSM connection file to log into NBI with when exporting.")
+	flag.StringVar(&exportTargetDir, "export-target-dir", "",
+		"Directory, relative to the export repo root, where to write packages and NS instance files. Defaults to the repo root.")
 	logOptions.BindFlags(flag.CommandLine)
 	flag.Parse()
 
 	ctrl.SetLogger(logger.NewLogger(logOptions))
 
+	selector, err := controllers.NewSourceSelector(sourceLabelSelector,
+		sourceAnnotation, sourceNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid source selection flags")
+		os.Exit(1)
+	}
+
+	if controllers.MaxArtifactSize <= 0 || controllers.MaxArtifactExtractedSize <= 0 {
+		setupLog.Error(fmt.Errorf("got: %d, %d", controllers.MaxArtifactSize,
+			controllers.MaxArtifactExtractedSize), "artifact size limits must be positive")
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(),
+		otelExporter, otelEndpoint)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	if nbiTokenDir != "" {
+		nbic.DefaultSessionCache = nbic.NewSessionCache(
+			nbic.FileTokenStores(nbiTokenDir))
+	}
+	if err := nbic.Lookup.Validate(nbiLookup); err != nil {
+		setupLog.Error(err, "invalid NBI lookup mode")
+		os.Exit(1)
+	}
+	nbic.DefaultSessionCache.UseLookup(nbic.Lookup.IndexOf(nbiLookup))
+	if err := nbic.DefaultSessionCache.UseLookupTTL(nbiLookupTTL); err != nil {
+		setupLog.Error(err, "invalid NBI lookup TTL")
+		os.Exit(1)
+	}
+	if traceNbi {
+		nbic.DefaultSessionCache.EnableTracing(
+			nbic.NbiTraceOptions(ctrl.Log.WithName("nbi")))
+	}
+
+	if exportTo != "" {
+		err = runExport(exportTo, exportConnFile, exportTargetDir)
+		if serr := shutdownTracing(context.Background()); serr != nil {
+			setupLog.Error(serr, "problem flushing traces")
+		}
+		if err != nil {
+			setupLog.Error(err, "unable to export OSM state")
+			os.Exit(1)
+		}
+		return
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -73,18 +178,20 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.GitRepositoryWatcher{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "GitRepositoryWatcher")
+	err = controllers.SetupSourceWatchers(mgr, watchSources, selector)
+	if err != nil {
+		setupLog.Error(err, "unable to create controller")
 		os.Exit(1)
 	}
 
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	if serr := shutdownTracing(context.Background()); serr != nil {
+		setupLog.Error(serr, "problem flushing traces")
+	}
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -92,3 +199,39 @@ func main() {
 	// TODO. Add health and readiness endpoints? See:
 	// https://github.com/kubernetes-sigs/kubebuilder/blob/master/docs/book/src/multiversion-tutorial/testdata/project/main.go#L125
 }
+
+// runExport exports the state of OSM to the given repo directory, logging
+// the packages and NS instances it had to leave out.
+func runExport(repoDir, connectionFile, targetDir string) error {
+	rootDir, err := file.ParseAbsPath(repoDir)
+	if err != nil {
+		return err
+	}
+	connFile, err := file.ParseAbsPath(connectionFile)
+	if err != nil {
+		return fmt.Errorf("export connection file: %v", err)
+	}
+	conn, err := cfg.LoadOsmConnection(connFile)
+	if err != nil {
+		return err
+	}
+	osm, err := export.Connect(conn)
+	if err != nil {
+		return err
+	}
+
+	report, err := export.Export(context.Background(), osm, rootDir,
+		export.WithTargetDir(targetDir))
+	if report != nil {
+		for _, skipped := range report.Skipped {
+			setupLog.Info("skipped export", "kind", skipped.Kind,
+				"name", skipped.Name, "reason", skipped.Reason.Error())
+		}
+	}
+	if err == nil {
+		setupLog.Info("exported OSM state", "repo", rootDir.Value(),
+			"packages", len(report.Packages),
+			"nsInstances", len(report.NsInstanceFiles))
+	}
+	return err
+}
@@ -4,10 +4,11 @@
 package cfg
 
 import (
-	"io/fs"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
@@ -56,15 +57,16 @@ func NewKduNsActionRepoScanner(store *Store) *KduNsActionRepoScanner {
 func (k *KduNsActionRepoScanner) Visit(visitor KduNsActionProcessor) []error {
 	scanner := file.NewTreeScanner(k.targetDir)
 	return scanner.Visit(func(node file.TreeNode) error {
-		if !k.isGitOpsFile(node.FsMeta) {
+		if !k.isGitOpsFile(node) {
 			return nil
 		}
 		return k.visitFile(node.NodePath, visitor)
 	})
 }
 
-func (k *KduNsActionRepoScanner) isGitOpsFile(info fs.FileInfo) bool {
-	if !info.IsDir() {
+func (k *KduNsActionRepoScanner) isGitOpsFile(node file.TreeNode) bool {
+	info := node.FsMeta
+	if !info.IsDir() && !isPackageManifest(node.RelPath) {
 		for _, ext := range k.fileExt {
 			name := strings.ToLower(info.Name())
 			if strings.HasSuffix(name, ext.Value()) {
@@ -75,6 +77,17 @@ func (k *KduNsActionRepoScanner) isGitOpsFile(info fs.FileInfo) bool {
 	return false
 }
 
+// isPackageManifest tells if the file at relPath, relative to the target
+// directory, is the manifest at the root of a package source directory.
+// Manifests have got the same extension as GitOps files but they tell
+// pkgr how to build a package. Anywhere else, a file with the manifest
+// name is just a GitOps file, e.g. for an NS instance named "package".
+func isPackageManifest(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	return len(parts) == 3 && parts[0] == OsmPackagesDirName &&
+		parts[2] == pkgr.ManifestFileName
+}
+
 func (k *KduNsActionRepoScanner) visitFile(absPath file.AbsPath,
 	visitor KduNsActionProcessor) error {
 	var err error
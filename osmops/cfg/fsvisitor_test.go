@@ -2,11 +2,14 @@ package cfg
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
@@ -79,3 +82,52 @@ func TestVisitFileIOReadError(t *testing.T) {
 		t.Errorf("want: no ops files visited; got: %v", visitor.received)
 	}
 }
+
+func TestVisitSkipsPackageManifests(t *testing.T) {
+	targetDir, _ := file.ParseAbsPath(t.TempDir())
+	pkgDir := targetDir.Join(OsmPackagesDirName).Join("p_knf")
+	os.MkdirAll(pkgDir.Value(), 0755)
+	manifest := pkgDir.Join(pkgr.ManifestFileName)
+	os.WriteFile(manifest.Value(), []byte("templates: []\n"), 0644)
+
+	scanner := &KduNsActionRepoScanner{
+		targetDir: targetDir,
+		fileExt:   DefaultOpsFileExtensions(),
+		readFile:  ioutil.ReadFile,
+	}
+	visitor := &processor{}
+	if errors := scanner.Visit(visitor); len(errors) != 0 {
+		t.Errorf("want: no errors; got: %v", errors)
+	}
+	if len(visitor.received) != 0 {
+		t.Errorf("want: no ops files visited; got: %v", visitor.received)
+	}
+}
+
+func TestVisitFilesNamedLikeManifestsOutsidePackageRoots(t *testing.T) {
+	targetDir, _ := file.ParseAbsPath(t.TempDir())
+	nsInstance := []byte(
+		"kind: NsInstance\nname: package\nnsdName: d\nvnfName: f\n" +
+			"vimAccountName: v\nkdu:\n  name: k\n")
+	for _, dir := range []file.AbsPath{
+		targetDir,
+		targetDir.Join(OsmPackagesDirName).Join("p_knf").Join("sub"),
+	} {
+		os.MkdirAll(dir.Value(), 0755)
+		manifest := dir.Join(pkgr.ManifestFileName)
+		os.WriteFile(manifest.Value(), nsInstance, 0644)
+	}
+
+	scanner := &KduNsActionRepoScanner{
+		targetDir: targetDir,
+		fileExt:   DefaultOpsFileExtensions(),
+		readFile:  ioutil.ReadFile,
+	}
+	visitor := &processor{}
+	if errors := scanner.Visit(visitor); len(errors) != 0 {
+		t.Errorf("want: no errors; got: %v", errors)
+	}
+	if len(visitor.received) != 2 {
+		t.Errorf("want: both files visited; got: %v", visitor.received)
+	}
+}
@@ -3,10 +3,13 @@
 package cfg
 
 import (
+	"crypto/ed25519"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
@@ -14,10 +17,13 @@ import (
 // Store holds the OSM Ops program configuration read from the OSM Ops
 // config and credentials files.
 type Store struct {
-	rootDir   file.AbsPath
-	targetDir file.AbsPath
-	fileExt   []u.NonEmptyStr
-	osmCreds  *OsmConnection
+	rootDir    file.AbsPath
+	targetDir  file.AbsPath
+	fileExt    []u.NonEmptyStr
+	osmCreds   *OsmConnection
+	checksums  []u.EnumIx
+	signingKey ed25519.PrivateKey
+	versions   *PackageVersioning
 }
 
 // NewStore reads the program configuration and credentials files, validates
@@ -45,6 +51,11 @@ func NewStore(repoRootDir file.AbsPath) (*Store, error) {
 	if s.osmCreds, err = readCreds(s.rootDir, cfg); err != nil {
 		return nil, err
 	}
+	s.checksums = getChecksums(cfg)
+	if s.signingKey, err = readSigningKey(s.rootDir, cfg); err != nil {
+		return nil, err
+	}
+	s.versions = cfg.PackageVersions
 
 	return &s, nil
 }
@@ -60,6 +71,14 @@ const OpsConfigFileName = "osm_ops_config.yaml"
 // where t is the absolute path returned by RepoTargetDirectory.
 const OsmPackagesDirName = "osm-pkgs"
 
+// HasOpsConfig tells if there's an OpsConfig file in the given repo root
+// directory. A repo without one isn't meant to be processed by OSM Ops.
+func HasOpsConfig(repoRootDir file.AbsPath) bool {
+	configFile := repoRootDir.Join(OpsConfigFileName)
+	info, err := os.Stat(configFile.Value())
+	return err == nil && info.Mode().IsRegular()
+}
+
 func readConfig(rootDir file.AbsPath) (*OpsConfig, error) {
 	file := rootDir.Join(OpsConfigFileName)
 	if fileData, err := ioutil.ReadFile(file.Value()); err != nil {
@@ -78,24 +97,54 @@ func buildTargetDirPath(rootDir file.AbsPath, cfg *OpsConfig) (file.AbsPath, err
 }
 
 func buildCredsDirPath(rootDir file.AbsPath, cfg *OpsConfig) (file.AbsPath, error) {
-	if filepath.IsAbs(cfg.ConnectionFile) {
-		return file.ParseAbsPath(cfg.ConnectionFile)
+	return buildSecretPath(rootDir, cfg.ConnectionFile)
+}
+
+func buildSecretPath(rootDir file.AbsPath, secretFile string) (file.AbsPath, error) {
+	if filepath.IsAbs(secretFile) {
+		return file.ParseAbsPath(secretFile)
 	}
-	return rootDir.Join(cfg.ConnectionFile), nil
+	return rootDir.Join(secretFile), nil
 }
 
 func readCreds(rootDir file.AbsPath, cfg *OpsConfig) (*OsmConnection, error) {
-	var fileData []byte
 	if credsFile, err := buildCredsDirPath(rootDir, cfg); err != nil {
 		return nil, err
 	} else {
-		if fileData, err = ioutil.ReadFile(credsFile.Value()); err != nil {
-			return nil, err
-		}
-		return readOsmConnection(fileData)
+		return LoadOsmConnection(credsFile)
 	}
 }
 
+// LoadOsmConnection reads the OsmConnection in the given YAML file, e.g.
+// the ConnectionFile in the OpsConfig, returning an error if the data
+// isn't valid.
+func LoadOsmConnection(connectionFile file.AbsPath) (*OsmConnection, error) {
+	fileData, err := ioutil.ReadFile(connectionFile.Value())
+	if err != nil {
+		return nil, err
+	}
+	return readOsmConnection(fileData)
+}
+
+func readSigningKey(rootDir file.AbsPath, cfg *OpsConfig) (ed25519.PrivateKey, error) {
+	if strings.TrimSpace(cfg.SigningKeyFile) == "" {
+		return nil, nil
+	}
+	keyFile, err := buildSecretPath(rootDir, strings.TrimSpace(cfg.SigningKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	return pkgr.LoadSigningKey(keyFile)
+}
+
+func getChecksums(cfg *OpsConfig) []u.EnumIx {
+	algs := []u.EnumIx{}
+	for _, label := range cfg.Checksums {
+		algs = append(algs, pkgr.HashAlgorithm.IndexOf(strings.TrimSpace(label)))
+	}
+	return algs
+}
+
 // DefaultOpsFileExtensions returns the default file extensions used to filter
 // OSM GitOps files: ".osmops.yaml" and ".osmops.yml".
 func DefaultOpsFileExtensions() []u.NonEmptyStr {
@@ -161,6 +210,26 @@ func (s *Store) OpsFileExtensions() []u.NonEmptyStr {
 	return s.fileExt
 }
 
+// PackageChecksums returns the hash algorithms, on top of MD5, to compute
+// package checksum files with. The list is empty if the OpsConfig YAML
+// file contains no checksums field.
+func (s *Store) PackageChecksums() []u.EnumIx {
+	return s.checksums
+}
+
+// PackageSigningKey returns the key to sign packages with or nil if the
+// OpsConfig YAML file contains no signingKeyFile field.
+func (s *Store) PackageSigningKey() ed25519.PrivateKey {
+	return s.signingKey
+}
+
+// PackageVersioning returns the package versioning settings or nil if
+// the OpsConfig YAML file contains no packageVersions field, in which case
+// packages aren't versioned.
+func (s *Store) PackageVersioning() *PackageVersioning {
+	return s.versions
+}
+
 // OsmCredentials returns the OSM connection and credentials details to
 // connect to the OSM north-bound interface.
 func (s *Store) OsmConnection() *OsmConnection {
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
@@ -69,6 +70,21 @@ func TestNoConfigFile(t *testing.T) {
 	}
 }
 
+func TestHasOpsConfig(t *testing.T) {
+	fixtures := []struct {
+		dirIndex int
+		want     bool
+	}{
+		{0, false}, {1, true}, {2, false}, {3, true},
+	}
+	for k, d := range fixtures {
+		repoRootDir := findTestDataDir(d.dirIndex)
+		if got := HasOpsConfig(repoRootDir); got != d.want {
+			t.Errorf("[%d] want: %v; got: %v", k, d.want, got)
+		}
+	}
+}
+
 func TestNoTargetDirOnFS(t *testing.T) {
 	repoRootDir := findTestDataDir(3)
 	if s, err := NewStore(repoRootDir); err == nil {
@@ -137,3 +153,54 @@ func TestRepoPkgRootDirWithSubdirs(t *testing.T) {
 		t.Errorf("want: %v; got: %v", want, got)
 	}
 }
+
+func TestNoPackageChecksumsOrSigningKey(t *testing.T) {
+	repoRootDir := findTestDataDir(1)
+	store, _ := NewStore(repoRootDir)
+
+	if got := store.PackageChecksums(); len(got) != 0 {
+		t.Errorf("want: no checksums; got: %v", got)
+	}
+	if got := store.PackageSigningKey(); got != nil {
+		t.Errorf("want: no signing key; got: %v", got)
+	}
+}
+
+func TestPackageChecksumsAndSigningKey(t *testing.T) {
+	repoRootDir := findTestDataDir(7)
+	store, err := NewStore(repoRootDir)
+	if err != nil {
+		t.Fatalf("want: new store; got: %v", err)
+	}
+
+	want := []u.EnumIx{pkgr.HashAlgorithm.SHA256, pkgr.HashAlgorithm.SHA512}
+	if got := store.PackageChecksums(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	if store.PackageSigningKey() == nil {
+		t.Errorf("want: signing key; got: nil")
+	}
+}
+
+func TestNoSigningKeyFileOnFS(t *testing.T) {
+	repoRootDir := findTestDataDir(8)
+	if s, err := NewStore(repoRootDir); err == nil {
+		t.Errorf("want: no store if no signing key file exists; got: %v", s)
+	}
+}
+
+func TestPackageVersioning(t *testing.T) {
+	store, _ := NewStore(findTestDataDir(1))
+	if got := store.PackageVersioning(); got != nil {
+		t.Errorf("want: no versioning; got: %+v", got)
+	}
+
+	store, err := NewStore(findTestDataDir(7))
+	if err != nil {
+		t.Fatalf("want: new store; got: %v", err)
+	}
+	got := store.PackageVersioning()
+	if got == nil || got.Keep != 3 {
+		t.Errorf("want: keep 3 versions; got: %+v", got)
+	}
+}
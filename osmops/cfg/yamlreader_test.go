@@ -19,11 +19,14 @@ fileExtensions:
   - .x
   - .ya.ml
 connectionFile: /the/secret/stash.yaml
+packageVersions:
+  keep: 2
 `
 	want := &OpsConfig{
-		TargetDir:      "deploy/ment",
-		FileExtensions: []string{".x", ".ya.ml"},
-		ConnectionFile: "/the/secret/stash.yaml",
+		TargetDir:       "deploy/ment",
+		FileExtensions:  []string{".x", ".ya.ml"},
+		ConnectionFile:  "/the/secret/stash.yaml",
+		PackageVersions: &PackageVersioning{Keep: 2},
 	}
 
 	got, err := readOpsConfig([]byte(data))
@@ -130,6 +133,7 @@ kdu:
   params:
     p: 1
     q: 2
+nsdVersion: 1.0
 `
 	want := &KduNsAction{
 		Kind:           "NsInstance",
@@ -145,6 +149,7 @@ kdu:
 				"q": 2,
 			},
 		},
+		NsdVersion: "1.0",
 	}
 
 	got, err := readKduNsAction([]byte(data))
@@ -17,6 +17,7 @@ package cfg
 import (
 	v "github.com/go-ozzo/ozzo-validation"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
@@ -43,14 +44,61 @@ type OpsConfig struct {
 	// keep this file in the repo. In that case, ConnectionFile should be
 	// a path relative to the repo root directory.
 	ConnectionFile string `yaml:"connectionFile"`
+
+	// Checksums is a list of hash algorithms, on top of MD5, to compute
+	// package checksum files with, e.g. `["sha256"]`. (See `pkgr.HashAlgorithm`
+	// for the supported algorithms.) OSM Ops always computes MD5 checksums
+	// since that's what OSM needs.
+	Checksums []string `yaml:"checksums"`
+
+	// SigningKeyFile is a path to a PEM file containing the Ed25519 private
+	// key to sign packages with. Like the ConnectionFile, typically this is
+	// an absolute path to a file mounted on the pod running OSM Ops through
+	// a K8s secret, but it can also be a path relative to the repo root.
+	// OSM Ops only signs packages if this field is present.
+	SigningKeyFile string `yaml:"signingKeyFile"`
+
+	// PackageVersions, if present, makes OSM Ops on-board each version of
+	// a package under its own descriptor ID instead of overwriting the
+	// package in OSM. (See `PackageVersioning`)
+	PackageVersions *PackageVersioning `yaml:"packageVersions"`
+}
+
+// PackageVersioning configures versioned packages. The version of a
+// package is the "version" field of the VNFD or NSD named after the
+// package directory or, if there's no such field, a short hash of the
+// package content, so the version only changes when the package files do.
+// OSM Ops on-boards each version under a versioned ID, e.g.
+// "openldap_knf-1.0" (see `pkgr.VersionedId`) and never changes it
+// afterwards, so you have to bump the descriptor version, if any, to
+// change the package.
+// NSDs in the repo refer to the versions of the repo VNFDs at the same
+// revision.
+type PackageVersioning struct {
+	// Keep is how many versions of each package to keep in OSM. After
+	// on-boarding a new version, OSM Ops deletes the oldest versions in
+	// excess, except for those OSM won't delete since an NS instance or
+	// an NSD still uses them. Defaults to 0 which means keep all versions.
+	Keep int `yaml:"keep"`
+}
+
+// Validate PackageVersioning data read from a YAML file.
+// An instance is valid if Keep isn't negative.
+func (d PackageVersioning) Validate() error {
+	return v.ValidateStruct(&d,
+		v.Field(&d.Keep, v.Min(0)),
+	)
 }
 
 // Validate OpsConfig data read from a YAML file.
 // An instance is valid if:
 // * TargetDir is not present or if present isn't empty and is a valid path.
 // * ConnectionFile isn't empty and is a valid path.
+// * Checksums only contains supported hash algorithms.
+// * SigningKeyFile is not present or if present is a valid path.
+// * PackageVersions is not present or if present is valid.
 func (d OpsConfig) Validate() error {
-	validTargetDir := func(value interface{}) error { // (*)
+	validOptionalPath := func(value interface{}) error { // (*)
 		s, _ := value.(string)
 		if len(s) == 0 {
 			return nil
@@ -58,13 +106,16 @@ func (d OpsConfig) Validate() error {
 		return file.IsStringPath(value)
 	}
 	return v.ValidateStruct(&d,
-		v.Field(&d.TargetDir, v.By(validTargetDir)),
+		v.Field(&d.TargetDir, v.By(validOptionalPath)),
 		v.Field(&d.ConnectionFile, v.By(file.IsStringPath)),
+		v.Field(&d.Checksums, v.Each(v.By(pkgr.HashAlgorithm.Validate))),
+		v.Field(&d.SigningKeyFile, v.By(validOptionalPath)),
+		v.Field(&d.PackageVersions),
 	)
 
 	// (*) the latest ozzo-validation (GH/master) comes w/ conditional
 	// validation rules, so when they release it, we could replace our
-	// custom validTargetDir w/ e.g.
+	// custom validOptionalPath w/ e.g.
 	//     v.When(d.TargetDir != "", v.By(u.IsStringPath)).Else(v.Nil)
 }
 
@@ -124,6 +175,11 @@ type KduNsAction struct {
 	VnfName        string `yaml:"vnfName"`
 	VimAccountName string `yaml:"vimAccountName"`
 	Kdu            Kdu    `yaml:"kdu"`
+	// NsdVersion pins the version of the NSD to create the NS instance
+	// with. Only meaningful if the OpsConfig has PackageVersions. If not
+	// present, OSM Ops uses the version of the NSD in the repo or, if the
+	// NSD isn't in the repo, the unversioned NSD.
+	NsdVersion string `yaml:"nsdVersion"`
 }
 
 // Validate KduNsAction data read from a YAML file.
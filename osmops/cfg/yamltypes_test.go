@@ -9,6 +9,9 @@ var opsConfigValidationFailFixtures = []OpsConfig{
 	{TargetDir: " ", ConnectionFile: "\n"},
 	{TargetDir: "valid", ConnectionFile: "\n"},
 	{TargetDir: "\t", ConnectionFile: "./val/id"},
+	{ConnectionFile: "./", Checksums: []string{"sha256", "crc32"}},
+	{ConnectionFile: "./", SigningKeyFile: " "},
+	{ConnectionFile: "./", PackageVersions: &PackageVersioning{Keep: -1}},
 }
 
 func TestOpsConfigValidationFail(t *testing.T) {
@@ -26,6 +29,10 @@ var opsConfigValidationOkFixtures = []OpsConfig{
 	{TargetDir: " /a/", ConnectionFile: "/a/b "},
 	{TargetDir: "valid", ConnectionFile: "./val/id"},
 	{TargetDir: "\tval/id\n", ConnectionFile: "\n/val/id/\t"},
+	{ConnectionFile: "./", Checksums: []string{"sha256", "SHA512", "md5"}},
+	{ConnectionFile: "./", SigningKeyFile: "/secrets/signing.key"},
+	{ConnectionFile: "./", PackageVersions: &PackageVersioning{}},
+	{ConnectionFile: "./", PackageVersions: &PackageVersioning{Keep: 3}},
 }
 
 func TestOpsConfigValidationOk(t *testing.T) {
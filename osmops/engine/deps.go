@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// pkgDeps tracks the descriptors defined by the packages that Reconcile
+// failed to create or update, so it can tell which NS instances depend
+// on them.
+type pkgDeps struct {
+	unknown     bool
+	failedNsds  map[string]bool
+	failedVnfds map[string]bool
+	nsdVnfds    map[string][]string
+}
+
+func newPkgDeps() *pkgDeps {
+	return &pkgDeps{
+		failedNsds:  map[string]bool{},
+		failedVnfds: map[string]bool{},
+		nsdVnfds:    map[string][]string{},
+	}
+}
+
+// addUnknownFailure records a failure we can't tie to any descriptor,
+// e.g. the package root directory can't be read. After that, every NS
+// instance is considered affected.
+func (d *pkgDeps) addUnknownFailure() {
+	d.unknown = true
+}
+
+// addPackage records the descriptors found in the given package source
+// directory. If the package failed, its descriptors get marked as failed
+// too. If we can't figure out which descriptors a failed package defines,
+// then we record an unknown failure since any NS instance could depend on
+// that package. The opts should be the same used to pack the package, so
+// we don't look at ignored files.
+func (d *pkgDeps) addPackage(source file.AbsPath, failed bool,
+	opts ...pkgr.PackOption) {
+	descs, err := pkgr.ReadDescriptors(source, opts...)
+	if err != nil || descs.IsEmpty() {
+		if failed {
+			d.addUnknownFailure()
+		}
+		return
+	}
+
+	for nsd, vnfds := range descs.Nsds {
+		d.nsdVnfds[nsd] = append(d.nsdVnfds[nsd], vnfds...)
+		if failed {
+			d.failedNsds[nsd] = true
+		}
+	}
+	if failed {
+		for _, vnfd := range descs.Vnfds {
+			d.failedVnfds[vnfd] = true
+		}
+	}
+}
+
+// affects tells if an NS instance based on the given NSD could be broken
+// by a failed package. This is the case if the NSD or any of the VNFDs
+// it references comes from a failed package, or if there's a failed
+// package we don't know anything about.
+//
+// Notice we only know which VNFDs an NSD references if the NSD is in one
+// of the repo packages. If the NSD was on-boarded in OSM some other way,
+// we assume it doesn't depend on any repo package.
+func (d *pkgDeps) affects(nsdName string) bool {
+	if d.unknown || d.failedNsds[nsdName] {
+		return true
+	}
+	for _, vnfd := range d.nsdVnfds[nsdName] {
+		if d.failedVnfds[vnfd] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestPkgDepsAffects(t *testing.T) {
+	deps := newPkgDeps()
+	deps.failedNsds["n1"] = true
+	deps.failedVnfds["v1"] = true
+	deps.nsdVnfds["n2"] = []string{"v2", "v1"}
+	deps.nsdVnfds["n3"] = []string{"v3"}
+
+	fixtures := []struct {
+		nsd  string
+		want bool
+	}{
+		{"n1", true}, {"n2", true}, {"n3", false}, {"not-in-repo", false},
+	}
+	for k, d := range fixtures {
+		if got := deps.affects(d.nsd); got != d.want {
+			t.Errorf("[%d] want: %v; got: %v", k, d.want, got)
+		}
+	}
+
+	deps.addUnknownFailure()
+	for k, d := range fixtures {
+		if !deps.affects(d.nsd) {
+			t.Errorf("[%d] want: affected after unknown failure; got: false", k)
+		}
+	}
+}
+
+func TestPkgDepsNoFailures(t *testing.T) {
+	deps := newPkgDeps()
+	if deps.affects("n") {
+		t.Errorf("want: false; got: true")
+	}
+}
+
+func TestPkgDepsAddFailedPackageWithUnreadableSource(t *testing.T) {
+	deps := newPkgDeps()
+	deps.addPackage(findTestDataDir(4).Join("not-there"), false)
+	if deps.affects("n") {
+		t.Errorf("want: ignore unreadable ok package; got: affected")
+	}
+	deps.addPackage(findTestDataDir(4).Join("not-there"), true)
+	if !deps.affects("n") {
+		t.Errorf("want: unknown failure; got: not affected")
+	}
+}
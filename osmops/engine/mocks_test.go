@@ -6,8 +6,10 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 	"github.com/go-logr/logr"
 )
@@ -117,6 +119,9 @@ func (c *logCollector) sortErrorFileNames() []string {
 type mockCreateOrUpdate struct {
 	dataMap           map[string]*nbic.NsInstanceContent
 	processedPkgNames []string
+	prunedPkgNames    []string
+	keptPkgIds        []string
+	ctxs              []context.Context
 }
 
 func newMockNbicWorkflow() *mockCreateOrUpdate {
@@ -126,21 +131,45 @@ func newMockNbicWorkflow() *mockCreateOrUpdate {
 	}
 }
 
-func (m *mockCreateOrUpdate) CreateOrUpdateNsInstance(data *nbic.NsInstanceContent) error {
+func (m *mockCreateOrUpdate) CreateOrUpdateNsInstance(ctx context.Context,
+	data *nbic.NsInstanceContent) (*nbic.Outcome, error) {
+	m.ctxs = append(m.ctxs, ctx)
 	m.dataMap[data.KduName] = data
 	if data.KduName == "k2" {
-		return errors.New("k2")
+		return nil, errors.New("k2")
 	}
-	return nil
+	return &nbic.Outcome{Created: true, Id: data.KduName}, nil
 }
 
-func (m *mockCreateOrUpdate) CreateOrUpdatePackage(source file.AbsPath) error {
+func (m *mockCreateOrUpdate) CreateOrUpdatePackage(ctx context.Context,
+	source file.AbsPath, opts ...pkgr.PackOption) (*nbic.Outcome, error) {
+	m.ctxs = append(m.ctxs, ctx)
 	name := path.Base(source.Value())
-	if name == "p1" {
-		return errors.New("p1")
+	if strings.HasPrefix(name, "p1") {
+		return nil, errors.New("p1")
 	}
 	m.processedPkgNames = append(m.processedPkgNames, name)
-	return nil
+	return &nbic.Outcome{Created: false, Id: name}, nil
+}
+
+func (m *mockCreateOrUpdate) CreatePackageVersion(ctx context.Context,
+	source file.AbsPath, opts ...pkgr.PackOption) (*nbic.Outcome, error) {
+	m.ctxs = append(m.ctxs, ctx)
+	name := path.Base(source.Value())
+	if strings.HasPrefix(name, "p1") {
+		return nil, errors.New("p1")
+	}
+	m.processedPkgNames = append(m.processedPkgNames, name)
+	return &nbic.Outcome{Unchanged: strings.HasPrefix(name, "old"), Id: name},
+		nil
+}
+
+func (m *mockCreateOrUpdate) PrunePackageVersions(ctx context.Context,
+	name string, keep int, keepIds ...string) ([]string, error) {
+	m.ctxs = append(m.ctxs, ctx)
+	m.prunedPkgNames = append(m.prunedPkgNames, name)
+	m.keptPkgIds = append(m.keptPkgIds, keepIds...)
+	return nil, nil
 }
 
 // mockCreateOrUpdate utils
@@ -2,19 +2,29 @@ package engine
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
 
 	"github.com/fluxcd/source-watcher/osmops/cfg"
 	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
 )
 
 type Engine struct {
-	ctx       context.Context
-	opsConfig *cfg.Store
-	nbic      nbic.Workflow
+	ctx          context.Context
+	opsConfig    *cfg.Store
+	nbic         nbic.Workflow
+	report       *Report
+	deps         *pkgDeps
+	versionedIds map[string]string
 }
 
 func newNbic(opsConfig *cfg.OsmConnection) (nbic.Workflow, error) {
@@ -33,7 +43,7 @@ func newNbic(opsConfig *cfg.OsmConnection) (nbic.Workflow, error) {
 		Project:  opsConfig.Project,
 	}
 
-	return nbic.New(conn, usrCreds)
+	return nbic.DefaultSessionCache.Get(conn, usrCreds)
 }
 
 func newProcessor(ctx context.Context, repoRootDir string) (*Engine, error) {
@@ -69,6 +79,11 @@ func (p *Engine) repoScanner() *cfg.KduNsActionRepoScanner {
 
 const (
 	processingMsg    = "processing"
+	skippingMsg      = "skipping b/c of failed packages"
+	lintFailedMsg    = "package failed linting"
+	pruneFailedMsg   = "can't prune old package versions"
+	prunedMsg        = "pruned old package versions"
+	prunedLogKey     = "deleted"
 	packageLogKey    = "osm package"
 	fileLogKey       = "file"
 	engineInitErrMsg = "can't initialize reconcile engine"
@@ -76,41 +91,306 @@ const (
 	errorLogKey      = "error"
 )
 
+// operationTimeout is the maximum amount of time we give OSM to create or
+// update a package or NS instance. An operation usually involves several
+// NBI calls, each with its own timeout (see: nbic.REQUEST_TIMEOUT_SECONDS),
+// this is the deadline for the whole lot.
+const operationTimeout = 15 * time.Minute
+
+// operationCtx derives the context for an NBI operation from the reconcile
+// context, so the operation stops if the reconcile gets cancelled or takes
+// longer than operationTimeout.
+func (p *Engine) operationCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(p.ctx, operationTimeout)
+}
+
+func (p *Engine) pkgsRootDir() file.AbsPath {
+	return p.opsConfig.RepoTargetDirectory().Join(cfg.OsmPackagesDirName)
+}
+
+// packOptions returns the options to build the repo packages with. On top
+// of each package's own ignore file, Pack also looks at a global one in
+// the target directory. (See: pkgr.IgnoreFileName) Pack also adds any
+// extra checksum files and the signature the OpsConfig asks for.
+func (p *Engine) packOptions() []pkgr.PackOption {
+	globalIgnoreFile := p.opsConfig.RepoTargetDirectory().Join(
+		pkgr.IgnoreFileName)
+	opts := []pkgr.PackOption{
+		pkgr.WithIgnoreFile(globalIgnoreFile),
+		pkgr.WithChecksums(p.opsConfig.PackageChecksums()...),
+	}
+	if key := p.opsConfig.PackageSigningKey(); key != nil {
+		opts = append(opts, pkgr.WithSigningKey(key))
+	}
+	if len(p.versionedIds) > 0 {
+		opts = append(opts, pkgr.WithDescriptorIds(p.versionedIds))
+	}
+	return opts
+}
+
+func (p *Engine) isVersioning() bool {
+	return p.opsConfig.PackageVersioning() != nil
+}
+
+// contentVersionLength is how many hex digits of a package's content
+// digest we keep when we turn it into a package version.
+const contentVersionLength = 7
+
+// versionPackages figures out the versioned ID of each package when the
+// OpsConfig asks for package versioning. A package's version is the one
+// in the descriptor named after the package or, if there's none, the one
+// derived from the package content. (See: contentVersion) Each package
+// we can't version gets reported as failed and marked as such in the
+// failed map.
+func (p *Engine) versionPackages(pkgs []file.AbsPath,
+	failed map[string]bool) []error {
+	es := []error{}
+	p.versionedIds = map[string]string{}
+	if !p.isVersioning() {
+		return es
+	}
+	descs := map[string]*pkgr.Descriptors{}
+	for _, pkgPath := range pkgs {
+		ds, err := pkgr.ReadDescriptors(pkgPath, p.packOptions()...) // (1)
+		if err != nil {
+			p.report.addFailed(ItemKind.PACKAGE, pkgPath, err)
+			failed[pkgPath.Value()] = true
+			es = append(es, err)
+			continue
+		}
+		descs[pkgPath.Value()] = ds
+	}
+
+	ids := map[string]string{}
+	for _, withNsds := range []bool{false, true} { // (2)
+		for _, pkgPath := range pkgs {
+			ds, ok := descs[pkgPath.Value()]
+			if !ok || (len(ds.Nsds) > 0) != withNsds {
+				continue
+			}
+			name := filepath.Base(pkgPath.Value())
+			ids[name] = pkgr.VersionedId(name, packageVersion(name, ds, ids))
+		}
+	}
+	p.versionedIds = ids
+	return es
+
+	// NOTE.
+	// 1. versionedIds is empty until we're done, so packOptions doesn't
+	// rename any descriptor yet. Otherwise ReadDescriptors would stage a
+	// renamed copy of each package for nothing.
+	// 2. NS packages go last since their content version depends on the
+	// versioned IDs of the VNFDs they reference.
+}
+
+func packageVersion(name string, descs *pkgr.Descriptors,
+	ids map[string]string) string {
+	if version, ok := descs.Versions[name]; ok {
+		return version
+	}
+	return contentVersion(descs, ids)
+}
+
+// contentVersion derives a version from the package content, so a package
+// without a descriptor version only gets a new version when its files
+// change. Pack points the NSDs in the package to the versioned IDs of the
+// repo VNFDs they reference, so those IDs go into the version too: a new
+// VNFD version means a new version of the NS packages that use it.
+func contentVersion(descs *pkgr.Descriptors, ids map[string]string) string {
+	hash := sha256.New()
+	hash.Write([]byte(descs.Digest))
+	nsdIds := []string{}
+	for nsdId := range descs.Nsds {
+		nsdIds = append(nsdIds, nsdId)
+	}
+	sort.Strings(nsdIds)
+	for _, nsdId := range nsdIds {
+		for _, vnfdId := range descs.Nsds[nsdId] {
+			if versionedId, ok := ids[vnfdId]; ok {
+				fmt.Fprintf(hash, "\n%s=%s", vnfdId, versionedId)
+			}
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:contentVersionLength]
+}
+
 func (p *Engine) processPackages() []error {
 	es := []error{}
 	pkgs, err := p.opsConfig.RepoPkgDirectories()
 	if err != nil {
 		es = append(es, err)
+		p.report.addFailed(ItemKind.PACKAGE, p.pkgsRootDir(), err)
+		p.deps.addUnknownFailure()
 		return es
 	}
+
+	failed := map[string]bool{}
+	es = append(es, p.versionPackages(pkgs, failed)...)
+	es = append(es, p.lintPackages(pkgs, failed)...)
 	for _, pkgPath := range pkgs {
+		if failed[pkgPath.Value()] {
+			continue
+		}
 		p.log().Info(processingMsg, packageLogKey, pkgPath.Value())
 
-		err = p.nbic.CreateOrUpdatePackage(pkgPath)
+		started := time.Now()
+		ctx, cancel := p.operationCtx()
+		outcome, err := p.uploadPackage(ctx, pkgPath)
+		cancel()
+		p.report.addProcessed(ItemKind.PACKAGE, pkgPath, started, outcome, err)
 		if err != nil {
 			es = append(es, err)
+			failed[pkgPath.Value()] = true
+		}
+	}
+
+	if len(es) > 0 { // (*)
+		for _, pkgPath := range pkgs {
+			p.deps.addPackage(pkgPath, failed[pkgPath.Value()],
+				p.packOptions()...)
 		}
 	}
 	return es
+
+	// (*) only need to figure out dependencies if something went wrong.
+}
+
+// uploadPackage creates or updates the package in OSM or, with package
+// versioning on, on-boards the package version unless OSM has it already.
+func (p *Engine) uploadPackage(ctx context.Context, pkgPath file.AbsPath) (
+	*nbic.Outcome, error) {
+	opName := "CreateOrUpdatePackage"
+	upload := p.nbic.CreateOrUpdatePackage
+	if p.isVersioning() {
+		opName = "CreatePackageVersion"
+		upload = p.nbic.CreatePackageVersion
+	}
+	ctx, span := tracing.Start(ctx, opName,
+		tracing.PackageNameKey.String(filepath.Base(pkgPath.Value())))
+	outcome, err := upload(ctx, pkgPath, p.packOptions()...)
+	tracing.End(span, err)
+	return outcome, err
+}
+
+// lintPackages checks all packages before uploading any of them, so a
+// broken descriptor never makes it to OSM. Each package Lint rejects gets
+// reported as failed and marked as such in the failed map.
+func (p *Engine) lintPackages(pkgs []file.AbsPath,
+	failed map[string]bool) []error {
+	es := []error{}
+	for _, pkgPath := range pkgs {
+		if failed[pkgPath.Value()] {
+			continue
+		}
+		if err := pkgr.Lint(pkgPath, p.packOptions()...); err != nil {
+			p.log().Error(err, lintFailedMsg, packageLogKey, pkgPath.Value())
+			p.report.addFailed(ItemKind.PACKAGE, pkgPath, err)
+			failed[pkgPath.Value()] = true
+			es = append(es, err)
+		}
+	}
+	return es
+}
+
+func (p *Engine) processGitOpsFiles() []error {
+	es := p.repoScanner().Visit(p)
+	p.report.addVisitErrors(ItemKind.GITOPS_FILE, es)
+	return es
+}
+
+// prunePackageVersions deletes from OSM the old versions of each package
+// we on-boarded, keeping as many as the OpsConfig retention policy says
+// plus the current one. Pruning is best effort: errors only get logged.
+func (p *Engine) prunePackageVersions() {
+	versioning := p.opsConfig.PackageVersioning()
+	if versioning == nil || versioning.Keep <= 0 {
+		return
+	}
+	pkgs, err := p.opsConfig.RepoPkgDirectories()
+	if err != nil {
+		return // processPackages already reported it
+	}
+	for k := len(pkgs) - 1; k >= 0; k-- { // (*)
+		name := filepath.Base(pkgs[k].Value())
+		versionedId, ok := p.versionedIds[name]
+		if !ok {
+			continue
+		}
+		ctx, cancel := p.operationCtx()
+		deleted, err := p.nbic.PrunePackageVersions(ctx, name,
+			versioning.Keep, versionedId)
+		cancel()
+		if err != nil {
+			p.log().Error(err, pruneFailedMsg, packageLogKey, pkgs[k].Value())
+		}
+		if len(deleted) > 0 {
+			p.log().Info(prunedMsg, packageLogKey, pkgs[k].Value(),
+				prunedLogKey, deleted)
+		}
+	}
+
+	// (*) reverse alphabetical order, so we prune NSDs before the VNFDs
+	// they reference. (See: Reconcile)
+}
+
+// nsdName figures out which NSD the NS instance should use. That's the
+// pinned NSD version if the file pins one, otherwise the version we just
+// on-boarded from the repo, if any, or just the NSD in the file.
+func (p *Engine) nsdName(action *cfg.KduNsAction) (string, error) {
+	if action.NsdVersion != "" {
+		if !p.isVersioning() {
+			return "", fmt.Errorf(
+				"nsdVersion %s requires packageVersions in the OpsConfig",
+				action.NsdVersion)
+		}
+		return pkgr.VersionedId(action.NsdName, action.NsdVersion), nil
+	}
+	if versionedId, ok := p.versionedIds[action.NsdName]; ok {
+		return versionedId, nil
+	}
+	return action.NsdName, nil
 }
 
 func (p *Engine) Process(file *cfg.KduNsActionFile) error {
+	nsdName, err := p.nsdName(file.Content)
+	if err != nil {
+		p.report.addFailed(ItemKind.GITOPS_FILE, file.FilePath, err)
+		return err
+	}
+	if p.deps.affects(nsdName) {
+		p.log().Info(skippingMsg, fileLogKey, file.FilePath.Value())
+		p.report.addSkipped(ItemKind.GITOPS_FILE, file.FilePath)
+		return nil
+	}
+
 	p.log().Info(processingMsg, fileLogKey, file.FilePath.Value())
+	started := time.Now()
 
 	data := nbic.NsInstanceContent{
 		Name:           file.Content.Name,
 		Description:    file.Content.Description,
-		NsdName:        file.Content.NsdName,
+		NsdName:        nsdName,
 		VnfName:        file.Content.VnfName,
 		VimAccountName: file.Content.VimAccountName,
 		KduName:        file.Content.Kdu.Name,
 		KduParams:      file.Content.Kdu.Params,
 	}
-	return p.nbic.CreateOrUpdateNsInstance(&data)
+	ctx, cancel := p.operationCtx()
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "CreateOrUpdateNsInstance",
+		tracing.NsNameKey.String(data.Name),
+		tracing.GitOpsFileKey.String(file.FilePath.Value()))
+	outcome, err := p.nbic.CreateOrUpdateNsInstance(ctx, &data)
+	tracing.End(span, err)
+	p.report.addProcessed(ItemKind.GITOPS_FILE, file.FilePath, started,
+		outcome, err)
+	return err
 }
 
 // New instantiates an Engine to reconcile the state of the OSM deployment
 // with that declared in the OSM GitOps files found in the specified repo.
+// The Engine runs all NBI operations with a context derived from ctx, so
+// cancelling ctx stops any operation in flight.
 func New(ctx context.Context, repoRootDir string) (*Engine, error) {
 	engine, err := newProcessor(ctx, repoRootDir)
 	if err != nil {
@@ -122,7 +402,8 @@ func New(ctx context.Context, repoRootDir string) (*Engine, error) {
 
 // Reconcile looks for OSM GitOps files in the repo and, for each file
 // found, it calls OSM NBI to reach the deployment state declared in the
-// file.
+// file. Reconcile returns a Report detailing what happened to each package
+// and GitOps file it came across.
 //
 // Additionally, if there's an OSM package root directory (see: Store),
 // Reconcile creates or updates any OSM packages found in there. Reconcile
@@ -154,21 +435,41 @@ func New(ctx context.Context, repoRootDir string) (*Engine, error) {
 // Because my-service_knf < my-service_ns (alphabetical order), Reconcile
 // will first process my-service_knf and then my-service_ns.
 //
+// If some packages fail, Reconcile still processes the GitOps files except
+// for those declaring an NS instance whose NSD, or any of the VNFDs the NSD
+// references, comes from a failed package. Those files get reported as
+// skipped. Reconcile figures out which descriptors each package defines by
+// parsing the YAML files in the package directory (see pkgr.ReadDescriptors)
+// and plays safe if it can't tell what a failed package contains: in that
+// case it skips all the GitOps files.
+//
+// If the OpsConfig asks for package versioning, Reconcile on-boards each
+// package version under its own versioned ID instead of updating the
+// package in place. (See: pkgr.VersionedId) The version comes from the
+// descriptor named after the package or, failing that, from the package
+// content. (See: contentVersion) An NS instance file can pin an NSD version,
+// otherwise Reconcile uses the NSD version in the repo. Notice OSM won't
+// switch the NSD of an existing NS instance, so pinning only affects new
+// NS instances. After processing the GitOps files, Reconcile deletes old
+// package versions as the retention policy says. (See: cfg.PackageVersioning)
+//
 // Surely this is a stopgap solution. Eventually we'll implement proper
 // handling of package dependencies. (Solution: parse OSM package definitions,
 // build dependency graph, extract DAG d[k] for each graph component g[k],
 // topologically sort d[k] ~~> s[k]; process s[k] sequences in parallel.)
-func (p *Engine) Reconcile() {
+func (p *Engine) Reconcile() *Report {
+	p.report = newReport()
+	p.deps = newPkgDeps()
+
 	errors := p.processPackages()
-	if len(errors) == 0 {
-		errors = p.repoScanner().Visit(p)
-	}
-	// else stop there since KDU ops might fail b/c referenced packages
-	// weren't created or updated.
+	errors = append(errors, p.processGitOpsFiles()...)
+	p.prunePackageVersions()
 
 	if len(errors) > 0 {
 		for k, e := range errors {
 			p.log().Error(e, processingErrMsg, errorLogKey, k)
 		}
 	}
+
+	return p.report
 }
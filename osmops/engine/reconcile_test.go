@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/fluxcd/source-watcher/osmops/cfg"
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
@@ -67,10 +69,22 @@ func TestReconcileDoNothingIfNoOsmGitOpsFileNorOsmPkgFound(t *testing.T) {
 		t.Errorf("want: engine; got: %v", err)
 	}
 
-	engine.Reconcile()
+	report := engine.Reconcile()
 	if got := logger.countEntries(); got != 0 {
 		t.Fatalf("want: 0; got: %d", got)
 	}
+	if got := len(report.Items); got != 0 {
+		t.Errorf("want: 0; got: %d", got)
+	}
+}
+
+func reportedOps(report *Report) map[string]string {
+	ops := map[string]string{}
+	for _, item := range report.Items {
+		name := filepath.Base(item.Source.Value())
+		ops[name] = Op.LabelOf(item.Op)
+	}
+	return ops
 }
 
 func TestReconcileProcessOsmGitOpsFiles(t *testing.T) {
@@ -84,7 +98,7 @@ func TestReconcileProcessOsmGitOpsFiles(t *testing.T) {
 	}
 
 	engine.nbic = mockNbic
-	engine.Reconcile()
+	report := engine.Reconcile()
 
 	if mockNbic.hasProcessedKdu("k1") {
 		t.Errorf("want: skip k1 (invalid content); got: processed")
@@ -119,6 +133,22 @@ func TestReconcileProcessOsmGitOpsFiles(t *testing.T) {
 	if got := logger.sortErrorFileNames(); !reflect.DeepEqual(want, got) {
 		t.Errorf("want: %v; got: %v", want, got)
 	}
+
+	wantOps := map[string]string{
+		"k1.ops.yaml": "failed", "k2.ops.yaml": "failed",
+		"k3.ops.yaml": "created",
+	}
+	if got := reportedOps(report); !reflect.DeepEqual(wantOps, got) {
+		t.Errorf("want: %v; got: %v", wantOps, got)
+	}
+	for _, item := range report.Items {
+		if _, ok := item.Err.(*file.VisitError); item.Op == Op.FAILED && !ok {
+			t.Errorf("want: visit error; got: %v", item.Err)
+		}
+		if item.Kind != ItemKind.GITOPS_FILE {
+			t.Errorf("want: gitops file; got: %v", ItemKind.LabelOf(item.Kind))
+		}
+	}
 }
 
 func TestNewNbicFailOnInvalidHostAndPort(t *testing.T) {
@@ -133,22 +163,57 @@ func TestNewNbicFailOnInvalidHostAndPort(t *testing.T) {
 	}
 }
 
-func TestReconcileProcessNoOsmGitOpsFilesOnPackageErr(t *testing.T) {
+func TestReconcileSkipOsmGitOpsFilesDependingOnFailedPackages(t *testing.T) {
 	logger := newLogCollector()
 	repoRootDir := findTestDataDir(4)
 	mockNbic := newMockNbicWorkflow()
 	engine, _ := New(newCtx(logger), repoRootDir.Value())
 	engine.nbic = mockNbic
 
-	engine.Reconcile()
+	report := engine.Reconcile()
 
 	wantProcessedPkgs := []string{"p2"}
 	if !reflect.DeepEqual(mockNbic.processedPkgNames, wantProcessedPkgs) {
 		t.Errorf("want processed pkgs: %v; got: %v", wantProcessedPkgs,
 			mockNbic.processedPkgNames)
 	}
+	if mockNbic.hasProcessedKdu("k2") {
+		t.Errorf("want: skip k2 b/c its nsd refs a failed vnfd; got: processed")
+	}
+	if mockNbic.hasProcessedKdu("k3") {
+		t.Errorf("want: skip k3 b/c its nsd failed; got: processed")
+	}
+	if !mockNbic.hasProcessedKdu("k4") {
+		t.Errorf("want: process k4 b/c no failed deps; got: not processed")
+	}
+
+	wantOps := map[string]string{
+		"p1_knf": "failed", "p1_ns": "failed", "p2": "updated",
+		"k1.ops.yaml": "failed", "k2.ops.yaml": "skipped",
+		"k3.ops.yaml": "skipped", "k4.ops.yaml": "created",
+	}
+	if got := reportedOps(report); !reflect.DeepEqual(wantOps, got) {
+		t.Errorf("want: %v; got: %v", wantOps, got)
+	}
+}
+
+func TestReconcileSkipAllOsmGitOpsFilesOnUnknownPackageErr(t *testing.T) {
+	logger := newLogCollector()
+	repoRootDir := findTestDataDir(6)
+	mockNbic := newMockNbicWorkflow()
+	engine, _ := New(newCtx(logger), repoRootDir.Value())
+	engine.nbic = mockNbic
+
+	report := engine.Reconcile()
+
 	if mockNbic.hasProcessedKdus() {
-		t.Errorf("want: skip kdus b/c of prev pkg errors; got: some processed")
+		t.Errorf("want: skip kdus b/c of unknown pkg errors; got: some processed")
+	}
+	wantOps := map[string]string{
+		"p1": "failed", "k2.ops.yaml": "skipped", "k3.ops.yaml": "skipped",
+	}
+	if got := reportedOps(report); !reflect.DeepEqual(wantOps, got) {
+		t.Errorf("want: %v; got: %v", wantOps, got)
 	}
 }
 
@@ -182,7 +247,7 @@ func TestReconcileProcessPackagesAndOsmGitOpsFiles(t *testing.T) {
 	engine, _ := New(newCtx(logger), repoRootDir.Value())
 	engine.nbic = mockNbic
 
-	engine.Reconcile()
+	report := engine.Reconcile()
 
 	wantProcessedPkgs := []string{"p2", "p3"}
 	if !reflect.DeepEqual(mockNbic.processedPkgNames, wantProcessedPkgs) {
@@ -199,4 +264,168 @@ func TestReconcileProcessPackagesAndOsmGitOpsFiles(t *testing.T) {
 	if !mockNbic.hasProcessedKdu("k3") {
 		t.Errorf("want: process k3; got: not processed")
 	}
+
+	if got := report.Count(Op.UPDATED); got != 2 {
+		t.Errorf("want: 2 updated pkgs; got: %d", got)
+	}
+	if got := report.Count(Op.CREATED); got != 1 {
+		t.Errorf("want: 1 created ns instance; got: %d", got)
+	}
+	if got := report.Count(Op.FAILED); got != 2 {
+		t.Errorf("want: 2 failed files; got: %d", got)
+	}
+}
+
+type ctxKey struct{}
+
+func TestReconcileRunsOperationsWithDeadline(t *testing.T) {
+	logger := newLogCollector()
+	repoRootDir := findTestDataDir(4)
+	mockNbic := newMockNbicWorkflow()
+
+	ctx := context.WithValue(newCtx(logger), ctxKey{}, "reconcile")
+	engine, err := New(ctx, repoRootDir.Value())
+	if err != nil {
+		t.Fatalf("want: engine; got: %v", err)
+	}
+
+	engine.nbic = mockNbic
+	engine.Reconcile()
+
+	if len(mockNbic.ctxs) == 0 {
+		t.Fatalf("want: NBI operations; got: none")
+	}
+	for k, opCtx := range mockNbic.ctxs {
+		if opCtx.Value(ctxKey{}) != "reconcile" {
+			t.Errorf("[%d] want: ctx derived from reconcile ctx; got: %v",
+				k, opCtx)
+		}
+		if _, ok := opCtx.Deadline(); !ok {
+			t.Errorf("[%d] want: deadline; got: none", k)
+		}
+	}
+}
+
+func TestReconcileDontUploadPackagesFailingLint(t *testing.T) {
+	logger := newLogCollector()
+	repoRootDir := findTestDataDir(7)
+	mockNbic := newMockNbicWorkflow()
+	engine, _ := New(newCtx(logger), repoRootDir.Value())
+	engine.nbic = mockNbic
+
+	report := engine.Reconcile()
+
+	wantProcessedPkgs := []string{"good_ns"}
+	if !reflect.DeepEqual(mockNbic.processedPkgNames, wantProcessedPkgs) {
+		t.Errorf("want processed pkgs: %v; got: %v", wantProcessedPkgs,
+			mockNbic.processedPkgNames)
+	}
+	if mockNbic.hasProcessedKdu("k1") {
+		t.Errorf("want: skip k1 b/c its nsd refs a vnfd failing lint; got: processed")
+	}
+
+	wantOps := map[string]string{
+		"bad_knf": "failed", "good_ns": "updated", "k1.ops.yaml": "skipped",
+	}
+	if got := reportedOps(report); !reflect.DeepEqual(wantOps, got) {
+		t.Errorf("want: %v; got: %v", wantOps, got)
+	}
+}
+
+func TestReconcileVersionedPackages(t *testing.T) {
+	logger := newLogCollector()
+	repoRootDir := findTestDataDir(8)
+	mockNbic := newMockNbicWorkflow()
+	engine, _ := New(newCtx(logger), repoRootDir.Value())
+	engine.nbic = mockNbic
+
+	report := engine.Reconcile()
+
+	wantIds := map[string]string{
+		"a_knf": "a_knf-1.0", "a_ns": "a_ns-964ba97", "old_knf": "old_knf-2.0",
+	}
+	if !reflect.DeepEqual(wantIds, engine.versionedIds) {
+		t.Errorf("want: %v; got: %v", wantIds, engine.versionedIds)
+	}
+	if got := mockNbic.dataFor("k1").NsdName; got != "a_ns-964ba97" {
+		t.Errorf("want: repo nsd version; got: %s", got)
+	}
+	if got := mockNbic.dataFor("k3").NsdName; got != "a_ns-0.9" {
+		t.Errorf("want: pinned nsd version; got: %s", got)
+	}
+
+	wantOps := map[string]string{
+		"a_knf": "updated", "a_ns": "updated", "old_knf": "unchanged",
+		"k1.ops.yaml": "created", "k3.ops.yaml": "created",
+	}
+	if got := reportedOps(report); !reflect.DeepEqual(wantOps, got) {
+		t.Errorf("want: %v; got: %v", wantOps, got)
+	}
+
+	wantPruned := []string{"old_knf", "a_ns", "a_knf"}
+	if !reflect.DeepEqual(wantPruned, mockNbic.prunedPkgNames) {
+		t.Errorf("want: %v; got: %v", wantPruned, mockNbic.prunedPkgNames)
+	}
+	wantKept := []string{"old_knf-2.0", "a_ns-964ba97", "a_knf-1.0"}
+	if !reflect.DeepEqual(wantKept, mockNbic.keptPkgIds) {
+		t.Errorf("want: %v; got: %v", wantKept, mockNbic.keptPkgIds)
+	}
+}
+
+func TestContentVersion(t *testing.T) {
+	descs := &pkgr.Descriptors{
+		Nsds:   map[string][]string{"n": {"v", "w"}},
+		Digest: "d1",
+	}
+	ids := map[string]string{"v": "v-1.0", "x": "x-1.0"}
+	version := contentVersion(descs, ids)
+	if len(version) != contentVersionLength {
+		t.Errorf("want: %d hex digits; got: %s", contentVersionLength, version)
+	}
+
+	if got := contentVersion(descs, map[string]string{"v": "v-1.0"}); got != version {
+		t.Errorf("want: same version if refs didn't change; got: %s", got)
+	}
+	if got := contentVersion(descs, map[string]string{"v": "v-1.1"}); got == version {
+		t.Errorf("want: new version on vnfd bump; got: %s", got)
+	}
+	changed := &pkgr.Descriptors{Nsds: descs.Nsds, Digest: "d2"}
+	if got := contentVersion(changed, ids); got == version {
+		t.Errorf("want: new version on content change; got: %s", got)
+	}
+}
+
+func TestNsdVersionRequiresPackageVersioning(t *testing.T) {
+	engine, _ := New(newCtx(newLogCollector()), findTestDataDir(5).Value())
+	action := &cfg.KduNsAction{NsdName: "d", NsdVersion: "1.0"}
+	if _, err := engine.nsdName(action); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestVersionPackagesDoesntRenameDescriptors(t *testing.T) {
+	repoRootDir := findTestDataDir(8)
+	engine, _ := New(newCtx(newLogCollector()), repoRootDir.Value())
+	engine.report = newReport()
+	pkgs, err := engine.opsConfig.RepoPkgDirectories()
+	if err != nil {
+		t.Fatalf("want: pkgs; got: %v", err)
+	}
+
+	t.Setenv("TMPDIR", "/no/such/dir") // (*)
+	failed := map[string]bool{}
+	if es := engine.versionPackages(pkgs, failed); len(es) > 0 {
+		t.Fatalf("want: no errors; got: %v", es)
+	}
+
+	wantIds := map[string]string{
+		"a_knf": "a_knf-1.0", "a_ns": "a_ns-964ba97", "old_knf": "old_knf-2.0",
+	}
+	if !reflect.DeepEqual(wantIds, engine.versionedIds) {
+		t.Errorf("want: %v; got: %v", wantIds, engine.versionedIds)
+	}
+
+	// (*) a_ns references a_knf, which gets versioned first. If
+	// versionPackages renamed descriptors while reading a_ns's version,
+	// ReadDescriptors would stage a renamed copy in a temp dir and fail.
 }
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/fluxcd/source-watcher/osmops/nbic"
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// ItemKind enumerates the kinds of repo items Reconcile processes.
+var ItemKind = struct {
+	u.StrEnum
+	PACKAGE, GITOPS_FILE u.EnumIx
+}{
+	StrEnum:     u.NewStrEnum("package", "gitops file"),
+	PACKAGE:     0,
+	GITOPS_FILE: 1,
+}
+
+// Op enumerates what Reconcile did with a repo item.
+//
+// CREATED and UPDATED mean OSM NBI successfully created or updated the
+// OSM entity corresponding to the item. UNCHANGED means OSM was already
+// in the state the item declares, e.g. it had a package with the same files,
+// so there was no need to change anything.
+// SKIPPED means Reconcile didn't process the item at all, typically
+// because an item it depends on failed. FAILED means Reconcile tried
+// processing the item but got an error.
+var Op = struct {
+	u.StrEnum
+	CREATED, UPDATED, UNCHANGED, SKIPPED, FAILED u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("created", "updated", "unchanged", "skipped",
+		"failed"),
+	CREATED:   0,
+	UPDATED:   1,
+	UNCHANGED: 2,
+	SKIPPED:   3,
+	FAILED:    4,
+}
+
+// ReportItem holds the result of processing a package directory or an
+// OSM GitOps file.
+type ReportItem struct {
+	// The kind of item processed, one of the ItemKind values.
+	Kind u.EnumIx
+	// The absolute path of the package directory or GitOps file.
+	Source file.AbsPath
+	// What Reconcile did with the item, one of the Op values.
+	Op u.EnumIx
+	// The OSM ID of the entity created or updated, if any. This is the
+	// VNFD or NSD ID for a package and the NS instance ID for a GitOps file.
+	NbiId string
+	// How long it took to process the item.
+	Duration time.Duration
+	// The error that made processing fail, nil if Op isn't FAILED. For
+	// GitOps file errors, this is a file.VisitError wrapping the actual
+	// cause.
+	Err error
+}
+
+// Report lists, in processing order, the items Reconcile processed.
+type Report struct {
+	Items []ReportItem
+}
+
+func newReport() *Report {
+	return &Report{Items: []ReportItem{}}
+}
+
+func toOp(outcome *nbic.Outcome, err error) u.EnumIx {
+	if err != nil || outcome == nil {
+		return Op.FAILED
+	}
+	if outcome.Created {
+		return Op.CREATED
+	}
+	if outcome.Unchanged {
+		return Op.UNCHANGED
+	}
+	return Op.UPDATED
+}
+
+func (r *Report) addProcessed(kind u.EnumIx, source file.AbsPath,
+	started time.Time, outcome *nbic.Outcome, err error) {
+	item := ReportItem{
+		Kind:     kind,
+		Source:   source,
+		Op:       toOp(outcome, err),
+		Duration: time.Since(started),
+		Err:      err,
+	}
+	if outcome != nil {
+		item.NbiId = outcome.Id
+	}
+	r.Items = append(r.Items, item)
+}
+
+func (r *Report) addSkipped(kind u.EnumIx, source file.AbsPath) {
+	r.Items = append(r.Items, ReportItem{
+		Kind:   kind,
+		Source: source,
+		Op:     Op.SKIPPED,
+	})
+}
+
+func (r *Report) addFailed(kind u.EnumIx, source file.AbsPath, err error) {
+	r.Items = append(r.Items, ReportItem{
+		Kind:   kind,
+		Source: source,
+		Op:     Op.FAILED,
+		Err:    err,
+	})
+}
+
+func (r *Report) lookup(source file.AbsPath) *ReportItem {
+	for k := range r.Items {
+		if r.Items[k].Source.Value() == source.Value() {
+			return &r.Items[k]
+		}
+	}
+	return nil
+}
+
+// addVisitErrors records the errors a repo scan returned. If an error
+// is about an item already in the report, then it replaces the item's
+// error since it carries the item path too. Otherwise it gets recorded
+// as a new failed item---e.g. GitOps files with invalid content that
+// the scanner never handed over to the engine.
+func (r *Report) addVisitErrors(kind u.EnumIx, es []error) {
+	for _, e := range es {
+		source := file.AbsPath{}
+		if ve, ok := e.(*file.VisitError); ok {
+			source, _ = file.ParseAbsPath(ve.AbsPath)
+		}
+		if item := r.lookup(source); item != nil {
+			item.Err = e
+		} else {
+			r.addFailed(kind, source, e)
+		}
+	}
+}
+
+// Count returns the number of items processed with the given Op.
+func (r *Report) Count(op u.EnumIx) int {
+	n := 0
+	for _, item := range r.Items {
+		if item.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors tells if processing any of the items failed.
+func (r *Report) HasErrors() bool {
+	return r.Count(Op.FAILED) > 0
+}
+
+// Errors collects the errors of the items that failed, in processing order.
+func (r *Report) Errors() []error {
+	es := []error{}
+	for _, item := range r.Items {
+		if item.Err != nil {
+			es = append(es, item.Err)
+		}
+	}
+	return es
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+func TestReportAddProcessed(t *testing.T) {
+	src, _ := file.ParseAbsPath("p")
+	started := time.Now()
+	report := newReport()
+
+	report.addProcessed(ItemKind.PACKAGE, src, started,
+		&nbic.Outcome{Created: true, Id: "1"}, nil)
+	report.addProcessed(ItemKind.PACKAGE, src, started,
+		&nbic.Outcome{Created: false, Id: "2"}, nil)
+	report.addProcessed(ItemKind.PACKAGE, src, started, nil, errors.New("3"))
+
+	wantOps := []string{"created", "updated", "failed"}
+	wantIds := []string{"1", "2", ""}
+	for k, item := range report.Items {
+		if got := Op.LabelOf(item.Op); got != wantOps[k] {
+			t.Errorf("[%d] want op: %s; got: %s", k, wantOps[k], got)
+		}
+		if item.NbiId != wantIds[k] {
+			t.Errorf("[%d] want id: %s; got: %s", k, wantIds[k], item.NbiId)
+		}
+		if item.Duration < 0 {
+			t.Errorf("[%d] want: duration >= 0; got: %v", k, item.Duration)
+		}
+	}
+}
+
+func TestReportAddVisitErrors(t *testing.T) {
+	f1, _ := file.ParseAbsPath("f1")
+	f2, _ := file.ParseAbsPath("f2")
+	report := newReport()
+	report.addProcessed(ItemKind.GITOPS_FILE, f1, time.Now(), nil,
+		errors.New("f1"))
+
+	e1 := &file.VisitError{AbsPath: f1.Value(), Err: errors.New("f1")}
+	e2 := &file.VisitError{AbsPath: f2.Value(), Err: errors.New("f2")}
+	e3 := errors.New("no path")
+	report.addVisitErrors(ItemKind.GITOPS_FILE, []error{e1, e2, e3})
+
+	if got := len(report.Items); got != 3 {
+		t.Fatalf("want: 3; got: %d", got)
+	}
+	if report.Items[0].Err != e1 {
+		t.Errorf("want: visit error replacing original; got: %v",
+			report.Items[0].Err)
+	}
+	if report.Items[1].Source != f2 || report.Items[1].Op != Op.FAILED {
+		t.Errorf("want: f2 failed; got: %+v", report.Items[1])
+	}
+	if got := report.Count(Op.FAILED); got != 3 {
+		t.Errorf("want: 3; got: %d", got)
+	}
+	want := []error{e1, e2, e3}
+	if got := report.Errors(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestEmptyReportHasNoErrors(t *testing.T) {
+	report := newReport()
+	if report.HasErrors() {
+		t.Errorf("want: no errors; got: %v", report.Errors())
+	}
+	if got := len(report.Errors()); got != 0 {
+		t.Errorf("want: 0; got: %d", got)
+	}
+}
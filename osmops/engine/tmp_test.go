@@ -1,6 +1,8 @@
 package engine
 
 import (
+	"context"
+
 	"gopkg.in/yaml.v2"
 
 	"github.com/fluxcd/source-watcher/osmops/nbic"
@@ -41,7 +43,7 @@ func T() {
 		KduName:        "ldap",
 		KduParams:      kduParams(),
 	}
-	err := client.CreateOrUpdateNsInstance(&data)
+	_, err := client.CreateOrUpdateNsInstance(context.TODO(), &data)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,366 @@
+// Export the state of an OSM deployment to an OsmOps repo.
+//
+// Export reads the packages and NS instances in OSM through NBI and writes
+// them to a repo in the layout `cfg.Store` expects, so you can migrate an
+// existing OSM installation to OsmOps. Here's what an exported repo looks
+// like:
+//
+//     my-gitops-repo
+//     | -- osm_ops_config.yaml
+//     + -- deployment-target-dir
+//        | -- ldap.osmops.yaml            (<- NS instance "ldap")
+//        + -- osm-pkgs
+//           + -- openldap_knf             (<- VNF package "openldap_knf")
+//              | -- openldap_vnfd.yaml
+//           + -- openldap_ns              (<- NS package "openldap_ns")
+//              | -- openldap_nsd.yaml
+//
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fluxcd/source-watcher/osmops/cfg"
+	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// DefaultConnectionFile is the path Export writes to the OpsConfig as
+// connection file, unless told otherwise. (See: WithConnectionFile)
+const DefaultConnectionFile = "/etc/osmops/nbi-connection.yaml"
+
+type exportOpts struct {
+	targetDir      string
+	connectionFile string
+}
+
+// ExportOption tweaks the way Export lays out the repo.
+type ExportOption func(opts *exportOpts)
+
+// WithTargetDir makes Export write packages and NS instance files to the
+// given directory, relative to the repo root, instead of the repo root.
+// (See: cfg.OpsConfig.TargetDir)
+func WithTargetDir(dir string) ExportOption {
+	return func(opts *exportOpts) {
+		opts.targetDir = dir
+	}
+}
+
+// WithConnectionFile sets the path of the OSM connection file in the
+// OpsConfig Export writes. Export never writes the connection file itself
+// since it holds the OSM credentials. (See: cfg.OpsConfig.ConnectionFile)
+func WithConnectionFile(path string) ExportOption {
+	return func(opts *exportOpts) {
+		opts.connectionFile = path
+	}
+}
+
+// Skipped is an OSM package or NS instance Export couldn't write to the
+// repo.
+type Skipped struct {
+	// What got skipped, either a package or an NS instance.
+	Kind string
+	// The name of the package or NS instance.
+	Name string
+	// Why Export skipped it.
+	Reason error
+}
+
+func (s *Skipped) String() string {
+	return fmt.Sprintf("%s %s: %v", s.Kind, s.Name, s.Reason)
+}
+
+const (
+	packageKind    = "package"
+	nsInstanceKind = "NS instance"
+)
+
+// Report tells what Export wrote to the repo.
+type Report struct {
+	// The package directories Export wrote.
+	Packages []file.AbsPath
+	// The NS instance files Export wrote.
+	NsInstanceFiles []file.AbsPath
+	// The OpsConfig file Export wrote.
+	OpsConfigFile file.AbsPath
+	// The packages and NS instances Export left out.
+	Skipped []*Skipped
+}
+
+func (r *Report) skip(kind, name string, reason error) {
+	r.Skipped = append(r.Skipped,
+		&Skipped{Kind: kind, Name: name, Reason: reason})
+}
+
+type exporter struct {
+	ctx       context.Context
+	osm       nbic.Inventory
+	targetDir file.AbsPath
+	pkgsDir   file.AbsPath
+	report    *Report
+}
+
+// Export writes the packages and NS instances in OSM to the given repo
+// root directory, along with an OpsConfig to manage them with OsmOps.
+// Export refuses to write to a repo that already has an OpsConfig.
+//
+// Export downloads each package and extracts it to a directory named after
+// the package's VNFD or NSD, under the OSM package root. (See: cfg.Store)
+// It leaves out the checksum files and the signature, since Pack generates
+// them. Export checks the package content against any checksum files in
+// the package, but accepts packages without checksum files. For OsmOps to
+// manage a package, the descriptor ID has to end in "_knf" or "_ns", so
+// Export skips packages that don't follow this convention.
+//
+// For each NS instance, Export writes a KduNsAction file to the target
+// directory, named after the instance, e.g. "ldap.osmops.yaml". The KDU
+// parameters are those the instance got instantiated with. Since OSM lets
+// you have more than one instance with the same name and a KduNsAction can
+// only describe one KDU, Export skips instances with duplicate names and
+// instances with more or less than one KDU.
+//
+// Export lists skipped items in the returned Report and only fails if it
+// can't read from OSM or write to the repo.
+func Export(ctx context.Context, osm nbic.Inventory, repoRootDir file.AbsPath,
+	opts ...ExportOption) (*Report, error) {
+	config := makeOpsConfig(opts...)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if err := repoRootDir.IsDir(); err != nil {
+		return nil, err
+	}
+	if cfg.HasOpsConfig(repoRootDir) {
+		return nil, fmt.Errorf("%v: already exists",
+			repoRootDir.Join(cfg.OpsConfigFileName))
+	}
+
+	targetDir := repoRootDir.Join(config.TargetDir)
+	e := &exporter{
+		ctx:       ctx,
+		osm:       osm,
+		targetDir: targetDir,
+		pkgsDir:   targetDir.Join(cfg.OsmPackagesDirName),
+		report:    &Report{},
+	}
+	if err := os.MkdirAll(e.pkgsDir.Value(), 0755); err != nil {
+		return nil, err
+	}
+	if err := e.exportPackages(); err != nil {
+		return e.report, err
+	}
+	if err := e.exportNsInstances(); err != nil {
+		return e.report, err
+	}
+
+	configFile := repoRootDir.Join(cfg.OpsConfigFileName)
+	if err := writeYaml(configFile, config); err != nil {
+		return e.report, err
+	}
+	e.report.OpsConfigFile = configFile
+	return e.report, nil
+}
+
+func makeOpsConfig(opts ...ExportOption) *opsConfigView {
+	settings := &exportOpts{connectionFile: DefaultConnectionFile}
+	for _, setting := range opts {
+		if setting != nil {
+			setting(settings)
+		}
+	}
+	return &opsConfigView{
+		TargetDir:      settings.targetDir,
+		ConnectionFile: settings.connectionFile,
+	}
+}
+
+// opsConfigView is the part of OpsConfig Export writes. We don't write
+// OpsConfig directly since we don't want empty fields in the YAML.
+type opsConfigView struct {
+	TargetDir      string `yaml:"targetDir,omitempty"`
+	ConnectionFile string `yaml:"connectionFile"`
+}
+
+func (d *opsConfigView) Validate() error {
+	return cfg.OpsConfig{
+		TargetDir:      d.TargetDir,
+		ConnectionFile: d.ConnectionFile,
+	}.Validate()
+}
+
+func (e *exporter) exportPackages() error {
+	pkgs, err := e.osm.ListPackages(e.ctx)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		target, err := e.exportPackage(pkg)
+		if err != nil {
+			e.report.skip(packageKind, pkg.Name, err)
+			continue
+		}
+		e.report.Packages = append(e.report.Packages, target)
+	}
+	return nil
+}
+
+func (e *exporter) exportPackage(pkg *nbic.PackageInfo) (file.AbsPath, error) {
+	target := e.pkgsDir.Join(pkg.Name)
+	suffix := "_" + nbic.PackageKind.LabelOf(pkg.Kind)
+	if !strings.HasSuffix(pkg.Name, suffix) {
+		return target, fmt.Errorf("name doesn't end with %s", suffix)
+	}
+	if strings.ContainsAny(pkg.Name, `/\`) {
+		return target, fmt.Errorf("not a valid directory name")
+	}
+	if _, err := os.Lstat(target.Value()); err == nil {
+		return target, fmt.Errorf("%v: already exists", target)
+	}
+
+	tarball, err := e.download(pkg)
+	if err != nil {
+		return target, err
+	}
+	defer os.Remove(tarball.Name())
+
+	staging, err := os.MkdirTemp(e.pkgsDir.Value(), ".osm-export-*")
+	if err != nil {
+		tarball.Close()
+		return target, err
+	}
+	defer os.RemoveAll(staging)
+	stagingDir, _ := file.ParseAbsPath(staging)
+
+	src, err := pkgr.Unpack(tarball, stagingDir, pkgr.AllowMissingChecksums()) // (1)
+	if err != nil {
+		return target, err
+	}
+	for _, name := range pkgr.ManifestFileNames() {
+		manifest := src.Directory().Join(name)
+		if err := os.Remove(manifest.Value()); err != nil && !os.IsNotExist(err) {
+			return target, err
+		}
+	}
+	return target, os.Rename(src.Directory().Value(), target.Value()) // (2)
+
+	// NOTE.
+	// 1. Unpack closes the tarball, even if it fails.
+	// 2. Package directory name. The root dir in the archive could have any
+	// name, but OsmOps needs the directory to be named after the descriptor.
+	// (See: nbic.Workflow.CreateOrUpdatePackage) So we extract the archive
+	// to a staging directory and then move its root dir to the target.
+}
+
+func (e *exporter) download(pkg *nbic.PackageInfo) (*os.File, error) {
+	tarball, err := os.CreateTemp("", "osm-export-*")
+	if err != nil {
+		return nil, err
+	}
+	if err = e.osm.DownloadPackage(e.ctx, pkg, tarball); err == nil {
+		_, err = tarball.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tarball.Close()
+		os.Remove(tarball.Name())
+		return nil, err
+	}
+	return tarball, nil
+}
+
+func (e *exporter) exportNsInstances() error {
+	instances, err := e.osm.ListNsInstances(e.ctx)
+	if err != nil {
+		return err
+	}
+	nameCount := map[string]int{}
+	for _, ns := range instances {
+		nameCount[ns.Name]++
+	}
+
+	for _, ns := range instances {
+		if nameCount[ns.Name] > 1 {
+			e.report.skip(nsInstanceKind, ns.Name,
+				fmt.Errorf("more than one NS instance with this name"))
+			continue
+		}
+		target, err := e.exportNsInstance(ns)
+		if err != nil {
+			e.report.skip(nsInstanceKind, ns.Name, err)
+			continue
+		}
+		e.report.NsInstanceFiles = append(e.report.NsInstanceFiles, target)
+	}
+	return nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+func nsInstanceFileName(ns *nbic.NsInstanceInfo) string {
+	ext := cfg.DefaultOpsFileExtensions()[0].Value()
+	return unsafeFileNameChars.ReplaceAllString(ns.Name, "_") + ext
+}
+
+func (e *exporter) exportNsInstance(ns *nbic.NsInstanceInfo) (
+	file.AbsPath, error) {
+	target := e.targetDir.Join(nsInstanceFileName(ns))
+	if len(ns.Kdus) != 1 {
+		return target, fmt.Errorf("want: 1 KDU; got: %d", len(ns.Kdus))
+	}
+	if _, err := os.Lstat(target.Value()); err == nil {
+		return target, fmt.Errorf("%v: already exists", target)
+	}
+
+	kdu := ns.Kdus[0]
+	action := cfg.KduNsAction{
+		Kind:           "NsInstance", // (*)
+		Name:           ns.Name,
+		Description:    ns.Description,
+		NsdName:        ns.NsdName,
+		VnfName:        kdu.VnfName,
+		VimAccountName: ns.VimAccountName,
+		Kdu: cfg.Kdu{
+			Name:   kdu.KduName,
+			Params: kdu.Params,
+		},
+	}
+	if err := action.Validate(); err != nil {
+		return target, err
+	}
+	return target, writeYaml(target, action)
+
+	// (*) KduNsActionKind's label is lowercase, but we'd rather write the
+	// kind the way people do in their GitOps files.
+}
+
+func writeYaml(target file.AbsPath, data interface{}) error {
+	content, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target.Value(), content, 0644)
+}
+
+// Connect logs into OSM NBI with the given connection data to read what's
+// in OSM.
+func Connect(conn *cfg.OsmConnection) (nbic.Inventory, error) {
+	hp, err := u.ParseHostAndPort(conn.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	return nbic.DefaultSessionCache.Get(
+		nbic.Connection{Address: *hp, Secure: false},
+		nbic.UserCredentials{
+			Username: conn.User,
+			Password: conn.Password,
+			Project:  conn.Project,
+		})
+}
@@ -0,0 +1,306 @@
+package export
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fluxcd/source-watcher/osmops/cfg"
+	"github.com/fluxcd/source-watcher/osmops/nbic"
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+type mockInventory struct {
+	pkgs      []*nbic.PackageInfo
+	archives  map[string][]byte
+	instances []*nbic.NsInstanceInfo
+	listErr   error
+}
+
+func (m *mockInventory) ListPackages(ctx context.Context) (
+	[]*nbic.PackageInfo, error) {
+	return m.pkgs, m.listErr
+}
+
+func (m *mockInventory) DownloadPackage(ctx context.Context,
+	pkg *nbic.PackageInfo, sink io.Writer) error {
+	data, ok := m.archives[pkg.Id]
+	if !ok {
+		return fmt.Errorf("no package: %s", pkg.Id)
+	}
+	_, err := sink.Write(data)
+	return err
+}
+
+func (m *mockInventory) ListNsInstances(ctx context.Context) (
+	[]*nbic.NsInstanceInfo, error) {
+	return m.instances, m.listErr
+}
+
+func tempDir(t *testing.T) file.AbsPath {
+	dir, _ := file.ParseAbsPath(t.TempDir())
+	return dir
+}
+
+func packArchive(t *testing.T, name string, files map[string]string) []byte {
+	src := tempDir(t).Join(name)
+	for path, content := range files {
+		writeFile(t, src.Join(path), content)
+	}
+	pkg, err := pkgr.Pack(src)
+	if err != nil {
+		t.Fatalf("couldn't pack: %v", err)
+	}
+	defer pkg.Data.Close()
+	data, _ := io.ReadAll(pkg.Data)
+	return data
+}
+
+func tarArchive(files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for path, content := range files {
+		tw.WriteHeader(&tar.Header{
+			Name: path, Mode: 0644, Size: int64(len(content)),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func zipArchive(files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for path, content := range files {
+		w, _ := zw.Create(path)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func writeFile(t *testing.T, target file.AbsPath, content string) {
+	if err := os.MkdirAll(filepath.Dir(target.Value()), 0755); err != nil {
+		t.Fatalf("couldn't create dir: %v", err)
+	}
+	if err := os.WriteFile(target.Value(), []byte(content), 0644); err != nil {
+		t.Fatalf("couldn't write file: %v", err)
+	}
+}
+
+func skippedNames(report *Report) []string {
+	names := []string{}
+	for _, s := range report.Skipped {
+		names = append(names, s.Kind+" "+s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func ldapInventory(t *testing.T) *mockInventory {
+	return &mockInventory{
+		pkgs: []*nbic.PackageInfo{
+			{Kind: nbic.PackageKind.KNF, Id: "1", Name: "openldap_knf"},
+			{Kind: nbic.PackageKind.NS, Id: "2", Name: "openldap_ns"},
+		},
+		archives: map[string][]byte{
+			"1": packArchive(t, "openldap_knf", map[string]string{
+				"vnfd.yaml": "vnfd:\n  id: openldap_knf\n",
+			}),
+			"2": zipArchive(map[string]string{ // (*)
+				"pkg/nsd.yaml": "nsd:\n  nsd:\n  - id: openldap_ns\n",
+			}),
+		},
+		instances: []*nbic.NsInstanceInfo{
+			{
+				Id: "i1", Name: "ldap", Description: "my ldap",
+				NsdName: "openldap_ns", VimAccountName: "mylocation1",
+				Kdus: []nbic.KduInfo{
+					{
+						VnfName: "openldap", KduName: "ldap",
+						Params: map[string]interface{}{"replicaCount": "2"},
+					},
+				},
+			},
+		},
+	}
+
+	// (*) NBI sends back a zip for multi-file packages, whatever format
+	// they got uploaded in.
+}
+
+func TestExportWritesRepoLayout(t *testing.T) {
+	repo := tempDir(t)
+	osm := ldapInventory(t)
+	report, err := Export(context.TODO(), osm, repo,
+		WithTargetDir("deploy"), WithConnectionFile("secret.yaml"))
+	if err != nil {
+		t.Fatalf("want: export; got: %v", err)
+	}
+	if len(report.Skipped) != 0 {
+		t.Errorf("want: nothing skipped; got: %v", skippedNames(report))
+	}
+
+	writeFile(t, repo.Join("secret.yaml"),
+		"hostname: osm:80\nproject: admin\nuser: admin\npassword: admin\n")
+	store, err := cfg.NewStore(repo)
+	if err != nil {
+		t.Fatalf("want: store; got: %v", err)
+	}
+	pkgDirs, _ := store.RepoPkgDirectories()
+	if !reflect.DeepEqual(pkgDirs, report.Packages) {
+		t.Errorf("want: %v; got: %v", report.Packages, pkgDirs)
+	}
+
+	knf := repo.Join("deploy/osm-pkgs/openldap_knf")
+	if _, err := os.Stat(knf.Join("vnfd.yaml").Value()); err != nil {
+		t.Errorf("want: vnfd.yaml; got: %v", err)
+	}
+	if _, err := os.Stat(knf.Join(pkgr.ChecksumFileName).Value()); err == nil {
+		t.Errorf("want: no checksum file; got: checksum file")
+	}
+	ns := repo.Join("deploy/osm-pkgs/openldap_ns")
+	if _, err := os.Stat(ns.Join("nsd.yaml").Value()); err != nil {
+		t.Errorf("want: nsd.yaml; got: %v", err)
+	}
+	if descs, _ := pkgr.ReadDescriptors(ns); len(descs.Nsds) != 1 {
+		t.Errorf("want: openldap_ns; got: %+v", descs)
+	}
+}
+
+func TestExportWritesKduNsActionFiles(t *testing.T) {
+	repo := tempDir(t)
+	report, err := Export(context.TODO(), ldapInventory(t), repo)
+	if err != nil {
+		t.Fatalf("want: export; got: %v", err)
+	}
+
+	want := []file.AbsPath{repo.Join("ldap.osmops.yaml")}
+	if !reflect.DeepEqual(report.NsInstanceFiles, want) {
+		t.Fatalf("want: %v; got: %v", want, report.NsInstanceFiles)
+	}
+	content, _ := os.ReadFile(want[0].Value())
+	got := cfg.KduNsAction{}
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatalf("want: KduNsAction; got: %v", err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Errorf("want: valid KduNsAction; got: %v", err)
+	}
+	if got.Kind != "NsInstance" || got.Name != "ldap" ||
+		got.Description != "my ldap" || got.NsdName != "openldap_ns" ||
+		got.VnfName != "openldap" || got.VimAccountName != "mylocation1" ||
+		got.Kdu.Name != "ldap" {
+		t.Errorf("want: ldap KduNsAction; got: %+v", got)
+	}
+	params, _ := got.Kdu.Params.(map[interface{}]interface{})
+	if params["replicaCount"] != "2" {
+		t.Errorf("want: replicaCount: 2; got: %v", got.Kdu.Params)
+	}
+
+	configData, _ := os.ReadFile(repo.Join(cfg.OpsConfigFileName).Value())
+	wantConfig := "connectionFile: " + DefaultConnectionFile + "\n"
+	if string(configData) != wantConfig {
+		t.Errorf("want: %s; got: %s", wantConfig, configData)
+	}
+}
+
+func TestExportSkipsWhatOsmOpsCantManage(t *testing.T) {
+	osm := ldapInventory(t)
+	osm.pkgs = append(osm.pkgs,
+		&nbic.PackageInfo{Kind: nbic.PackageKind.KNF, Id: "3", Name: "plain"},
+		&nbic.PackageInfo{Kind: nbic.PackageKind.NS, Id: "4", Name: "gone_ns"},
+		&nbic.PackageInfo{Kind: nbic.PackageKind.NS, Id: "5", Name: "text_ns"},
+		&nbic.PackageInfo{Kind: nbic.PackageKind.NS, Id: "6", Name: "bad_ns"},
+	)
+	osm.archives["5"] = []byte("nsd: {}")
+	osm.archives["6"] = tarArchive(map[string]string{
+		"bad_ns/nsd.yaml":      "nsd: {}",
+		"bad_ns/checksums.txt": "abc\tbad_ns/nsd.yaml\n",
+	})
+	osm.instances = append(osm.instances,
+		&nbic.NsInstanceInfo{Name: "dup", Kdus: osm.instances[0].Kdus},
+		&nbic.NsInstanceInfo{Name: "dup", Kdus: osm.instances[0].Kdus},
+		&nbic.NsInstanceInfo{Name: "no-kdu", NsdName: "n", VimAccountName: "v"},
+		&nbic.NsInstanceInfo{
+			Name: "two-kdus", NsdName: "n", VimAccountName: "v",
+			Kdus: []nbic.KduInfo{
+				{VnfName: "v", KduName: "k1"}, {VnfName: "v", KduName: "k2"},
+			},
+		},
+		&nbic.NsInstanceInfo{
+			Name: "no-vim", NsdName: "n", Kdus: osm.instances[0].Kdus,
+		},
+	)
+
+	repo := tempDir(t)
+	report, err := Export(context.TODO(), osm, repo)
+	if err != nil {
+		t.Fatalf("want: export; got: %v", err)
+	}
+
+	want := []string{
+		"NS instance dup", "NS instance dup", "NS instance no-kdu",
+		"NS instance no-vim", "NS instance two-kdus",
+		"package bad_ns", "package gone_ns", "package plain", "package text_ns",
+	}
+	if got := skippedNames(report); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	if len(report.Packages) != 2 || len(report.NsInstanceFiles) != 1 {
+		t.Errorf("want: ldap packages and NS instance; got: %+v", report)
+	}
+
+	entries, _ := os.ReadDir(repo.Join(cfg.OsmPackagesDirName).Value())
+	if len(entries) != 2 {
+		t.Errorf("want: only ldap package dirs; got: %v", entries)
+	}
+}
+
+func TestExportRefusesRepoWithOpsConfig(t *testing.T) {
+	repo := tempDir(t)
+	writeFile(t, repo.Join(cfg.OpsConfigFileName), "connectionFile: x\n")
+	osm := ldapInventory(t)
+	if _, err := Export(context.TODO(), osm, repo); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	if _, err := os.Stat(repo.Join(cfg.OsmPackagesDirName).Value()); err == nil {
+		t.Errorf("want: no package dir; got: package dir")
+	}
+}
+
+func TestExportRefusesInvalidTargetDir(t *testing.T) {
+	osm := ldapInventory(t)
+	_, err := Export(context.TODO(), osm, tempDir(t), WithTargetDir("   "))
+	if err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestExportListError(t *testing.T) {
+	repo := tempDir(t)
+	osm := ldapInventory(t)
+	osm.listErr = fmt.Errorf("NBI down")
+	if _, err := Export(context.TODO(), osm, repo); err != osm.listErr {
+		t.Errorf("want: %v; got: %v", osm.listErr, err)
+	}
+	if cfg.HasOpsConfig(repo) {
+		t.Errorf("want: no OpsConfig; got: OpsConfig")
+	}
+}
@@ -1,8 +1,11 @@
 package nbic
 
 import (
+	"context"
 	"errors"
+	"net/http"
 	"net/url"
+	"time"
 
 	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
 	. "github.com/fluxcd/source-watcher/osmops/util/http"
@@ -28,8 +31,9 @@ type authMan struct {
 }
 
 // NewAuthz builds a TokenManager to acquire and refresh OSM NBI access tokens.
-func NewAuthz(conn Connection, creds UserCredentials, transport ReqSender) (
-	*sec.TokenManager, error) {
+// Tokens get kept in memory unless you pass in a different store.
+func NewAuthz(conn Connection, creds UserCredentials, transport ReqSender,
+	store ...sec.TokenStore) (*sec.TokenManager, error) {
 	if transport == nil {
 		return nil, errors.New("nil transport")
 	}
@@ -40,10 +44,21 @@ func NewAuthz(conn Connection, creds UserCredentials, transport ReqSender) (
 		agent:    transport,
 	}
 
-	return sec.NewTokenManager(theMan.acquireToken, &sec.MemoryTokenStore{})
+	var tokens sec.TokenStore = &sec.MemoryTokenStore{}
+	if len(store) > 0 {
+		tokens = store[0]
+	}
+
+	return sec.NewTokenManager(theMan.acquireToken, tokens)
 }
 
+const LOGIN_TIMEOUT_SECONDS = 60
+
 func (m *authMan) acquireToken() (*sec.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Second*LOGIN_TIMEOUT_SECONDS) // (*)
+	defer cancel()
+
 	payload := tokenPayloadView{}
 	_, err := Request(
 		POST, At(m.endpoint),
@@ -51,11 +66,20 @@ func (m *authMan) acquireToken() (*sec.Token, error) {
 		Accept(MediaType.JSON),
 		JsonBody(m.creds),
 	).
-		SetHandler(ExpectSuccess(), ReadJsonResponse(&payload)).
-		RunWith(m.agent)
+		SetHandler(
+			ExpectNbiSuccess(http.MethodPost, m.endpoint),
+			ReadJsonResponse(&payload),
+		).
+		RunWith(ctx, m.agent)
 
 	if err != nil {
 		return nil, err
 	}
 	return sec.NewToken(payload.Id, payload.Expires), nil
+
+	// (*) login context. We don't use the context of the NBI call that
+	// triggered the login since the TokenManager shares the same login
+	// among concurrent calls---think single-flight refresh---so cancelling
+	// one call shouldn't fail the others. Hence logins get their own
+	// deadline.
 }
@@ -2,20 +2,30 @@
 package nbic
 
 import (
+	"context"
 	"crypto/tls"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 
 	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
 	. "github.com/fluxcd/source-watcher/osmops/util/http"
 	"github.com/fluxcd/source-watcher/osmops/util/http/sec"
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
 )
 
 // Workflow defines functions to carry out high-level tasks, usually involving
-// several NBI calls.
+// several NBI calls. Each task runs all its NBI calls with the given context,
+// so cancelling the context or letting its deadline expire aborts the task.
 type Workflow interface {
 	// CreateOrUpdateNsInstance creates or updates an NS instance in OSM
 	// through NBI.
@@ -30,7 +40,8 @@ type Workflow interface {
 	// For now we only support creating or updating KNFs. For a create or
 	// update operation to work, the target KNF must've been "on-boarded"
 	// in OSM already. So there must be, in OSM, a NSD and VNFD for it.
-	CreateOrUpdateNsInstance(data *NsInstanceContent) error
+	CreateOrUpdateNsInstance(ctx context.Context, data *NsInstanceContent) (
+		*Outcome, error)
 
 	// CreateOrUpdatePackage uploads the given package to OSM through NBI.
 	//
@@ -51,7 +62,70 @@ type Workflow interface {
 	// recursively, the files in source, creates a gzipped tar archive in
 	// the OSM format (including creating the "checksums.txt" file) and
 	// then streams it to OSM NBI to create or update the package in OSM.
-	CreateOrUpdatePackage(source file.AbsPath) error
+	// The opts tweak the way the archive gets built, e.g. which files to
+	// leave out. (See: pkgr.Pack) If OSM already has the package, then
+	// CreateOrUpdatePackage first downloads it and compares its files with
+	// those of the archive. If they're the same, it leaves the package
+	// alone and returns an Unchanged Outcome.
+	CreateOrUpdatePackage(ctx context.Context, source file.AbsPath,
+		opts ...pkgr.PackOption) (*Outcome, error)
+
+	// CreatePackageVersion uploads the given package to OSM through NBI
+	// unless OSM already has a package with the same ID. Package versions
+	// are immutable, so if the package is there, CreatePackageVersion
+	// downloads it and compares its files with those of the given package.
+	// If they're the same, it returns an Unchanged Outcome. Otherwise it
+	// errors out since the package changed without a version bump.
+	//
+	// CreatePackageVersion follows the same conventions CreateOrUpdatePackage
+	// does, except the package ID is the name of the package Pack builds.
+	// This way you can on-board a package under a versioned ID through
+	// pkgr.WithDescriptorIds. (See: pkgr.VersionedId)
+	CreatePackageVersion(ctx context.Context, source file.AbsPath,
+		opts ...pkgr.PackOption) (*Outcome, error)
+
+	// PrunePackageVersions deletes the oldest versions of the package with
+	// the given name, keeping the most recent ones. It looks at the VNFDs,
+	// if the name ends with "_knf", or the NSDs, if it ends with "_ns",
+	// whose IDs are versioned IDs of the package name and deletes all but
+	// the keep most recently created ones, if keep is positive. It never
+	// deletes the versions in keepIds. Nor can it delete versions an NS
+	// instance or another package still uses since OSM won't let it; it
+	// leaves those alone for the next time. PrunePackageVersions returns
+	// the versioned IDs of the packages it deleted.
+	PrunePackageVersions(ctx context.Context, name string, keep int,
+		keepIds ...string) ([]string, error)
+}
+
+// Inventory defines functions to read what's in OSM, e.g. to export an OSM
+// deployment to an OsmOps repo. Like Workflow, each function runs all its
+// NBI calls with the given context.
+type Inventory interface {
+	// ListPackages returns the VNF and NS packages on-boarded in OSM,
+	// VNF packages first.
+	ListPackages(ctx context.Context) ([]*PackageInfo, error)
+
+	// DownloadPackage streams the archive of the given package to sink.
+	// That's the archive as it was uploaded to OSM, so usually a gzipped
+	// tar in the OSM package format. (See: pkgr.Package)
+	DownloadPackage(ctx context.Context, pkg *PackageInfo,
+		sink io.Writer) error
+
+	// ListNsInstances returns the NS instances in OSM.
+	ListNsInstances(ctx context.Context) ([]*NsInstanceInfo, error)
+}
+
+// Outcome tells what a Workflow task did in OSM.
+type Outcome struct {
+	// Created is true if the task created a new OSM entity, false if it
+	// updated an existing one.
+	Created bool
+	// Id is the OSM ID of the entity the task created or updated---e.g.
+	// NS instance ID, VNFD ID, NSD ID.
+	Id string
+	// Unchanged is true if the task found the entity already in the state
+	// it wanted and didn't change anything in OSM. (Created is false then.)
+	Unchanged bool
 }
 
 const REQUEST_TIMEOUT_SECONDS = 600
@@ -78,17 +152,63 @@ type Session struct {
 	creds     UserCredentials
 	transport ReqSender
 	authz     *sec.TokenManager
+	lookup    u.EnumIx
 	nsdMap    nsDescMap
 	vnfdMap   vnfDescMap
 	vimAccMap vimAccountMap
 	nsInstMap nsInstanceMap
+
+	lookupTTL       time.Duration
+	lookupsExpireAt time.Time
 }
 
-func New(conn Connection, creds UserCredentials, transport ...ReqSender) (
-	*Session, error) {
+const (
+	CIRCUIT_BREAKER_FAILURES     = 5
+	CIRCUIT_BREAKER_OPEN_SECONDS = 30
+)
+
+// newTransport builds the ReqSender we use to talk to NBI when the caller
+// doesn't provide one. It retries idempotent requests on network errors
+// and 5xx gateway responses---OSM NBI tends to do that during upgrades---
+// and stops calling NBI for a while if it keeps on failing. If trace isn't
+// nil, it also logs each attempt. Each request, retries included, gets an
+// OpenTelemetry span which NBI can join through the W3C headers we add.
+func newTransport(trace *TraceOptions) ReqSender {
 	httpc := newHttpClient()
+	send := httpc.Do
+	if trace != nil {
+		send = Trace(send, *trace)
+	}
+	breaker := NewCircuitBreaker(CIRCUIT_BREAKER_FAILURES,
+		time.Second*CIRCUIT_BREAKER_OPEN_SECONDS)
+	send = Retry(breaker.Wrap(send), DefaultRetryPolicy())
+	return Instrument(send, nbiSpanAttributes)
+}
+
+func nbiSpanAttributes(req *http.Request) []attribute.KeyValue {
+	if req.URL == nil {
+		return nil
+	}
+	return []attribute.KeyValue{tracing.NbiEndpointKey.String(req.URL.Path)}
+}
+
+// NbiTraceOptions returns the options to trace NBI exchanges. On top of
+// the defaults, they hide the bodies of token requests and responses since
+// those carry user credentials and access tokens. Trace logs to the logger
+// in the request context if any, otherwise to the given logger.
+func NbiTraceOptions(log logr.Logger) TraceOptions {
+	opts := DefaultTraceOptions()
+	opts.Logger = log
+	opts.SecretBodies = func(req *http.Request) bool {
+		return req.URL != nil &&
+			strings.HasSuffix(req.URL.Path, tokensEndpointPath)
+	}
+	return opts
+}
 
-	agent := httpc.Do
+func New(conn Connection, creds UserCredentials, transport ...ReqSender) (
+	*Session, error) {
+	agent := newTransport(nil)
 	if len(transport) > 0 {
 		agent = transport[0]
 	}
@@ -98,12 +218,23 @@ func New(conn Connection, creds UserCredentials, transport ...ReqSender) (
 		return nil, err
 	}
 
-	return &Session{
-		conn:      conn,
-		creds:     creds,
-		transport: agent,
-		authz:     authz,
-	}, nil
+	return newSession(conn, creds, agent, authz), nil
+}
+
+func newSession(conn Connection, creds UserCredentials, agent ReqSender,
+	authz *sec.TokenManager) *Session {
+	session := &Session{
+		conn:  conn,
+		creds: creds,
+		authz: authz,
+	}
+	session.transport = ReAuthenticate(agent, authz.ClearAccessToken,
+		session.NbiAccessToken()) // (*)
+	return session
+
+	// (*) OSM revokes tokens when it restarts or an admin logs the user out.
+	// If that happens, we get a 401 even if the token hasn't expired yet, so
+	// we log in again and replay the request.
 }
 
 func (c *Session) NbiAccessToken() ReqBuilder {
@@ -117,19 +248,22 @@ func (c *Session) NbiAccessToken() ReqBuilder {
 	return BearerToken(provider)
 }
 
-func (c *Session) getJson(endpoint *url.URL, data interface{}) (
-	*http.Response, error) {
+func (c *Session) getJson(ctx context.Context, endpoint *url.URL,
+	data interface{}) (*http.Response, error) {
 	return Request(
 		GET, At(endpoint),
 		c.NbiAccessToken(),
 		Accept(MediaType.JSON),
 	).
-		SetHandler(ExpectSuccess(), ReadJsonResponse(data)).
-		RunWith(c.transport)
+		SetHandler(
+			ExpectNbiSuccess(http.MethodGet, endpoint),
+			ReadJsonResponse(data),
+		).
+		RunWith(ctx, c.transport)
 }
 
-func (c *Session) postJson(endpoint *url.URL, inData interface{},
-	outData ...interface{}) (*http.Response, error) {
+func (c *Session) postJson(ctx context.Context, endpoint *url.URL,
+	inData interface{}, outData ...interface{}) (*http.Response, error) {
 	req := Request(
 		POST, At(endpoint),
 		c.NbiAccessToken(),
@@ -137,8 +271,9 @@ func (c *Session) postJson(endpoint *url.URL, inData interface{},
 		Content(MediaType.YAML), // same as what OSM client does
 		JsonBody(inData),
 	)
+	handlers := []ResHandler{ExpectNbiSuccess(http.MethodPost, endpoint)}
 	if len(outData) > 0 {
-		req.SetHandler(ExpectSuccess(), ReadJsonResponse(outData[0]))
+		handlers = append(handlers, ReadJsonResponse(outData[0]))
 	}
-	return req.RunWith(c.transport)
+	return req.SetHandler(handlers...).RunWith(ctx, c.transport)
 }
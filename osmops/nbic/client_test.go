@@ -1,6 +1,8 @@
 package nbic
 
 import (
+	"context"
+	"net/http"
 	"testing"
 )
 
@@ -25,7 +27,7 @@ func TestGetJsonStopIfResponseNotOkay(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
-	if _, err := nbic.getJson(urls.buildUrl("/wrong"), nil); err == nil {
+	if _, err := nbic.getJson(context.TODO(), urls.buildUrl("/wrong"), nil); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -35,7 +37,60 @@ func TestPostJsonStopIfResponseNotOkay(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
-	if _, err := nbic.postJson(urls.buildUrl("/wrong"), "42", nil); err == nil {
+	if _, err := nbic.postJson(context.TODO(), urls.buildUrl("/wrong"), "42", nil); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
+
+func TestSessionLogsInAgainOnRevokedToken(t *testing.T) {
+	nbi := newMockNbi()
+	revoked := true
+	getNsds := nbi.handlers[handlerKey("GET", "/osm/nsd/v1/ns_descriptors")]
+	nbi.handlers[handlerKey("GET", "/osm/nsd/v1/ns_descriptors")] =
+		func(req *http.Request) (*http.Response, error) {
+			if revoked {
+				revoked = false
+				return &http.Response{StatusCode: http.StatusUnauthorized}, nil
+			}
+			return getNsds(req)
+		}
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err != nil {
+		t.Fatalf("want: NSD ID; got: %v", err)
+	}
+
+	logins := 0
+	for _, rr := range nbi.exchanges {
+		if rr.req.URL.Path == urls.Tokens().Path {
+			logins += 1
+		}
+	}
+	if logins != 2 {
+		t.Errorf("want: 2 logins; got: %d", logins)
+	}
+}
+
+func TestWorkflowStopsOnCancelledContext(t *testing.T) {
+	nbi := newMockNbi()
+	var got context.Context
+	send := func(req *http.Request) (*http.Response, error) {
+		got = req.Context()
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return nbi.exchange(req)
+	}
+	nbic, _ := New(newConn(), usrCreds, send)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	data := &NsInstanceContent{Name: "ldap"}
+	if _, err := nbic.CreateOrUpdateNsInstance(ctx, data); err == nil {
+		t.Errorf("want: context error; got: nil")
+	}
+	if got == nil || got.Err() == nil {
+		t.Errorf("want: NBI call bound to cancelled ctx; got: %v", got)
+	}
+}
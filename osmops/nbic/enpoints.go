@@ -32,9 +32,11 @@ func (b Connection) buildUrl(path string) *url.URL {
 // URL functions below in our unit tests, we can be sure the panic won't
 // happen at runtime.
 
+const tokensEndpointPath = "/osm/admin/v1/tokens"
+
 // Tokens returns the URL to the NBI tokens endpoint.
 func (b Connection) Tokens() *url.URL {
-	return b.buildUrl("/osm/admin/v1/tokens")
+	return b.buildUrl(tokensEndpointPath)
 }
 
 // NsDescriptors returns the URL to the NBI NS descriptors endpoint.
@@ -82,3 +84,17 @@ func (b Connection) NsPackageContent(pkgId string) *url.URL {
 	path := fmt.Sprintf("/osm/nsd/v1/ns_descriptors_content/%s", pkgId)
 	return b.buildUrl(path)
 }
+
+// VnfPackageArchive returns the URL to download the archive of the VNF
+// package identified by the given ID.
+func (b Connection) VnfPackageArchive(pkgId string) *url.URL {
+	path := fmt.Sprintf("/osm/vnfpkgm/v1/vnf_packages/%s/package_content", pkgId)
+	return b.buildUrl(path)
+}
+
+// NsPackageArchive returns the URL to download the archive of the NS
+// package identified by the given ID.
+func (b Connection) NsPackageArchive(pkgId string) *url.URL {
+	path := fmt.Sprintf("/osm/nsd/v1/ns_descriptors/%s/nsd_content", pkgId)
+	return b.buildUrl(path)
+}
@@ -0,0 +1,122 @@
+package nbic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
+	. "github.com/fluxcd/source-watcher/osmops/util/http"
+)
+
+// NbiError is the error we return when NBI replies with a status code
+// outside of the 2xx range. Along with the request method and URL, it
+// holds the problem details OSM puts in the response body, e.g.
+//
+//     {
+//         "code": "CONFLICT",
+//         "status": 409,
+//         "detail": "nsd with id 'openldap_ns' already exists for this project"
+//     }
+//
+// Use errors.As to get hold of it:
+//
+//     var nbiErr *NbiError
+//     if errors.As(err, &nbiErr) && nbiErr.StatusCode == http.StatusConflict {
+//         ...
+//     }
+type NbiError struct {
+	// Method is the HTTP method of the failed request.
+	Method string
+	// Url is the URL of the failed request.
+	Url string
+	// StatusCode is the HTTP status code NBI replied with, e.g. 409.
+	StatusCode int
+	// Status is the HTTP status line NBI replied with, e.g. "409 Conflict".
+	Status string
+	// Code is the OSM error code, e.g. "CONFLICT". Empty if the response
+	// body didn't contain any OSM problem details.
+	Code string
+	// Detail is OSM's explanation of what went wrong. If the response body
+	// didn't contain any OSM problem details, Detail holds the first few
+	// hundred characters of the body, if any.
+	Detail string
+}
+
+func (e *NbiError) Error() string {
+	msg := fmt.Sprintf("NBI %s %s: %s", e.Method, e.Url, e.Status)
+	if e.Code != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Code)
+	}
+	if e.Detail != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Detail)
+	}
+	return msg
+}
+
+type nbiProblemView struct { // only the response fields we care about.
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+const (
+	maxProblemBodySize = 64 * 1024
+	maxRawDetailSize   = 512
+)
+
+func newNbiError(method string, endpoint *url.URL,
+	res *http.Response) *NbiError {
+	nbiErr := &NbiError{
+		Method:     method,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+	}
+	if endpoint != nil {
+		nbiErr.Url = endpoint.String()
+	}
+	if nbiErr.Status == "" {
+		nbiErr.Status = fmt.Sprintf("%d %s", res.StatusCode,
+			http.StatusText(res.StatusCode))
+	}
+	if res.Body == nil {
+		return nbiErr
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxProblemBodySize))
+	problem := nbiProblemView{}
+	if err := json.Unmarshal(body, &problem); err == nil &&
+		(problem.Code != "" || problem.Detail != "") {
+		nbiErr.Code, nbiErr.Detail = problem.Code, problem.Detail
+		return nbiErr
+	}
+
+	detail := strings.TrimSpace(string(body))
+	if len(detail) > maxRawDetailSize {
+		detail = detail[:maxRawDetailSize] + "..."
+	}
+	nbiErr.Detail = detail
+	return nbiErr
+}
+
+type expectNbiSuccess struct {
+	method   string
+	endpoint *url.URL
+}
+
+func (e expectNbiSuccess) Handle(res *http.Response) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return newNbiError(e.method, e.endpoint, res)
+	}
+	return nil
+}
+
+// ExpectNbiSuccess builds a ResHandler to check for successful NBI responses.
+// It works like ExpectSuccess except the error it returns is an NbiError
+// with the given request method and URL. Since the response doesn't always
+// link back to the request, you have to pass in method and URL.
+func ExpectNbiSuccess(method string, endpoint *url.URL) ResHandler {
+	return expectNbiSuccess{method: method, endpoint: endpoint}
+}
@@ -0,0 +1,107 @@
+package nbic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNbiErrorWithProblemDetails(t *testing.T) {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("GET", "/osm/nsd/v1/ns_descriptors")] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusConflict,
+				Status:     "409 Conflict",
+				Body: stringReader(`{
+					"code": "CONFLICT",
+					"status": 409,
+					"detail": "nsd with id 'openldap_ns' already exists"
+				}`),
+			}, nil
+		}
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+
+	_, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns")
+
+	var nbiErr *NbiError
+	if !errors.As(err, &nbiErr) {
+		t.Fatalf("want: NbiError; got: %v", err)
+	}
+	want := NbiError{
+		Method:     http.MethodGet,
+		Url:        withFilter(urls.NsDescriptors(), "id", "openldap_ns").String(),
+		StatusCode: http.StatusConflict,
+		Status:     "409 Conflict",
+		Code:       "CONFLICT",
+		Detail:     "nsd with id 'openldap_ns' already exists",
+	}
+	if *nbiErr != want {
+		t.Errorf("want: %+v; got: %+v", want, *nbiErr)
+	}
+	if !strings.Contains(err.Error(), "CONFLICT") {
+		t.Errorf("want: code in message; got: %s", err)
+	}
+}
+
+var nbiErrorBodyFixtures = []struct {
+	body       string
+	wantDetail string
+}{
+	{"", ""},
+	{"  not json  ", "not json"},
+	{`{"other": "json"}`, `{"other": "json"}`},
+	{strings.Repeat("x", maxRawDetailSize+1),
+		strings.Repeat("x", maxRawDetailSize) + "..."},
+}
+
+func TestNbiErrorWithoutProblemDetails(t *testing.T) {
+	for k, d := range nbiErrorBodyFixtures {
+		res := &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       stringReader(d.body),
+		}
+		err := ExpectNbiSuccess(http.MethodPut, nil).Handle(res)
+
+		var nbiErr *NbiError
+		if !errors.As(err, &nbiErr) {
+			t.Fatalf("[%d] want: NbiError; got: %v", k, err)
+		}
+		if nbiErr.Code != "" || nbiErr.Detail != d.wantDetail {
+			t.Errorf("[%d] want: %s; got: %+v", k, d.wantDetail, nbiErr)
+		}
+		if nbiErr.Status != "500 Internal Server Error" {
+			t.Errorf("[%d] want: status text; got: %s", k, nbiErr.Status)
+		}
+	}
+}
+
+func TestExpectNbiSuccessLetsSuccessThrough(t *testing.T) {
+	for _, code := range []int{200, 201, 204, 299} {
+		res := &http.Response{StatusCode: code}
+		if err := ExpectNbiSuccess(http.MethodGet, nil).Handle(res); err != nil {
+			t.Errorf("[%d] want: nil; got: %v", code, err)
+		}
+	}
+}
+
+func TestNbiErrorOnNsInstanceUpdate(t *testing.T) {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("POST",
+		"/osm/nslcm/v1/ns_instances/0335c32c-d28c-4d79-9b94-0ffa36326932/action")] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusUnprocessableEntity}, nil
+		}
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	_, err := nbic.updateNsInstance(context.TODO(),
+		"0335c32c-d28c-4d79-9b94-0ffa36326932", &NsInstanceContent{})
+	var nbiErr *NbiError
+	if !errors.As(err, &nbiErr) ||
+		nbiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("want: 422 NbiError; got: %v", err)
+	}
+}
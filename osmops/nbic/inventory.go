@@ -0,0 +1,193 @@
+package nbic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+
+	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
+	. "github.com/fluxcd/source-watcher/osmops/util/http"
+)
+
+// PackageKind enumerates the kinds of OSM packages an Inventory knows about.
+// The labels are the same as the package directory suffixes OsmOps uses.
+// (See: Workflow.CreateOrUpdatePackage)
+var PackageKind = struct {
+	u.StrEnum
+	KNF, NS u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("knf", "ns"),
+	KNF:     0,
+	NS:      1,
+}
+
+// PackageInfo describes a package on-boarded in OSM.
+type PackageInfo struct {
+	// Tells if it's a VNF or NS package. (See: PackageKind)
+	Kind u.EnumIx
+	// The OSM ID of the package, e.g. "4ffdeb67-92e7-46fa-9fa2-331a4d674137".
+	Id string
+	// The ID of the VNFD or NSD in the package, e.g. "openldap_knf".
+	Name string
+}
+
+// KduInfo describes a KDU deployed in an NS instance.
+type KduInfo struct {
+	// The name of the VNF the KDU belongs to, i.e. its member VNF index.
+	VnfName string
+	// The name of the KDU as specified in the VNFD.
+	KduName string
+	// The KDU parameters the NS instance got instantiated with, if any.
+	Params interface{}
+}
+
+// NsInstanceInfo describes an NS instance in OSM.
+type NsInstanceInfo struct {
+	// The OSM ID of the NS instance.
+	Id string
+	// The name of the NS instance. Notice OSM doesn't enforce uniqueness
+	// of NS instance names.
+	Name string
+	// Short description of the NS instance.
+	Description string
+	// The name of the NSD that defines the NS instance.
+	NsdName string
+	// The name of the VIM account the NS instance got created with.
+	VimAccountName string
+	// The KDUs in the NS instance.
+	Kdus []KduInfo
+}
+
+type nsInstanceDetailView struct { // only the response fields we care about.
+	Id                string           `json:"_id"`
+	Name              string           `json:"name"`
+	Description       string           `json:"description"`
+	NsdName           string           `json:"nsd-name-ref"`
+	InstantiateParams nsInstContentDto `json:"instantiate_params"`
+	Admin             struct {
+		Deployed struct {
+			K8s []deployedKduView `json:"K8s"`
+		} `json:"deployed"`
+	} `json:"_admin"`
+}
+
+type deployedKduView struct { // only the response fields we care about.
+	MemberVnfIndex string `json:"member-vnf-index"`
+	KduName        string `json:"kdu-name"`
+}
+
+func (c *Session) ListPackages(ctx context.Context) ([]*PackageInfo, error) {
+	vnfds, err := c.getVnfDescriptors(ctx, c.conn.VnfPackagesContent())
+	if err != nil {
+		return nil, err
+	}
+	nsds, err := c.getNsDescriptors(ctx, c.conn.NsDescriptors())
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := []*PackageInfo{}
+	for _, d := range vnfds {
+		pkgs = append(pkgs, &PackageInfo{
+			Kind: PackageKind.KNF, Id: d.Id, Name: d.Name,
+		})
+	}
+	for _, d := range nsds {
+		pkgs = append(pkgs, &PackageInfo{
+			Kind: PackageKind.NS, Id: d.Id, Name: d.Name,
+		})
+	}
+	return pkgs, nil
+}
+
+func (c *Session) DownloadPackage(ctx context.Context, pkg *PackageInfo,
+	sink io.Writer) error {
+	if pkg == nil {
+		return fmt.Errorf("nil package")
+	}
+	endpoint := c.conn.VnfPackageArchive(pkg.Id)
+	if pkg.Kind == PackageKind.NS {
+		endpoint = c.conn.NsPackageArchive(pkg.Id)
+	}
+
+	_, err := Request(
+		GET, At(endpoint),
+		c.NbiAccessToken(),
+		Accept(MediaType.ZIP, MediaType.GZIP), // (*)
+	).
+		SetHandler(
+			ExpectNbiSuccess(http.MethodGet, endpoint),
+			CopyResponse(sink),
+		).
+		RunWith(ctx, c.transport)
+	return err
+
+	// (*) NBI only hands out the archive of a multi-file package if the
+	// client accepts zip, whatever the actual archive format. Otherwise
+	// it refuses to send back anything but a single descriptor file.
+}
+
+func (c *Session) ListNsInstances(ctx context.Context) (
+	[]*NsInstanceInfo, error) {
+	accounts, err := c.getVimAccounts(ctx, c.conn.VimAccounts())
+	if err != nil {
+		return nil, err
+	}
+	accountNames := map[string]string{}
+	for _, a := range accounts {
+		accountNames[a.Id] = a.Name
+	}
+
+	vs := []nsInstanceDetailView{}
+	if err := c.getJsonList(ctx, c.conn.NsInstancesContent(), &vs); err != nil {
+		return nil, err
+	}
+
+	instances := []*NsInstanceInfo{}
+	for _, v := range vs {
+		description := v.InstantiateParams.NsDescription
+		if description == "" {
+			description = v.Description
+		}
+		instances = append(instances, &NsInstanceInfo{
+			Id:             v.Id,
+			Name:           v.Name,
+			Description:    description,
+			NsdName:        v.NsdName,
+			VimAccountName: accountNames[v.InstantiateParams.VimAccountId],
+			Kdus:           v.kdus(),
+		})
+	}
+	return instances, nil
+}
+
+// kdus merges the KDUs NBI says it deployed with those the instance got
+// parameters for. Notice the parameters are those given on instantiation,
+// NBI doesn't update them when you run an upgrade action.
+func (v *nsInstanceDetailView) kdus() []KduInfo {
+	kdus := []KduInfo{}
+	positions := map[string]int{}
+	lookup := func(vnfName, kduName string) int {
+		key := vnfName + "/" + kduName
+		if k, ok := positions[key]; ok {
+			return k
+		}
+		kdus = append(kdus, KduInfo{VnfName: vnfName, KduName: kduName})
+		positions[key] = len(kdus) - 1
+		return len(kdus) - 1
+	}
+
+	for _, d := range v.Admin.Deployed.K8s {
+		lookup(d.MemberVnfIndex, d.KduName)
+	}
+	for _, vnf := range v.InstantiateParams.AdditionalParamsForVnf {
+		for _, kdu := range vnf.AdditionalParamsForKdu {
+			k := lookup(vnf.MemberVnfIndex, kdu.KduName)
+			kdus[k].Params = kdu.AdditionalParams
+		}
+	}
+	return kdus
+}
@@ -0,0 +1,173 @@
+package nbic
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestListPackages(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	pkgs, err := nbic.ListPackages(context.TODO())
+	if err != nil {
+		t.Fatalf("want: packages; got: %v", err)
+	}
+	want := []PackageInfo{
+		{PackageKind.KNF, "4ffdeb67-92e7-46fa-9fa2-331a4d674137", "openldap_knf"},
+		{PackageKind.KNF, "5ccfed39-92e7-46fa-9fa2-331a4d674137", "dummy_knf"},
+		{PackageKind.NS, "aba58e40-d65f-4f4e-be0a-e248c14d3e03", "openldap_ns"},
+		{PackageKind.NS, "ddd20a30-d65f-4f4e-be0a-e248c14d3e03", "dummy_ns"},
+	}
+	if len(pkgs) != len(want) {
+		t.Fatalf("want: %d packages; got: %d", len(want), len(pkgs))
+	}
+	for k, p := range pkgs {
+		if *p != want[k] {
+			t.Errorf("[%d] want: %+v; got: %+v", k, want[k], *p)
+		}
+	}
+}
+
+func TestListPackagesTokenError(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), UserCredentials{}, nbi.exchange)
+
+	if _, err := nbic.ListPackages(context.TODO()); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestDownloadPackage(t *testing.T) {
+	nbi := newMockNbi()
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+
+	pkg := &PackageInfo{Kind: PackageKind.NS, Id: "aba58e40", Name: "openldap_ns"}
+	endpoint := urls.NsPackageArchive(pkg.Id)
+	nbi.handlers[handlerKey("GET", endpoint.Path)] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       stringReader("tarball"),
+			}, nil
+		}
+
+	sink := &bytes.Buffer{}
+	if err := nbic.DownloadPackage(context.TODO(), pkg, sink); err != nil {
+		t.Fatalf("want: download; got: %v", err)
+	}
+	if sink.String() != "tarball" {
+		t.Errorf("want: tarball; got: %s", sink.String())
+	}
+
+	rr := nbi.exchanges[len(nbi.exchanges)-1]
+	if rr.req.URL.Path != endpoint.Path {
+		t.Errorf("want: %s; got: %s", endpoint.Path, rr.req.URL.Path)
+	}
+	wantAccept := "application/zip, application/gzip"
+	if got := rr.req.Header.Get("Accept"); got != wantAccept {
+		t.Errorf("want: %s; got: %s", wantAccept, got)
+	}
+}
+
+func TestDownloadPackageNbiError(t *testing.T) {
+	nbi := newMockNbi()
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+
+	pkg := &PackageInfo{Kind: PackageKind.KNF, Id: "4ffdeb67", Name: "openldap_knf"}
+	endpoint := urls.VnfPackageArchive(pkg.Id)
+	nbi.handlers[handlerKey("GET", endpoint.Path)] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotFound}, nil
+		}
+
+	err := nbic.DownloadPackage(context.TODO(), pkg, &bytes.Buffer{})
+	if !isNotFound(err) {
+		t.Errorf("want: not found; got: %v", err)
+	}
+}
+
+func TestDownloadPackageErrorOnNilPackage(t *testing.T) {
+	nbic := &Session{}
+	if err := nbic.DownloadPackage(context.TODO(), nil, &bytes.Buffer{}); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestListNsInstances(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	instances, err := nbic.ListNsInstances(context.TODO())
+	if err != nil {
+		t.Fatalf("want: instances; got: %v", err)
+	}
+	if len(instances) != 4 {
+		t.Fatalf("want: 4 instances; got: %d", len(instances))
+	}
+
+	want := NsInstanceInfo{
+		Id:             "0335c32c-d28c-4d79-9b94-0ffa36326932",
+		Name:           "ldap",
+		Description:    "default description",
+		NsdName:        "openldap_ns",
+		VimAccountName: "mylocation1",
+		Kdus: []KduInfo{
+			{
+				VnfName: "openldap",
+				KduName: "ldap",
+				Params: map[string]interface{}{
+					"replicaCount": "2",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(*instances[0], want) {
+		t.Errorf("want: %+v; got: %+v", want, *instances[0])
+	}
+
+	ldap2 := instances[1]
+	if len(ldap2.Kdus) != 1 || ldap2.Kdus[0].Params != nil {
+		t.Errorf("want: one KDU w/o params; got: %+v", ldap2.Kdus)
+	}
+	dup := instances[2]
+	if dup.Name != "dup-name" || len(dup.Kdus) != 0 || dup.VimAccountName != "" {
+		t.Errorf("want: dup-name w/o KDUs and VIM account; got: %+v", dup)
+	}
+}
+
+func TestNsInstanceKdusMergeDeployedAndParams(t *testing.T) {
+	v := nsInstanceDetailView{}
+	v.Admin.Deployed.K8s = []deployedKduView{
+		{MemberVnfIndex: "v1", KduName: "k1"},
+		{MemberVnfIndex: "v1", KduName: "k2"},
+	}
+	v.InstantiateParams.AdditionalParamsForVnf = []additionalParamsForVnfDto{
+		{
+			MemberVnfIndex: "v1",
+			AdditionalParamsForKdu: []additionalParamsForKduDto{
+				{KduName: "k2", AdditionalParams: 2},
+			},
+		},
+		{
+			MemberVnfIndex: "v2",
+			AdditionalParamsForKdu: []additionalParamsForKduDto{
+				{KduName: "k1", AdditionalParams: 3},
+			},
+		},
+	}
+
+	want := []KduInfo{
+		{VnfName: "v1", KduName: "k1"},
+		{VnfName: "v1", KduName: "k2", Params: 2},
+		{VnfName: "v2", KduName: "k1", Params: 3},
+	}
+	if got := v.kdus(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want: %+v; got: %+v", want, got)
+	}
+}
@@ -0,0 +1,118 @@
+package nbic
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// NOTE. Lookup map consistency.
+// A Session caches the OSM IDs it looks up (see: Lookup) and we have to
+// keep those maps in line with what's in OSM, otherwise we could end up
+// making the wrong call. E.g. if we didn't record the NS instance we've
+// just created, in CACHED mode a second GitOps file for the same instance
+// would miss the map and make us POST a duplicate---OSM happily accepts
+// duplicate NS names. So each Workflow task updates the maps as it goes:
+// it adds the entities it creates and drops the entities NBI says aren't
+// there anymore (404 Not Found), e.g. because someone deleted them behind
+// our back. On top of that, a long-lived Session can set a TTL so it
+// refetches everything every so often and picks up any other changes.
+// Sessions from a SessionCache get the TTL the cache was configured with,
+// i.e. the "--nbi-lookup-ttl" flag for the DefaultSessionCache.
+
+// timeNow tells the time; tests can swap it out for a fake clock.
+var timeNow = time.Now
+
+// SetLookupTTL makes the Session forget all the OSM IDs it looked up once
+// the given time has elapsed since it started filling its lookup maps, so
+// the next lookups fetch fresh data from NBI. A zero TTL, the default,
+// means the maps never expire.
+func (c *Session) SetLookupTTL(ttl time.Duration) {
+	c.lookupTTL = ttl
+	c.lookupsExpireAt = time.Time{}
+}
+
+// InvalidateLookups makes the Session forget all the OSM IDs it looked up.
+func (c *Session) InvalidateLookups() {
+	c.nsdMap = nil
+	c.vnfdMap = nil
+	c.vimAccMap = nil
+	c.nsInstMap = nil
+	c.lookupsExpireAt = time.Time{}
+}
+
+// expireLookups clears the lookup maps if their TTL has elapsed. Call it
+// before each lookup.
+func (c *Session) expireLookups() {
+	if c.lookupTTL <= 0 {
+		return
+	}
+	now := timeNow()
+	if !c.lookupsExpireAt.IsZero() && now.After(c.lookupsExpireAt) {
+		c.InvalidateLookups()
+	}
+	if c.lookupsExpireAt.IsZero() {
+		c.lookupsExpireAt = now.Add(c.lookupTTL)
+	}
+}
+
+// canRemember tells if we can add an entry to a lookup map. In CACHED mode,
+// a nil map means we haven't fetched the collection yet; if we added an
+// entry, we'd then take that one entry as the whole collection.
+func (c *Session) canRemember(notFetchedYet bool) bool {
+	return c.lookup != Lookup.CACHED || !notFetchedYet
+}
+
+func (c *Session) rememberNsDescriptor(name string, id string) {
+	if c.canRemember(c.nsdMap == nil) {
+		if c.nsdMap == nil {
+			c.nsdMap = nsDescMap{}
+		}
+		c.nsdMap[name] = id
+	}
+}
+
+func (c *Session) forgetNsDescriptor(name string) {
+	delete(c.nsdMap, name)
+}
+
+func (c *Session) rememberVnfDescriptor(name string, id string) {
+	if c.canRemember(c.vnfdMap == nil) {
+		if c.vnfdMap == nil {
+			c.vnfdMap = vnfDescMap{}
+		}
+		c.vnfdMap[name] = id
+	}
+}
+
+func (c *Session) forgetVnfDescriptor(name string) {
+	delete(c.vnfdMap, name)
+}
+
+func (c *Session) rememberNsInstance(name string, id string) {
+	if c.canRemember(c.nsInstMap == nil) {
+		if c.nsInstMap == nil {
+			c.nsInstMap = nsInstanceMap{}
+		}
+		if !c.nsInstMap.hasMapping(name, id) {
+			c.nsInstMap.addMapping(name, id)
+		}
+	}
+}
+
+func (c *Session) forgetNsInstance(name string, id string) {
+	c.nsInstMap.removeMapping(name, id)
+}
+
+// isConflict tells if err comes from NBI replying 409 Conflict, e.g. when
+// deleting a package an NS instance still uses.
+func isConflict(err error) bool {
+	var nbiErr *NbiError
+	return errors.As(err, &nbiErr) && nbiErr.StatusCode == http.StatusConflict
+}
+
+// isNotFound tells if err comes from NBI replying 404 Not Found.
+func isNotFound(err error) bool {
+	var nbiErr *NbiError
+	return errors.As(err, &nbiErr) && nbiErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,171 @@
+package nbic
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+)
+
+func newNsData(name string) *NsInstanceContent {
+	return &NsInstanceContent{
+		Name:           name,
+		Description:    "wada wada",
+		NsdName:        "openldap_ns",
+		VimAccountName: "mylocation1",
+	}
+}
+
+func countRequests(nbi *mockNbi, method string, path string) int {
+	count := 0
+	for _, rr := range nbi.exchanges {
+		if rr.req.Method == method && rr.req.URL.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func TestCreatedNsInstanceGetsUpdatedNextTime(t *testing.T) {
+	for _, mode := range []u.EnumIx{Lookup.FILTERED, Lookup.CACHED} {
+		nbi := newMockNbi()
+		urls := newConn()
+		actionPath := urls.NsInstancesAction(createdNsInstanceId).Path
+		nbi.handlers[handlerKey("POST", actionPath)] = nsInstActionHandler
+		nbi.handlers[handlerKey("GET", "/osm/nslcm/v1/ns_instances_content")] =
+			func(req *http.Request) (*http.Response, error) {
+				return &http.Response{ // (*)
+					StatusCode: http.StatusOK,
+					Body:       stringReader("[]"),
+				}, nil
+			}
+		nbic, _ := New(urls, usrCreds, nbi.exchange)
+		nbic.UseLookup(mode)
+
+		first, err := nbic.CreateOrUpdateNsInstance(context.TODO(), newNsData("new"))
+		if err != nil || !first.Created {
+			t.Fatalf("[%d] want: create; got: %v, %v", mode, first, err)
+		}
+		second, err := nbic.CreateOrUpdateNsInstance(context.TODO(), newNsData("new"))
+		if err != nil || second.Created || second.Id != createdNsInstanceId {
+			t.Fatalf("[%d] want: update; got: %v, %v", mode, second, err)
+		}
+
+		creates := countRequests(nbi, "POST", urls.NsInstancesContent().Path)
+		if creates != 1 {
+			t.Errorf("[%d] want: 1 create; got: %d", mode, creates)
+		}
+		if updates := countRequests(nbi, "POST", actionPath); updates != 1 {
+			t.Errorf("[%d] want: 1 update; got: %d", mode, updates)
+		}
+	}
+
+	// (*) NBI takes a while to list a new instance, make sure we don't
+	// rely on it.
+}
+
+func TestUpdateOfDeletedNsInstanceForgetsIt(t *testing.T) {
+	nbi := newMockNbi()
+	urls := newConn()
+	nsId := "0335c32c-d28c-4d79-9b94-0ffa36326932"
+	nbi.handlers[handlerKey("POST", urls.NsInstancesAction(nsId).Path)] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotFound}, nil
+		}
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+	nbic.UseLookup(Lookup.CACHED)
+
+	if _, err := nbic.CreateOrUpdateNsInstance(context.TODO(), newNsData("ldap")); !isNotFound(err) {
+		t.Fatalf("want: not found error; got: %v", err)
+	}
+	if ids, ok := nbic.nsInstMap["ldap"]; ok {
+		t.Errorf("want: ldap forgotten; got: %v", ids)
+	}
+}
+
+func TestCreatedPackageGetsRemembered(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	pkgSrc := findTestDataDir("create_knf")
+	if _, err := nbic.CreateOrUpdatePackage(context.TODO(), pkgSrc); err != nil {
+		t.Fatalf("want: create; got: %v", err)
+	}
+	if got := nbic.vnfdMap["create_knf"]; got != "create_knf" {
+		t.Errorf("want: create_knf; got: %s", got)
+	}
+}
+
+func TestCachedModeDoesntRememberBeforeFetching(t *testing.T) {
+	nbic := &Session{lookup: Lookup.CACHED}
+
+	nbic.rememberNsDescriptor("ns", "1")
+	nbic.rememberVnfDescriptor("knf", "2")
+	nbic.rememberNsInstance("ldap", "3")
+
+	if nbic.nsdMap != nil || nbic.vnfdMap != nil || nbic.nsInstMap != nil {
+		t.Errorf("want: no maps; got: %v, %v, %v",
+			nbic.nsdMap, nbic.vnfdMap, nbic.nsInstMap)
+	}
+}
+
+func TestForgetNsInstanceKeepsOtherIds(t *testing.T) {
+	nbic := &Session{
+		nsInstMap: nsInstanceMap{"ldap": {"1", "2"}},
+	}
+	nbic.rememberNsInstance("ldap", "2")
+	nbic.forgetNsInstance("ldap", "1")
+	if ids := nbic.nsInstMap["ldap"]; len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("want: [2]; got: %v", ids)
+	}
+}
+
+func TestLookupMapsExpireAfterTtl(t *testing.T) {
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	nbi := newMockNbi()
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+	nbic.UseLookup(Lookup.CACHED)
+	nbic.SetLookupTTL(time.Minute)
+
+	lookup := func() {
+		if _, err := nbic.lookupVimAccountId(context.TODO(), "mylocation1"); err != nil {
+			t.Fatalf("want: id; got: %v", err)
+		}
+	}
+	fetches := func() int {
+		return countRequests(nbi, "GET", urls.VimAccounts().Path)
+	}
+
+	lookup()
+	now = now.Add(time.Minute)
+	lookup()
+	if got := fetches(); got != 1 {
+		t.Errorf("want: 1 fetch within TTL; got: %d", got)
+	}
+
+	now = now.Add(time.Second)
+	lookup()
+	if got := fetches(); got != 2 {
+		t.Errorf("want: 2 fetches after TTL; got: %d", got)
+	}
+}
+
+func TestInvalidateLookups(t *testing.T) {
+	nbic := &Session{
+		nsdMap:    nsDescMap{"ns": "1"},
+		vnfdMap:   vnfDescMap{"knf": "2"},
+		vimAccMap: vimAccountMap{"vim": "3"},
+		nsInstMap: nsInstanceMap{"ldap": {"4"}},
+	}
+	nbic.InvalidateLookups()
+	if nbic.nsdMap != nil || nbic.vnfdMap != nil || nbic.vimAccMap != nil ||
+		nbic.nsInstMap != nil {
+		t.Errorf("want: no maps; got: %+v", nbic)
+	}
+}
@@ -0,0 +1,144 @@
+package nbic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+)
+
+// Lookup enumerates the ways a Session can find the OSM ID of an entity
+// (NSD, VNFD, VIM account, NS instance) given its name.
+//
+// With FILTERED, each lookup asks NBI for the entities with that name
+// through a query filter, e.g. `ns_instances_content?name=ldap`, and the
+// Session remembers the IDs it got back. This is the default since it
+// scales to OSM deployments with thousands of entities.
+//
+// With CACHED, the first lookup fetches the whole collection, page by page,
+// and the Session answers any further lookup from the resulting map. This
+// is what you want when there's just a handful of entities and a reconcile
+// involves lots of lookups.
+var Lookup = struct {
+	u.StrEnum
+	FILTERED, CACHED u.EnumIx
+}{
+	StrEnum:  u.NewStrEnum("filtered", "cached"),
+	FILTERED: 0,
+	CACHED:   1,
+}
+
+// NOTE. NBI query filters and paging.
+// NBI lets you filter any collection by field value through query params,
+// e.g. `GET /osm/nsd/v1/ns_descriptors?id=openldap_ns` returns the NSDs
+// whose name ID is `openldap_ns`. (Careful: descriptors keep their OSM ID
+// in `_id` and their name ID in `id`.) As for paging, SOL013 has the server
+// decide the page size and return a `Link` header pointing to the next page,
+// e.g.
+//
+// Link: <https://nbi/osm/nslcm/v1/ns_instances_content?nextpage_opaque_marker=abc>; rel="next"
+//
+// So we keep on following `next` links until there's none left. OSM NBI
+// doesn't page collections at the moment, so in practice we only ever GET
+// one page, but we're ready for when it does.
+
+// UseLookup sets how the Session looks up OSM IDs, either Lookup.FILTERED
+// or Lookup.CACHED. It returns an error if mode is neither. Call it before
+// running any Workflow task, since switching mode halfway through could
+// leave the Session with a partial map it then takes as the full picture.
+func (c *Session) UseLookup(mode u.EnumIx) error {
+	if mode != Lookup.FILTERED && mode != Lookup.CACHED {
+		return fmt.Errorf("unknown lookup mode: %d", mode)
+	}
+	c.lookup = mode
+	return nil
+}
+
+// mustFetch tells if a lookup that missed the Session's map should go to
+// NBI. With FILTERED lookups, the map only holds what we looked up so far,
+// so we always have to. With CACHED lookups, the map holds the whole
+// collection, so we only have to if we haven't fetched it yet.
+func (c *Session) mustFetch(notFetchedYet bool) bool {
+	return c.lookup != Lookup.CACHED || notFetchedYet
+}
+
+func withFilter(endpoint *url.URL, field string, value string) *url.URL {
+	filtered := *endpoint
+	query := filtered.Query()
+	query.Set(field, value)
+	filtered.RawQuery = query.Encode()
+	return &filtered
+}
+
+// nextPageUrl returns the URL of the `next` link in the `Link` header of
+// the response to a GET on page, resolved against page, or nil if there's
+// no `next` link. It errors out if the link points to another scheme or
+// host since we'd send our NBI token along with the request.
+func nextPageUrl(page *url.URL, res *http.Response) (*url.URL, error) {
+	if res == nil {
+		return nil, nil
+	}
+	for _, header := range res.Header.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") ||
+				!strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), `"`, "")
+				if strings.EqualFold(param, "rel=next") {
+					ref, err := url.Parse(target[1 : len(target)-1])
+					if err != nil {
+						return nil, nil
+					}
+					next := page.ResolveReference(ref)
+					if next.Scheme != page.Scheme || next.Host != page.Host {
+						return nil, fmt.Errorf(
+							"next page not on %s://%s: %s",
+							page.Scheme, page.Host, next)
+					}
+					return next, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// getJsonList GETs all the pages of the JSON array at endpoint, following
+// `next` links, and collects the elements in the slice data points to.
+func (c *Session) getJsonList(ctx context.Context, endpoint *url.URL,
+	data interface{}) error {
+	target := reflect.ValueOf(data)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("not a pointer to a slice: %T", data)
+	}
+	items := target.Elem()
+
+	visited := map[string]bool{}
+	for next := endpoint; next != nil; {
+		if visited[next.String()] { // (*)
+			return fmt.Errorf("paging loop at: %s", next)
+		}
+		visited[next.String()] = true
+
+		page := reflect.New(items.Type())
+		res, err := c.getJson(ctx, next, page.Interface())
+		if err != nil {
+			return err
+		}
+		items.Set(reflect.AppendSlice(items, page.Elem()))
+		if next, err = nextPageUrl(next, res); err != nil {
+			return err
+		}
+	}
+	return nil
+
+	// (*) a buggy server could keep on sending us back to the same page.
+}
@@ -0,0 +1,250 @@
+package nbic
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestFilteredLookupSendsQueryFilter(t *testing.T) {
+	nbi := newMockNbi()
+	urls := newConn()
+	nbic, _ := New(urls, usrCreds, nbi.exchange)
+
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err != nil {
+		t.Fatalf("want: id; got: %v", err)
+	}
+	if _, err := nbic.lookupVimAccountId(context.TODO(), "mylocation1"); err != nil {
+		t.Fatalf("want: id; got: %v", err)
+	}
+
+	if len(nbi.exchanges) != 3 {
+		t.Fatalf("want: 3; got: %d", len(nbi.exchanges))
+	}
+	if got := nbi.exchanges[1].req.URL.RawQuery; got != "id=openldap_ns" {
+		t.Errorf("want: id=openldap_ns; got: %s", got)
+	}
+	if got := nbi.exchanges[2].req.URL.RawQuery; got != "name=mylocation1" {
+		t.Errorf("want: name=mylocation1; got: %s", got)
+	}
+}
+
+func TestFilteredLookupRemembersIds(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	for k := 0; k < 2; k++ {
+		if _, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err != nil {
+			t.Fatalf("[%d] want: id; got: %v", k, err)
+		}
+	}
+	if len(nbi.exchanges) != 2 { // #1 = get token
+		t.Errorf("want: 1 lookup; got: %d", len(nbi.exchanges)-1)
+	}
+}
+
+func TestFilteredLookupQueriesAgainOnMiss(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	for k := 0; k < 2; k++ {
+		if _, err := nbic.lookupNsDescriptorId(context.TODO(), "not there!"); err == nil {
+			t.Fatalf("[%d] want: error; got: nil", k)
+		}
+	}
+	if len(nbi.exchanges) != 3 { // #1 = get token
+		t.Errorf("want: 2 lookups; got: %d", len(nbi.exchanges)-1)
+	}
+}
+
+func TestCachedLookupFetchesWholeCollectionOnce(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	if err := nbic.UseLookup(Lookup.CACHED); err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err != nil {
+		t.Fatalf("want: id; got: %v", err)
+	}
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "not there!"); err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+
+	if len(nbi.exchanges) != 2 { // #1 = get token
+		t.Fatalf("want: 1 lookup; got: %d", len(nbi.exchanges)-1)
+	}
+	if got := nbi.exchanges[1].req.URL.RawQuery; got != "" {
+		t.Errorf("want: no filter; got: %s", got)
+	}
+}
+
+func TestUseLookupRejectUnknownMode(t *testing.T) {
+	nbic := &Session{}
+	if err := nbic.UseLookup(3); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	if err := NewSessionCache(nil).UseLookup(-1); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestSessionCacheAppliesLookupMode(t *testing.T) {
+	cache := NewSessionCache(nil)
+	cache.UseLookup(Lookup.CACHED)
+	nbic, _ := cache.Get(newConn(), usrCreds, newMockNbi().exchange)
+	if nbic.lookup != Lookup.CACHED {
+		t.Errorf("want: cached; got: %d", nbic.lookup)
+	}
+}
+
+func TestSessionCacheAppliesLookupTTL(t *testing.T) {
+	cache := NewSessionCache(nil)
+	if err := cache.UseLookupTTL(-time.Second); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	cache.UseLookupTTL(time.Minute)
+	nbic, _ := cache.Get(newConn(), usrCreds, newMockNbi().exchange)
+	if nbic.lookupTTL != time.Minute {
+		t.Errorf("want: %v; got: %v", time.Minute, nbic.lookupTTL)
+	}
+}
+
+func pagedHandler(pages map[string]string,
+	links map[string]string) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		marker := req.URL.Query().Get("nextpage_opaque_marker")
+		res := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       stringReader(pages[marker]),
+		}
+		if link, ok := links[marker]; ok {
+			res.Header.Set("Link", link)
+		}
+		return res, nil
+	}
+}
+
+func TestCachedLookupFollowsNextPageLinks(t *testing.T) {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("GET", "/osm/admin/v1/vim_accounts")] = pagedHandler(
+		map[string]string{
+			"":  `[{"_id": "1", "name": "one"}]`,
+			"2": `[{"_id": "2", "name": "two"}]`,
+			"3": `[{"_id": "3", "name": "three"}]`,
+		},
+		map[string]string{
+			"":  `<http://localhost:8080/osm/admin/v1/vim_accounts?nextpage_opaque_marker=2>; rel="next"`,
+			"2": `</osm/admin/v1/vim_accounts?nextpage_opaque_marker=3>; rel=next, </osm/admin/v1/vim_accounts>; rel="first"`,
+		})
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	nbic.UseLookup(Lookup.CACHED)
+
+	if id, err := nbic.lookupVimAccountId(context.TODO(), "three"); err != nil {
+		t.Fatalf("want: id; got: %v", err)
+	} else if id != "3" {
+		t.Errorf("want: 3; got: %s", id)
+	}
+	if len(nbic.vimAccMap) != 3 {
+		t.Errorf("want: 3 accounts; got: %v", nbic.vimAccMap)
+	}
+	if len(nbi.exchanges) != 4 { // #1 = get token
+		t.Errorf("want: 3 pages; got: %d", len(nbi.exchanges)-1)
+	}
+}
+
+func TestListStopsOnPagingLoop(t *testing.T) {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("GET", "/osm/admin/v1/vim_accounts")] = pagedHandler(
+		map[string]string{"": `[]`, "2": `[]`},
+		map[string]string{
+			"":  `</osm/admin/v1/vim_accounts?nextpage_opaque_marker=2>; rel="next"`,
+			"2": `</osm/admin/v1/vim_accounts?nextpage_opaque_marker=2>; rel="next"`,
+		})
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	nbic.UseLookup(Lookup.CACHED)
+
+	if _, err := nbic.lookupVimAccountId(context.TODO(), "one"); err == nil {
+		t.Errorf("want: paging loop error; got: nil")
+	}
+}
+
+func TestGetJsonListRejectNonSliceTarget(t *testing.T) {
+	nbic := &Session{}
+	data := map[string]string{}
+	if err := nbic.getJsonList(context.TODO(), newConn().VimAccounts(), &data); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestNextPageUrl(t *testing.T) {
+	page, _ := url.Parse("http://nbi/osm/x?a=1")
+	for k, d := range []struct {
+		links []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{`<http://nbi/osm/x?p=2>; rel="prev"`}, ""},
+		{[]string{`http://nbi/osm/x?p=2; rel="next"`}, ""},
+		{[]string{`<http://nbi/osm/x?p=2>; rel="next"`}, "http://nbi/osm/x?p=2"},
+		{[]string{`<?p=2>; rel=NEXT`}, "http://nbi/osm/x?p=2"},
+		{[]string{`</osm/x?p=1>; rel="prev"`, `</osm/x?p=3>; rel="next"`},
+			"http://nbi/osm/x?p=3"},
+	} {
+		res := &http.Response{Header: http.Header{}}
+		for _, link := range d.links {
+			res.Header.Add("Link", link)
+		}
+		got := ""
+		next, err := nextPageUrl(page, res)
+		if err != nil {
+			t.Errorf("[%d] want: %s; got: %v", k, d.want, err)
+		}
+		if next != nil {
+			got = next.String()
+		}
+		if got != d.want {
+			t.Errorf("[%d] want: %s; got: %s", k, d.want, got)
+		}
+	}
+	if next, err := nextPageUrl(page, nil); next != nil || err != nil {
+		t.Errorf("want: nil on nil response; got: %v, %v", next, err)
+	}
+}
+
+func TestNextPageUrlRejectOtherOrigin(t *testing.T) {
+	page, _ := url.Parse("http://nbi/osm/x?a=1")
+	for k, link := range []string{
+		`<https://nbi/osm/x?p=2>; rel="next"`,
+		`<http://evil/osm/x?p=2>; rel="next"`,
+		`<http://nbi:8080/osm/x?p=2>; rel="next"`,
+		`<//evil/osm/x?p=2>; rel="next"`,
+	} {
+		res := &http.Response{Header: http.Header{}}
+		res.Header.Add("Link", link)
+		if next, err := nextPageUrl(page, res); err == nil {
+			t.Errorf("[%d] want: error; got: %v", k, next)
+		}
+	}
+}
+
+func TestListStopsOnNextPageToOtherHost(t *testing.T) {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("GET", "/osm/admin/v1/vim_accounts")] = pagedHandler(
+		map[string]string{"": `[]`},
+		map[string]string{
+			"": `<http://evil/osm/admin/v1/vim_accounts?nextpage_opaque_marker=2>; rel="next"`,
+		})
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	nbic.UseLookup(Lookup.CACHED)
+
+	if _, err := nbic.lookupVimAccountId(context.TODO(), "one"); err == nil {
+		t.Errorf("want: next page host error; got: nil")
+	}
+	if len(nbi.exchanges) != 2 { // #1 = get token
+		t.Errorf("want: 1 page; got: %d", len(nbi.exchanges)-1)
+	}
+}
@@ -459,7 +459,20 @@ var nsInstancesContent = `[
             "nsdId": "aba58e40-d65f-4f4e-be0a-e248c14d3e03",
             "nsName": "ldap",
             "nsDescription": "default description",
-            "vimAccountId": "4a4425f7-3e72-4d45-a4ec-4241186f3547"
+            "vimAccountId": "4a4425f7-3e72-4d45-a4ec-4241186f3547",
+            "additionalParamsForVnf": [
+                {
+                    "member-vnf-index": "openldap",
+                    "additionalParamsForKdu": [
+                        {
+                            "kdu_name": "ldap",
+                            "additionalParams": {
+                                "replicaCount": "2"
+                            }
+                        }
+                    ]
+                }
+            ]
         },
         "additionalParamsForNs": null,
         "ns-instance-config-ref": "0335c32c-d28c-4d79-9b94-0ffa36326932",
@@ -693,3 +706,10 @@ var nsInstancesContent = `[
         "name": "dup-name"
     }
 ]`
+
+var createdNsInstanceId = "794ef9a2-8bbb-42c1-869a-bab6422982ec"
+
+var nsInstanceCreated = `{
+    "id": "794ef9a2-8bbb-42c1-869a-bab6422982ec",
+    "nslcmop_id": "0fdfaa6a-b742-480c-9701-122b3f732e4"
+}`
@@ -50,8 +50,15 @@ func newMockNbi() *mockNbi {
 		"/osm/nsd/v1/ns_descriptors_content")] = mock.createPkgHandler
 	mock.handlers[handlerKey("PUT",
 		"/osm/nsd/v1/ns_descriptors_content/")] = mock.updatePkgHandler
+	mock.handlers[handlerKey("GET",
+		"/osm/vnfpkgm/v1/vnf_packages/")] = pkgNotFoundHandler // (*)
+	mock.handlers[handlerKey("GET",
+		"/osm/nsd/v1/ns_descriptors/")] = pkgNotFoundHandler // (*)
 
 	return mock
+
+	// (*) package archive downloads. Tests that want OSM to hold a package
+	// archive register a handler for its exact path. (See: serveHeldPackage)
 }
 
 func handlerKey(method string, path string) string {
@@ -63,12 +70,19 @@ func (s *mockNbi) lookupHandler(req *http.Request) (u.ReqSender, error) {
 	if handle, ok := s.handlers[key]; ok {
 		return handle, nil
 	}
-	for k, handle := range s.handlers {
-		if strings.HasPrefix(key, k) {
-			return handle, nil
+	longest := ""
+	for k := range s.handlers { // (*)
+		if strings.HasPrefix(key, k) && len(k) > len(longest) {
+			longest = k
 		}
 	}
+	if longest != "" {
+		return s.handlers[longest], nil
+	}
 	return nil, fmt.Errorf("no handler for request: %s", key)
+
+	// (*) pick the most specific handler, e.g. "GET /osm/nsd/v1/ns_descriptors/"
+	// rather than "GET /osm/nsd/v1/ns_descriptors" for a package download.
 }
 
 func (s *mockNbi) exchange(req *http.Request) (*http.Response, error) {
@@ -127,7 +141,10 @@ func nsInstContentHandler(req *http.Request) (*http.Response, error) {
 	}
 
 	// POST
-	return &http.Response{StatusCode: http.StatusCreated}, nil
+	return &http.Response{
+		StatusCode: http.StatusCreated,
+		Body:       stringReader(nsInstanceCreated),
+	}, nil
 }
 
 func nsInstActionHandler(req *http.Request) (*http.Response, error) {
@@ -136,6 +153,7 @@ func nsInstActionHandler(req *http.Request) (*http.Response, error) {
 
 func (m *mockNbi) createPkgHandler(req *http.Request) (*http.Response, error) {
 	name := strings.TrimSuffix(req.Header.Get("Content-Filename"), ".tar.gz")
+	name = strings.TrimSuffix(name, ".zip")
 	if name == "" {
 		return &http.Response{StatusCode: http.StatusBadRequest}, nil
 	}
@@ -146,7 +164,14 @@ func (m *mockNbi) createPkgHandler(req *http.Request) (*http.Response, error) {
 
 	pkgTgzData, _ := io.ReadAll(req.Body)
 	m.packages[name] = pkgTgzData
-	return &http.Response{StatusCode: http.StatusCreated}, nil
+	return &http.Response{
+		StatusCode: http.StatusCreated,
+		Body:       stringReader(fmt.Sprintf(`{"id": "%s"}`, name)),
+	}, nil
+}
+
+func pkgNotFoundHandler(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: http.StatusNotFound}, nil
 }
 
 func (m *mockNbi) updatePkgHandler(req *http.Request) (*http.Response, error) {
@@ -1,5 +1,10 @@
 package nbic
 
+import (
+	"context"
+	"net/url"
+)
+
 type nsDescView struct { // only the response fields we care about.
 	Id   string `json:"_id"`
 	Name string `json:"id"`
@@ -8,11 +13,15 @@ type nsDescView struct { // only the response fields we care about.
 type nsDescMap map[string]string
 
 func buildNsDescMap(ds []nsDescView) nsDescMap {
-	descMap := map[string]string{}
+	descMap := nsDescMap{}
+	descMap.add(ds)
+	return descMap
+}
+
+func (m nsDescMap) add(ds []nsDescView) {
 	for _, d := range ds {
-		descMap[d.Name] = d.Id
+		m[d.Name] = d.Id
 	}
-	return descMap
 }
 
 // NOTE. NSD name to ID lookup.
@@ -29,20 +38,38 @@ func buildNsDescMap(ds []nsDescView) nsDescMap {
 //     "detail": "nsd with id 'openldap_ns' already exists for this project"
 // }
 
-func (c *Session) getNsDescriptors() ([]nsDescView, error) {
+func (c *Session) getNsDescriptors(ctx context.Context, endpoint *url.URL) (
+	[]nsDescView, error) {
 	data := []nsDescView{}
-	if _, err := c.getJson(c.conn.NsDescriptors(), &data); err != nil {
+	if err := c.getJsonList(ctx, endpoint, &data); err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
-func (c *Session) lookupNsDescriptorId(name string) (string, error) {
+func (c *Session) fetchNsDescriptors(ctx context.Context, name string) error {
+	endpoint := c.conn.NsDescriptors()
+	if c.lookup == Lookup.FILTERED {
+		endpoint = withFilter(endpoint, "id", name)
+	}
+	ds, err := c.getNsDescriptors(ctx, endpoint)
+	if err != nil {
+		return err
+	}
 	if c.nsdMap == nil {
-		if ds, err := c.getNsDescriptors(); err != nil {
+		c.nsdMap = buildNsDescMap(ds)
+	} else {
+		c.nsdMap.add(ds)
+	}
+	return nil
+}
+
+func (c *Session) lookupNsDescriptorId(ctx context.Context,
+	name string) (string, error) {
+	c.expireLookups()
+	if _, ok := c.nsdMap[name]; !ok && c.mustFetch(c.nsdMap == nil) {
+		if err := c.fetchNsDescriptors(ctx, name); err != nil {
 			return "", err
-		} else {
-			c.nsdMap = buildNsDescMap(ds)
 		}
 	}
 	if id, ok := c.nsdMap[name]; !ok {
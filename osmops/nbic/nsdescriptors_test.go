@@ -1,6 +1,7 @@
 package nbic
 
 import (
+	"context"
 	"testing"
 )
 
@@ -8,7 +9,7 @@ func TestLookupNsDescIdUseCachedData(t *testing.T) {
 	nbic := &Session{
 		nsdMap: map[string]string{"silly_ns": "324567"},
 	}
-	id, err := nbic.lookupNsDescriptorId("silly_ns")
+	id, err := nbic.lookupNsDescriptorId(context.TODO(), "silly_ns")
 	if err != nil {
 		t.Errorf("want: 324567; got: %v", err)
 	}
@@ -21,7 +22,7 @@ func TestLookupNsDescIdErrorOnMiss(t *testing.T) {
 	nbic := &Session{
 		nsdMap: map[string]string{"silly_ns": "324567"},
 	}
-	if _, err := nbic.lookupNsDescriptorId("not there!"); err == nil {
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "not there!"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -32,7 +33,7 @@ func TestLookupNsDescIdFetchDataFromServer(t *testing.T) {
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
 	wantId := "aba58e40-d65f-4f4e-be0a-e248c14d3e03"
-	if gotId, err := nbic.lookupNsDescriptorId("openldap_ns"); err != nil {
+	if gotId, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err != nil {
 		t.Errorf("want: %s; got: %v", wantId, err)
 	} else {
 		if gotId != wantId {
@@ -57,7 +58,7 @@ func TestLookupNsDescIdFetchDataFromServerTokenError(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, UserCredentials{}, nbi.exchange)
 
-	if _, err := nbic.lookupNsDescriptorId("openldap_ns"); err == nil {
+	if _, err := nbic.lookupNsDescriptorId(context.TODO(), "openldap_ns"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 
@@ -1,7 +1,9 @@
 package nbic
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 
 	u "github.com/fluxcd/source-watcher/osmops/util"
 )
@@ -11,6 +13,10 @@ type nsInstanceView struct { // only the response fields we care about.
 	Name string `json:"name"`
 }
 
+type nsInstCreatedView struct { // only the response fields we care about.
+	Id string `json:"id"`
+}
+
 type nsInstanceMap map[string][]string
 
 func (m nsInstanceMap) addMapping(name string, id string) {
@@ -23,10 +29,41 @@ func (m nsInstanceMap) addMapping(name string, id string) {
 
 func buildNsInstanceMap(vs []nsInstanceView) nsInstanceMap {
 	nsMap := nsInstanceMap{}
+	nsMap.add(vs)
+	return nsMap
+}
+
+func (m nsInstanceMap) add(vs []nsInstanceView) {
 	for _, v := range vs {
-		nsMap.addMapping(v.Name, v.Id)
+		if !m.hasMapping(v.Name, v.Id) { // (*)
+			m.addMapping(v.Name, v.Id)
+		}
 	}
-	return nsMap
+
+	// (*) a filtered fetch could return instances we already know about.
+}
+
+func (m nsInstanceMap) removeMapping(name string, id string) {
+	kept := []string{}
+	for _, known := range m[name] {
+		if known != id {
+			kept = append(kept, known)
+		}
+	}
+	if len(kept) == 0 {
+		delete(m, name)
+	} else {
+		m[name] = kept
+	}
+}
+
+func (m nsInstanceMap) hasMapping(name string, id string) bool {
+	for _, known := range m[name] {
+		if known == id {
+			return true
+		}
+	}
+	return false
 }
 
 // NOTE. NS instance name to ID lookup.
@@ -47,22 +84,40 @@ func buildNsInstanceMap(vs []nsInstanceView) nsInstanceMap {
 //
 // This is why we map an NS instance name to a list of IDs.
 
-func (c *Session) getNsInstancesContent() ([]nsInstanceView, error) {
+func (c *Session) getNsInstancesContent(ctx context.Context,
+	endpoint *url.URL) ([]nsInstanceView, error) {
 	data := []nsInstanceView{}
-	if _, err := c.getJson(c.conn.NsInstancesContent(), &data); err != nil {
+	if err := c.getJsonList(ctx, endpoint, &data); err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
+func (c *Session) fetchNsInstances(ctx context.Context, name string) error {
+	endpoint := c.conn.NsInstancesContent()
+	if c.lookup == Lookup.FILTERED {
+		endpoint = withFilter(endpoint, "name", name)
+	}
+	vs, err := c.getNsInstancesContent(ctx, endpoint)
+	if err != nil {
+		return err
+	}
+	if c.nsInstMap == nil {
+		c.nsInstMap = buildNsInstanceMap(vs)
+	} else {
+		c.nsInstMap.add(vs)
+	}
+	return nil
+}
+
 type maybeNsInstId *string
 
-func (c *Session) lookupNsInstanceId(name string) (maybeNsInstId, error) {
-	if c.nsInstMap == nil {
-		if vs, err := c.getNsInstancesContent(); err != nil {
+func (c *Session) lookupNsInstanceId(ctx context.Context,
+	name string) (maybeNsInstId, error) {
+	c.expireLookups()
+	if _, ok := c.nsInstMap[name]; !ok && c.mustFetch(c.nsInstMap == nil) {
+		if err := c.fetchNsInstances(ctx, name); err != nil {
 			return nil, err
-		} else {
-			c.nsInstMap = buildNsInstanceMap(vs)
 		}
 	}
 	if ids, ok := c.nsInstMap[name]; !ok {
@@ -122,19 +177,20 @@ type nsInstanceContentActionDto struct {
 	PrimitiveParams interface{} `json:"primitive_params"`
 }
 
-func (c *Session) CreateOrUpdateNsInstance(data *NsInstanceContent) error {
+func (c *Session) CreateOrUpdateNsInstance(ctx context.Context,
+	data *NsInstanceContent) (*Outcome, error) {
 	if data == nil {
-		return fmt.Errorf("nil data")
+		return nil, fmt.Errorf("nil data")
 	}
 
-	nsId, err := c.lookupNsInstanceId(data.Name)
+	nsId, err := c.lookupNsInstanceId(ctx, data.Name)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if nsId == nil {
-		return c.createNsInstance(data)
+		return c.createNsInstance(ctx, data)
 	}
-	return c.updateNsInstance(*nsId, data)
+	return c.updateNsInstance(ctx, *nsId, data)
 }
 
 func toNsInstContentDto(nsdId string, vimAccId string,
@@ -161,19 +217,25 @@ func toNsInstContentDto(nsdId string, vimAccId string,
 	return &dto
 }
 
-func (c *Session) createNsInstance(data *NsInstanceContent) error {
-	nsdId, err := c.lookupNsDescriptorId(data.NsdName)
+func (c *Session) createNsInstance(ctx context.Context,
+	data *NsInstanceContent) (*Outcome, error) {
+	nsdId, err := c.lookupNsDescriptorId(ctx, data.NsdName)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	vimAccId, err := c.lookupVimAccountId(data.VimAccountName)
+	vimAccId, err := c.lookupVimAccountId(ctx, data.VimAccountName)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	dto := toNsInstContentDto(nsdId, vimAccId, data)
 
-	_, err = c.postJson(c.conn.NsInstancesContent(), dto)
-	return err
+	created := nsInstCreatedView{}
+	_, err = c.postJson(ctx, c.conn.NsInstancesContent(), dto, &created)
+	if err != nil {
+		return nil, err
+	}
+	c.rememberNsInstance(data.Name, created.Id)
+	return &Outcome{Created: true, Id: created.Id}, nil
 }
 
 var nsAction = struct {
@@ -195,8 +257,15 @@ func toNsInstanceContentActionDto(nsId string, data *NsInstanceContent) *nsInsta
 	}
 }
 
-func (c *Session) updateNsInstance(nsId string, data *NsInstanceContent) error {
+func (c *Session) updateNsInstance(ctx context.Context, nsId string,
+	data *NsInstanceContent) (*Outcome, error) {
 	dto := toNsInstanceContentActionDto(nsId, data)
-	_, err := c.postJson(c.conn.NsInstancesAction(nsId), dto)
-	return err
+	endpoint := c.conn.NsInstancesAction(nsId)
+	if _, err := c.postJson(ctx, endpoint, dto); err != nil {
+		if isNotFound(err) {
+			c.forgetNsInstance(data.Name, nsId)
+		}
+		return nil, err
+	}
+	return &Outcome{Created: false, Id: nsId}, nil
 }
@@ -1,6 +1,7 @@
 package nbic
 
 import (
+	"context"
 	"io/ioutil"
 	"reflect"
 	"testing"
@@ -41,7 +42,7 @@ func TestLookupNsInstIdUseCachedData(t *testing.T) {
 	nbic := &Session{
 		nsInstMap: map[string][]string{"silly_ns": {"324567"}},
 	}
-	id, err := nbic.lookupNsInstanceId("silly_ns")
+	id, err := nbic.lookupNsInstanceId(context.TODO(), "silly_ns")
 	if err != nil {
 		t.Errorf("want: 324567; got: %v", err)
 	}
@@ -52,9 +53,10 @@ func TestLookupNsInstIdUseCachedData(t *testing.T) {
 
 func TestLookupNsInstIdNilOnMiss(t *testing.T) {
 	nbic := &Session{
+		lookup:    Lookup.CACHED,
 		nsInstMap: map[string][]string{"silly_ns": {"324567"}},
 	}
-	if got, err := nbic.lookupNsInstanceId("not there!"); err != nil {
+	if got, err := nbic.lookupNsInstanceId(context.TODO(), "not there!"); err != nil {
 		t.Errorf("want: nil; got: %v", err)
 	} else {
 		if got != nil {
@@ -69,7 +71,7 @@ func TestLookupNsInstIdFetchDataFromServer(t *testing.T) {
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
 	wantId := "0335c32c-d28c-4d79-9b94-0ffa36326932"
-	if gotId, err := nbic.lookupNsInstanceId("ldap"); err != nil {
+	if gotId, err := nbic.lookupNsInstanceId(context.TODO(), "ldap"); err != nil {
 		t.Errorf("want: %s; got: %v", wantId, err)
 	} else {
 		if *gotId != wantId {
@@ -94,7 +96,7 @@ func TestLookupNsInstIdFetchDataFromServerTokenError(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, UserCredentials{}, nbi.exchange)
 
-	if _, err := nbic.lookupNsInstanceId("ldap"); err == nil {
+	if _, err := nbic.lookupNsInstanceId(context.TODO(), "ldap"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 
@@ -112,7 +114,7 @@ func TestLookupNsInstIdFetchDataFromServerDupNameError(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
-	if _, err := nbic.lookupNsInstanceId("dup-name"); err == nil {
+	if _, err := nbic.lookupNsInstanceId(context.TODO(), "dup-name"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 
@@ -133,7 +135,7 @@ func TestCreateOrUpdateNsInstanceErrorOnNilData(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
-	if got := nbic.CreateOrUpdateNsInstance(nil); got == nil {
+	if _, got := nbic.CreateOrUpdateNsInstance(context.TODO(), nil); got == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -149,7 +151,7 @@ func TestCreateNsInstanceErrorOnMissingNsd(t *testing.T) {
 		NsdName:        "not there!",
 		VimAccountName: "mylocation1",
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err == nil {
+	if _, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -165,7 +167,7 @@ func TestCreateNsInstanceErrorOnMissingVimAccount(t *testing.T) {
 		NsdName:        "openldap_ns",
 		VimAccountName: "not there!",
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err == nil {
+	if _, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -181,7 +183,7 @@ func TestCreateNsInstanceErrorOnDupNsInstanceName(t *testing.T) {
 		NsdName:        "openldap_ns",
 		VimAccountName: "mylocation1",
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err == nil {
+	if _, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -218,6 +220,15 @@ func assertCreateNsInstanceHttpFlow(t *testing.T, urls Connection,
 	return string(got)
 }
 
+func assertCreatedNsInstanceOutcome(t *testing.T, outcome *Outcome) {
+	if !outcome.Created {
+		t.Errorf("want: create outcome; got: update")
+	}
+	if outcome.Id != createdNsInstanceId {
+		t.Errorf("want: %s; got: %s", createdNsInstanceId, outcome.Id)
+	}
+}
+
 func TestCreateNsInstanceWithNoAdditionalParams(t *testing.T) {
 	nbi := newMockNbi()
 	urls := newConn()
@@ -229,9 +240,11 @@ func TestCreateNsInstanceWithNoAdditionalParams(t *testing.T) {
 		NsdName:        "openldap_ns",
 		VimAccountName: "mylocation1",
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err != nil {
-		t.Errorf("want: create; got: %v", err)
+	outcome, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data)
+	if err != nil {
+		t.Fatalf("want: create; got: %v", err)
 	}
+	assertCreatedNsInstanceOutcome(t, outcome)
 
 	want := `{"nsName":"not-there","nsdId":"aba58e40-d65f-4f4e-be0a-e248c14d3e03","nsDescription":"wada wada","vimAccountId":"4a4425f7-3e72-4d45-a4ec-4241186f3547"}`
 	got := assertCreateNsInstanceHttpFlow(t, urls, nbi.exchanges)
@@ -267,9 +280,11 @@ func TestCreateNsInstanceWithAdditionalParams(t *testing.T) {
 		KduName:        "ldap",
 		KduParams:      kdu.Params,
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err != nil {
-		t.Errorf("want: create; got: %v", err)
+	outcome, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data)
+	if err != nil {
+		t.Fatalf("want: create; got: %v", err)
 	}
+	assertCreatedNsInstanceOutcome(t, outcome)
 
 	want := `{"nsName":"not-there","nsdId":"aba58e40-d65f-4f4e-be0a-e248c14d3e03","nsDescription":"wada wada","vimAccountId":"4a4425f7-3e72-4d45-a4ec-4241186f3547"`
 	want += `,"additionalParamsForVnf":[{"member-vnf-index":"openldap","additionalParamsForKdu":[{"kdu_name":"ldap","additionalParams":{"replicaCount":"2"}}]}]}`
@@ -325,8 +340,15 @@ func TestUpdateNsInstance(t *testing.T) {
 		KduName:        "ldap",
 		KduParams:      kdu.Params,
 	}
-	if err := nbic.CreateOrUpdateNsInstance(&data); err != nil {
-		t.Errorf("want: update; got: %v", err)
+	outcome, err := nbic.CreateOrUpdateNsInstance(context.TODO(), &data)
+	if err != nil {
+		t.Fatalf("want: update; got: %v", err)
+	}
+	if outcome.Created {
+		t.Errorf("want: update outcome; got: create")
+	}
+	if outcome.Id != nsInstanceId {
+		t.Errorf("want: %s; got: %s", nsInstanceId, outcome.Id)
 	}
 
 	want := `{"member_vnf_index":"openldap","kdu_name":"ldap","primitive":"upgrade","primitive_params":{"replicaCount":"2"}}`
@@ -1,25 +1,140 @@
 package nbic
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
 
 	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
 	. "github.com/fluxcd/source-watcher/osmops/util/http"
 )
 
-func (s *Session) CreateOrUpdatePackage(source file.AbsPath) error {
-	handler, err := newPkgHandler(s, source)
+func (s *Session) CreateOrUpdatePackage(ctx context.Context,
+	source file.AbsPath, opts ...pkgr.PackOption) (*Outcome, error) {
+	reader, err := newPkgReader(ctx, source, opts...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer reader.Close()
+
+	handler, err := newPkgHandler(ctx, s, reader)
+	if err != nil {
+		return nil, err
+	}
+	if handler.isUpdate {
+		if same, err := handler.sameAsHeld(); err == nil && same { // (*)
+			return &Outcome{Unchanged: true, Id: handler.osmPkgId}, nil
+		}
 	}
 	return handler.process()
+
+	// (*) if we can't tell whether the package changed, e.g. because OSM
+	// won't let us download it, we play safe and update it anyway.
+}
+
+func (s *Session) CreatePackageVersion(ctx context.Context,
+	source file.AbsPath, opts ...pkgr.PackOption) (*Outcome, error) {
+	reader, err := newPkgReader(ctx, source, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	handler, err := newPkgHandler(ctx, s, reader)
+	if err != nil {
+		return nil, err
+	}
+	if handler.isUpdate {
+		return handler.checkUnchanged()
+	}
+	return handler.process()
+}
+
+// checkUnchanged compares the package we built with the one OSM holds
+// under the same versioned ID. If their files are the same, the version
+// is already in OSM, otherwise someone edited the package without bumping
+// its version and we'd silently lose those changes if we went ahead.
+func (h *pkgHandler) checkUnchanged() (*Outcome, error) {
+	same, err := h.sameAsHeld()
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, versionNotBumped(h.pkg)
+	}
+	return &Outcome{Unchanged: true, Id: h.osmPkgId}, nil
+}
+
+// sameAsHeld downloads the package OSM holds under the ID of the package
+// we built and tells if the two packages have the same files.
+func (h *pkgHandler) sameAsHeld() (bool, error) {
+	info := &PackageInfo{
+		Kind: PackageKind.NS, Id: h.osmPkgId, Name: h.pkg.Id(),
+	}
+	if h.pkg.IsKnf() {
+		info.Kind = PackageKind.KNF
+	}
+	held := &bytes.Buffer{}
+	if err := h.session.DownloadPackage(h.ctx, info, held); err != nil {
+		return false, err
+	}
+	heldDigests, err := archiveDigests(io.NopCloser(held))
+	if err != nil {
+		return false, err
+	}
+	data, err := h.pkg.OpenData()
+	if err != nil {
+		return false, err
+	}
+	builtDigests, err := archiveDigests(data)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(heldDigests, builtDigests), nil
+}
+
+func versionNotBumped(pkg *pkgReader) error {
+	msg := "%v: content changed but version not bumped: %s"
+	return fmt.Errorf(msg, pkg.Source(), pkg.Id())
+}
+
+// archiveDigests returns the MD5 of each file in the given package archive,
+// keyed by archive path. We compare files rather than archives so it
+// doesn't matter how OSM or Pack compressed them.
+func archiveDigests(source io.ReadCloser) (map[string]string, error) {
+	reader, err := tgz.NewDetectingReader(source)
+	if err != nil {
+		return nil, err
+	}
+	digests := map[string]string{}
+	err = reader.IterateEntries(
+		func(archivePath string, fi os.FileInfo, content io.Reader) error {
+			if !fi.Mode().IsRegular() {
+				return nil
+			}
+			hash := md5.New()
+			if _, err := io.Copy(hash, content); err != nil {
+				return err
+			}
+			digests[archivePath] = hex.EncodeToString(hash.Sum(nil))
+			return nil
+		})
+	return digests, err
 }
 
 // pkgReader wraps Package to consolidate in one place all the assumptions
@@ -30,29 +145,44 @@ func (s *Session) CreateOrUpdatePackage(source file.AbsPath) error {
 // - VNF pkg => pgk name ends w/ "_knf"
 // - NS pkg => pkg name ends w/ "_ns"
 //
+// Notice the pkg name is that of the package Pack builds, which could be
+// a versioned ID (see: pkgr.WithDescriptorIds), whereas we look at the
+// name of the source directory to figure out the package type.
+//
 // None of the above needs to be true in general, but OsmOps relies on that
 // at the moment to simplify the implementation. Eventually, we'll redo this
 // properly, i.e. use a semantic approach (parse, interpret OSM files) rather
 // than naming conventions and guesswork.
+//
+// pkgReader doesn't hold the package data in memory, it streams it from
+// the file Pack spooled it to. Close the reader to delete that file.
 type pkgReader struct {
-	pkg  *pkgr.Package
-	data []byte
+	source file.AbsPath
+	pkg    *pkgr.Package
 }
 
-func newPkgReader(pkgSource file.AbsPath) (*pkgReader, error) {
-	pkg, err := pkgr.Pack(pkgSource)
+func newPkgReader(ctx context.Context, pkgSource file.AbsPath,
+	opts ...pkgr.PackOption) (*pkgReader, error) {
+	_, span := tracing.Start(ctx, "pkgr.Pack")
+	pkg, err := pkgr.Pack(pkgSource, opts...)
 	if err != nil {
-		return nil, err
+		return nil, tracing.End(span, err)
 	}
-	data, err := io.ReadAll(pkg.Data)
-	return &pkgReader{
-		pkg:  pkg,
-		data: data,
-	}, err
+	span.SetAttributes(tracing.PackageNameKey.String(pkg.Name))
+	span.End()
+	return &pkgReader{source: pkgSource, pkg: pkg}, nil
+}
+
+func (r *pkgReader) Close() error {
+	return r.pkg.Data.Close()
 }
 
 func (r *pkgReader) Source() file.AbsPath {
-	return r.pkg.Source.Directory()
+	return r.source
+}
+
+func (r *pkgReader) sourceName() string {
+	return filepath.Base(r.source.Value())
 }
 
 func (r *pkgReader) Name() string {
@@ -63,45 +193,68 @@ func (r *pkgReader) Id() string {
 	return r.Name()
 }
 
-func (r *pkgReader) Data() []byte {
-	return r.data
+func (r *pkgReader) Size() int64 {
+	return r.pkg.Size
+}
+
+func (r *pkgReader) OpenData() (io.ReadCloser, error) {
+	return r.pkg.OpenData()
 }
 
 func (r *pkgReader) Hash() string {
 	return r.pkg.Hash
 }
 
+// MediaType is the content type of the package archive: zip for CSAR
+// packages, gzip otherwise.
+func (r *pkgReader) MediaType() u.EnumIx {
+	if r.pkg.Format == pkgr.PackageFormat.CSAR {
+		return MediaType.ZIP
+	}
+	return MediaType.GZIP
+}
+
 func (r *pkgReader) IsNs() bool {
-	return strings.HasSuffix(r.Name(), "_ns")
+	return strings.HasSuffix(r.sourceName(), "_ns")
 }
 
 func (r *pkgReader) IsKnf() bool {
-	return strings.HasSuffix(r.Name(), "_knf")
+	return strings.HasSuffix(r.sourceName(), "_knf")
+}
+
+type pkgCreatedView struct { // only the response fields we care about.
+	Id string `json:"id"`
 }
 
 type pkgHandler struct {
+	ctx      context.Context
 	session  *Session
 	pkg      *pkgReader
 	endpoint *url.URL
 	isUpdate bool
+	osmPkgId string
+	remember func(name string, id string)
+	forget   func(name string)
 }
 
-func newPkgHandler(sesh *Session, pkgSrc file.AbsPath) (*pkgHandler, error) {
-	reader, err := newPkgReader(pkgSrc)
-	if err != nil {
-		return nil, err
-	}
+func newPkgHandler(ctx context.Context, sesh *Session, reader *pkgReader) (
+	*pkgHandler, error) {
 	handler := &pkgHandler{
+		ctx:     ctx,
 		session: sesh,
 		pkg:     reader,
 	}
 	if reader.IsKnf() {
+		handler.remember = sesh.rememberVnfDescriptor
+		handler.forget = sesh.forgetVnfDescriptor
 		return mkPkgHandler(
 			handler, handler.session.lookupVnfDescriptorId,
 			handler.session.conn.VnfPackagesContent,
 			handler.session.conn.VnfPackageContent)
 	}
 	if reader.IsNs() {
+		handler.remember = sesh.rememberNsDescriptor
+		handler.forget = sesh.forgetNsDescriptor
 		return mkPkgHandler(
 			handler, handler.session.lookupNsDescriptorId,
 			handler.session.conn.NsPackagesContent,
@@ -114,13 +267,13 @@ func unsupportedPackageType(pkg *pkgReader) error {
 	return fmt.Errorf("unsupported package type: %v", pkg.Source())
 }
 
-type lookupDescId func(pkgId string) (string, error)
+type lookupDescId func(ctx context.Context, pkgId string) (string, error)
 type createEndpoint func() *url.URL
 type updateEndpoint func(osmPkgId string) *url.URL
 
 func mkPkgHandler(h *pkgHandler, getOsmId lookupDescId,
 	createUrl createEndpoint, updateUrl updateEndpoint) (*pkgHandler, error) {
-	osmPkgId, err := getOsmId(h.pkg.Id())
+	osmPkgId, err := getOsmId(h.ctx, h.pkg.Id())
 	if _, ok := err.(*missingDescriptor); ok {
 		h.isUpdate = false
 		h.endpoint = createUrl()
@@ -129,32 +282,46 @@ func mkPkgHandler(h *pkgHandler, getOsmId lookupDescId,
 	}
 	if err == nil {
 		h.isUpdate = true
+		h.osmPkgId = osmPkgId
 		h.endpoint = updateUrl(osmPkgId)
 	}
 	return h, err
 }
 
-func (h *pkgHandler) process() error {
-	run := h.post
+func (h *pkgHandler) process() (*Outcome, error) {
 	if h.isUpdate {
-		run = h.put
+		if _, err := h.put(); err != nil {
+			if isNotFound(err) {
+				h.forget(h.pkg.Id())
+			}
+			return nil, err
+		}
+		return &Outcome{Created: false, Id: h.osmPkgId}, nil
 	}
-	_, err := run()
-	return err
+
+	created := pkgCreatedView{}
+	if _, err := h.post(&created); err != nil {
+		return nil, err
+	}
+	h.remember(h.pkg.Id(), created.Id)
+	return &Outcome{Created: true, Id: created.Id}, nil
 }
 
-func (h *pkgHandler) post() (*http.Response, error) {
+func (h *pkgHandler) post(created *pkgCreatedView) (*http.Response, error) {
 	req := Request(
 		POST, At(h.endpoint),
 		h.session.NbiAccessToken(),
-		Accept(MediaType.JSON),  // same as what OSM client does
-		Content(MediaType.GZIP), // ditto
-		ContentFilename(h.pkg),  // ditto
-		ContentFileMd5(h.pkg),   // ditto
-		Body(h.pkg.Data()),
+		Accept(MediaType.JSON),     // same as what OSM client does
+		Content(h.pkg.MediaType()), // ditto
+		ContentFilename(h.pkg),     // ditto
+		ContentFileMd5(h.pkg),      // ditto
+		StreamBody(h.pkg.Size(), h.pkg.OpenData),
+	)
+	req.SetHandler(
+		ExpectNbiSuccess(http.MethodPost, h.endpoint),
+		ReadJsonResponse(created),
 	)
-	req.SetHandler(ExpectSuccess())
-	return req.RunWith(h.session.transport)
+	return req.RunWith(h.ctx, h.session.transport)
 }
 
 func (h *pkgHandler) put() (*http.Response, error) {
@@ -169,8 +336,8 @@ func (h *pkgHandler) put() (*http.Response, error) {
 		Content(MediaType.YAML),
 		Body(descData),
 	)
-	req.SetHandler(ExpectSuccess())
-	return req.RunWith(h.session.transport)
+	req.SetHandler(ExpectNbiSuccess(http.MethodPut, h.endpoint))
+	return req.RunWith(h.ctx, h.session.transport)
 }
 
 // NOTE. Package update. It's kinda weird the way it works, but most likely
@@ -212,7 +379,7 @@ func noDescriptorFound(pkg *pkgReader) error {
 }
 
 func ContentFilename(pkg *pkgReader) ReqBuilder {
-	name := fmt.Sprintf("%s.tar.gz", pkg.Name())
+	name := pkg.pkg.FileName()
 	return func(request *http.Request) error {
 		request.Header.Set("Content-Filename", name)
 		return nil
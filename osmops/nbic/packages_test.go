@@ -1,6 +1,7 @@
 package nbic
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
@@ -28,13 +30,14 @@ func md5string(data []byte) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-func callCreateOrUpdatePackage(pkgDirName string) (*mockNbi, error) {
+func callCreateOrUpdatePackage(pkgDirName string) (*mockNbi, *Outcome, error) {
 	nbi := newMockNbi()
 	urls := newConn()
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 	pkgSrc := findTestDataDir(pkgDirName)
 
-	return nbi, nbic.CreateOrUpdatePackage(pkgSrc)
+	outcome, err := nbic.CreateOrUpdatePackage(context.TODO(), pkgSrc)
+	return nbi, outcome, err
 }
 
 func checkUploadedPackage(t *testing.T, mockNbi *mockNbi, req *http.Request,
@@ -77,10 +80,13 @@ func checkUnsupportedPackageErr(t *testing.T, err error) {
 }
 
 func runCreatePackageTest(t *testing.T, pkgDirName string) {
-	mockNbi, err := callCreateOrUpdatePackage(pkgDirName)
+	mockNbi, outcome, err := callCreateOrUpdatePackage(pkgDirName)
 
 	if err != nil {
-		t.Errorf("want: create package; got: %v", err)
+		t.Fatalf("want: create package; got: %v", err)
+	}
+	if !outcome.Created || outcome.Id != pkgDirName {
+		t.Errorf("want: created %s; got: %+v", pkgDirName, outcome)
 	}
 	if len(mockNbi.exchanges) != 3 { // #1 = get token
 		t.Fatalf("want: one req to lookup package, then one to create it; got: %d",
@@ -96,17 +102,20 @@ func runCreatePackageTest(t *testing.T, pkgDirName string) {
 }
 
 func runUpdatePackageTest(t *testing.T, pkgDirName, descFilePath, osmPkgId string) {
-	mockNbi, err := callCreateOrUpdatePackage(pkgDirName)
+	mockNbi, outcome, err := callCreateOrUpdatePackage(pkgDirName)
 
 	if err != nil {
-		t.Errorf("want: update package; got: %v", err)
+		t.Fatalf("want: update package; got: %v", err)
 	}
-	if len(mockNbi.exchanges) != 3 { // #1 = get token
-		t.Fatalf("want: one req to lookup package, then one to update it; got: %d",
+	if outcome.Created || outcome.Id != osmPkgId {
+		t.Errorf("want: updated %s; got: %+v", osmPkgId, outcome)
+	}
+	if len(mockNbi.exchanges) != 4 { // #1 = get token
+		t.Fatalf("want: one req to lookup package, one to download it, then one to update it; got: %d",
 			len(mockNbi.exchanges)-1)
 	}
 
-	updateExchange := mockNbi.exchanges[2]
+	updateExchange := mockNbi.exchanges[3]
 	checkUploadedPackageDesc(t, mockNbi, pkgDirName, descFilePath, osmPkgId)
 	if updateExchange.res.StatusCode != http.StatusOK {
 		t.Errorf("want update status: %d; got: %d",
@@ -115,7 +124,7 @@ func runUpdatePackageTest(t *testing.T, pkgDirName, descFilePath, osmPkgId strin
 }
 
 func runUpdatePackageTestNoDescErr(t *testing.T, pkgDirName string) {
-	_, err := callCreateOrUpdatePackage(pkgDirName)
+	_, _, err := callCreateOrUpdatePackage(pkgDirName)
 	if err == nil {
 		t.Errorf("want: update package error; got: nil")
 	}
@@ -125,7 +134,7 @@ func runUpdatePackageTestNoDescErr(t *testing.T, pkgDirName string) {
 }
 
 func runUpdatePackageTestManyDescErr(t *testing.T, pkgDirName string) {
-	_, err := callCreateOrUpdatePackage(pkgDirName)
+	_, _, err := callCreateOrUpdatePackage(pkgDirName)
 	if err == nil {
 		t.Errorf("want: update package error; got: nil")
 	}
@@ -142,6 +151,29 @@ func TestCreateNsPackage(t *testing.T) {
 	runCreatePackageTest(t, "create_ns")
 }
 
+func TestCreateCsarPackage(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	csar := pkgr.WithPackageFormat(pkgr.PackageFormat.CSAR)
+
+	outcome, err := nbic.CreateOrUpdatePackage(context.TODO(),
+		findTestDataDir("csar_knf"), csar)
+	if err != nil {
+		t.Fatalf("want: create package; got: %v", err)
+	}
+	if !outcome.Created || outcome.Id != "csar_knf" {
+		t.Errorf("want: created csar_knf; got: %+v", outcome)
+	}
+
+	req := nbi.exchanges[2].req
+	if got := req.Header.Get("Content-Type"); got != "application/zip" {
+		t.Errorf("want: application/zip; got: %s", got)
+	}
+	if got := req.Header.Get("Content-Filename"); got != "csar_knf.zip" {
+		t.Errorf("want: csar_knf.zip; got: %s", got)
+	}
+}
+
 func TestUpdateKnfPackage(t *testing.T) {
 	osmPkgId := "4ffdeb67-92e7-46fa-9fa2-331a4d674137" // see vnfDescriptors
 	runUpdatePackageTest(t, "openldap_knf", "openldap_vnfd.yaml", osmPkgId)
@@ -152,6 +184,27 @@ func TestUpdateNsPackage(t *testing.T) {
 	runUpdatePackageTest(t, "openldap_ns", "openldap_nsd.yaml", osmPkgId)
 }
 
+func TestUpdatePackageLeavesUnchangedOneAlone(t *testing.T) {
+	nbi := newMockNbi()
+	serveHeldPackage(t, nbi, "openldap_knf")
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	outcome, err := nbic.CreateOrUpdatePackage(context.TODO(),
+		findTestDataDir("openldap_knf"))
+	if err != nil {
+		t.Fatalf("want: outcome; got: %v", err)
+	}
+	want := Outcome{Id: "4ffdeb67-92e7-46fa-9fa2-331a4d674137", Unchanged: true}
+	if *outcome != want {
+		t.Errorf("want: %+v; got: %+v", want, *outcome)
+	}
+	for _, rr := range nbi.exchanges {
+		if rr.req.Method == http.MethodPut {
+			t.Errorf("want: no package update; got: PUT %s", rr.req.URL)
+		}
+	}
+}
+
 func TestUpdateKnfPackageNoDescErr(t *testing.T) {
 	runUpdatePackageTestNoDescErr(t, "update_no_desc/openldap_knf")
 }
@@ -169,7 +222,7 @@ func TestUpdateNsPackageManyDescErr(t *testing.T) {
 }
 
 func TestPackErrOnSourceDirAccess(t *testing.T) {
-	mockNbi, err := callCreateOrUpdatePackage("not-there_knf")
+	mockNbi, _, err := callCreateOrUpdatePackage("not-there_knf")
 
 	if _, ok := err.(*file.VisitError); !ok {
 		t.Errorf("want: visit error; got: %v", err)
@@ -181,7 +234,7 @@ func TestPackErrOnSourceDirAccess(t *testing.T) {
 }
 
 func TestCreateUnsupportedPackage(t *testing.T) {
-	mockNbi, err := callCreateOrUpdatePackage("unsupported")
+	mockNbi, _, err := callCreateOrUpdatePackage("unsupported")
 	if len(mockNbi.exchanges) > 0 {
 		t.Errorf("want: no req to create or update package; got: %d",
 			len(mockNbi.exchanges))
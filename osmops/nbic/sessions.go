@@ -0,0 +1,160 @@
+package nbic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+
+	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
+	. "github.com/fluxcd/source-watcher/osmops/util/http"
+	"github.com/fluxcd/source-watcher/osmops/util/http/sec"
+)
+
+// TokenStoreFactory creates the TokenStore to keep the NBI access tokens
+// of the given OSM target. The target is an opaque key, safe to use as a
+// file name, which uniquely identifies an NBI endpoint and credentials.
+type TokenStoreFactory func(target string) (sec.TokenStore, error)
+
+// MemoryTokenStores keeps tokens in memory, so they only last as long as
+// the process.
+func MemoryTokenStores() TokenStoreFactory {
+	return func(target string) (sec.TokenStore, error) {
+		return &sec.MemoryTokenStore{}, nil
+	}
+}
+
+// FileTokenStores keeps each target's tokens in a file in the given
+// directory, so they survive process restarts. In a cluster, dir would
+// typically be on a persistent volume.
+func FileTokenStores(dir string) TokenStoreFactory {
+	return func(target string) (sec.TokenStore, error) {
+		path := filepath.Join(dir, target+".token.json")
+		return sec.NewFileTokenStore(path), nil
+	}
+}
+
+type nbiLogin struct {
+	agent ReqSender
+	authz *sec.TokenManager
+}
+
+// SessionCache shares NBI logins among Sessions. It hands out a new Session
+// each time you ask for one, but Sessions for the same OSM target (NBI
+// endpoint and user credentials) reuse the same transport and access tokens,
+// so we only log into OSM again when the token expires. Since every Session
+// starts with empty lookup maps, a Session obtained at the beginning of a
+// reconcile won't see stale NSD, VNFD, VIM account or NS instance data from
+// previous reconciles.
+//
+// SessionCache is safe for concurrent use.
+type SessionCache struct {
+	mutex     sync.Mutex
+	newStore  TokenStoreFactory
+	logins    map[string]*nbiLogin
+	trace     *TraceOptions
+	lookup    u.EnumIx
+	lookupTTL time.Duration
+}
+
+// NewSessionCache creates a SessionCache that keeps tokens in the stores
+// the given factory builds.
+func NewSessionCache(newStore TokenStoreFactory) *SessionCache {
+	if newStore == nil {
+		newStore = MemoryTokenStores()
+	}
+	return &SessionCache{
+		newStore: newStore,
+		logins:   map[string]*nbiLogin{},
+	}
+}
+
+// DefaultSessionCache is the process-wide SessionCache. It keeps tokens in
+// memory unless replaced on startup, e.g. with a cache using FileTokenStores.
+var DefaultSessionCache = NewSessionCache(MemoryTokenStores())
+
+// EnableTracing makes the Sessions log every NBI exchange (see: Trace and
+// NbiTraceOptions). It only affects logins that happen after the call, so
+// call it on startup, before getting any Session.
+func (c *SessionCache) EnableTracing(opts TraceOptions) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.trace = &opts
+}
+
+// UseLookup sets how the Sessions the cache hands out look up OSM IDs
+// (see: Lookup). The default is Lookup.FILTERED.
+func (c *SessionCache) UseLookup(mode u.EnumIx) error {
+	if mode != Lookup.FILTERED && mode != Lookup.CACHED {
+		return fmt.Errorf("unknown lookup mode: %d", mode)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lookup = mode
+	return nil
+}
+
+// UseLookupTTL makes the Sessions the cache hands out refetch the OSM IDs
+// they looked up once the given TTL has elapsed (see: Session.SetLookupTTL).
+// That only makes a difference for Sessions that live longer than the TTL,
+// e.g. a Session processing a large repo in Lookup.CACHED mode. The default
+// of zero means lookups never expire.
+func (c *SessionCache) UseLookupTTL(ttl time.Duration) error {
+	if ttl < 0 {
+		return fmt.Errorf("negative lookup TTL: %v", ttl)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lookupTTL = ttl
+	return nil
+}
+
+// Get returns a Session for the given OSM target, reusing the login of
+// any previous Session for the same target. Like in New, the transport
+// argument is optional, but it only gets used for the first Session
+// of each target.
+func (c *SessionCache) Get(conn Connection, creds UserCredentials,
+	transport ...ReqSender) (*Session, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := targetKey(conn, creds)
+	login, ok := c.logins[key]
+	if !ok {
+		agent := newTransport(c.trace)
+		if len(transport) > 0 {
+			agent = transport[0]
+		}
+
+		store, err := c.newStore(key)
+		if err != nil {
+			return nil, err
+		}
+		authz, err := NewAuthz(conn, creds, agent, store)
+		if err != nil {
+			return nil, err
+		}
+
+		login = &nbiLogin{agent: agent, authz: authz}
+		c.logins[key] = login
+	}
+
+	session := newSession(conn, creds, login.agent, login.authz)
+	session.lookup = c.lookup
+	session.SetLookupTTL(c.lookupTTL)
+	return session, nil
+}
+
+func targetKey(conn Connection, creds UserCredentials) string {
+	target := fmt.Sprintf("%s\n%s\n%s\n%s", conn.Tokens(),
+		creds.Username, creds.Project, creds.Password) // (*)
+	digest := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(digest[:])
+
+	// (*) changing the password should result in a new login. But we
+	// hash the whole lot since the key could end up in a file name.
+}
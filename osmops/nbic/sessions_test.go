@@ -0,0 +1,88 @@
+package nbic
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestSessionCacheSharesLogin(t *testing.T) {
+	nbi := newMockNbi()
+	cache := NewSessionCache(nil)
+
+	s1, err := cache.Get(newConn(), usrCreds, nbi.exchange)
+	if err != nil {
+		t.Fatalf("want: session; got: %v", err)
+	}
+	s2, _ := cache.Get(newConn(), usrCreds, nbi.exchange)
+	if s1 == s2 {
+		t.Errorf("want: new session; got: same session")
+	}
+	if s1.authz != s2.authz {
+		t.Errorf("want: same token manager; got: different")
+	}
+
+	s1.authz.GetAccessToken()
+	s2.authz.GetAccessToken()
+	if len(nbi.exchanges) != 1 {
+		t.Errorf("want: 1 login; got: %d", len(nbi.exchanges))
+	}
+}
+
+func TestSessionCacheSeparatesTargets(t *testing.T) {
+	cache := NewSessionCache(nil)
+	otherCreds := usrCreds
+	otherCreds.Password = "changed"
+
+	s1, _ := cache.Get(newConn(), usrCreds, newMockNbi().exchange)
+	s2, _ := cache.Get(newConn(), otherCreds, newMockNbi().exchange)
+	if s1.authz == s2.authz {
+		t.Errorf("want: different token managers; got: same")
+	}
+}
+
+func TestSessionCacheErrorOnNilTransport(t *testing.T) {
+	cache := NewSessionCache(nil)
+	if session, err := cache.Get(newConn(), usrCreds, nil); err == nil {
+		t.Errorf("want: error; got: %+v", session)
+	}
+}
+
+func TestFileTokenStoresSurviveCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbic-token-stores-test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	nbi := newMockNbi()
+	s1, _ := NewSessionCache(FileTokenStores(dir)).
+		Get(newConn(), usrCreds, nbi.exchange)
+	if _, err := s1.authz.GetAccessToken(); err != nil {
+		t.Fatalf("want: token; got: %v", err)
+	}
+
+	s2, _ := NewSessionCache(FileTokenStores(dir)).
+		Get(newConn(), usrCreds, nbi.exchange)
+	if _, err := s2.authz.GetAccessToken(); err != nil {
+		t.Fatalf("want: token; got: %v", err)
+	}
+	if len(nbi.exchanges) != 1 {
+		t.Errorf("want: 1 login; got: %d", len(nbi.exchanges))
+	}
+}
+
+func TestNbiTraceOptionsHideTokenBodies(t *testing.T) {
+	opts := NbiTraceOptions(nil)
+	urls := newConn()
+
+	tokens, _ := http.NewRequest("POST", urls.Tokens().String(), nil)
+	if !opts.SecretBodies(tokens) {
+		t.Errorf("want: secret token bodies; got: not secret")
+	}
+	nsds, _ := http.NewRequest("GET", urls.NsDescriptors().String(), nil)
+	if opts.SecretBodies(nsds) {
+		t.Errorf("want: NSD bodies not secret; got: secret")
+	}
+}
@@ -0,0 +1,105 @@
+package nbic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+
+	//lint:ignore ST1001 HTTP EDSL is more readable w/o qualified import
+	. "github.com/fluxcd/source-watcher/osmops/util/http"
+)
+
+type pkgVersionView struct { // only the response fields we care about.
+	Id    string `json:"_id"`
+	Name  string `json:"id"`
+	Admin struct {
+		Created float64 `json:"created"`
+	} `json:"_admin"`
+}
+
+type pkgVersionEndpoints struct {
+	list   *url.URL
+	delete updateEndpoint
+	forget func(name string)
+}
+
+func (c *Session) pkgVersionEndpoints(name string) (
+	*pkgVersionEndpoints, error) {
+	if strings.HasSuffix(name, "_knf") {
+		return &pkgVersionEndpoints{
+			list:   c.conn.VnfPackagesContent(),
+			delete: c.conn.VnfPackageContent,
+			forget: c.forgetVnfDescriptor,
+		}, nil
+	}
+	if strings.HasSuffix(name, "_ns") {
+		return &pkgVersionEndpoints{
+			list:   c.conn.NsDescriptors(),
+			delete: c.conn.NsPackageContent,
+			forget: c.forgetNsDescriptor,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported package type: %s", name)
+}
+
+func (c *Session) PrunePackageVersions(ctx context.Context, name string,
+	keep int, keepIds ...string) ([]string, error) {
+	endpoints, err := c.pkgVersionEndpoints(name)
+	if err != nil || keep <= 0 {
+		return nil, err
+	}
+	vs := []pkgVersionView{}
+	if err := c.getJsonList(ctx, endpoints.list, &vs); err != nil {
+		return nil, err
+	}
+
+	versions := []pkgVersionView{}
+	for _, v := range vs {
+		if pkgr.IsVersionOf(v.Name, name) {
+			versions = append(versions, v)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool { // newest first
+		return versions[i].Admin.Created > versions[j].Admin.Created
+	})
+	kept := map[string]bool{}
+	for _, id := range keepIds {
+		kept[id] = true
+	}
+
+	deleted := []string{}
+	for k, v := range versions {
+		if k < keep || kept[v.Name] {
+			continue
+		}
+		err := c.deletePackage(ctx, endpoints.delete(v.Id))
+		if isConflict(err) { // (*)
+			continue
+		}
+		if err != nil && !isNotFound(err) {
+			return deleted, err
+		}
+		endpoints.forget(v.Name)
+		deleted = append(deleted, v.Name)
+	}
+	return deleted, nil
+
+	// (*) OSM won't delete a VNFD an NSD references or an NSD an NS
+	// instance uses. That's fine, we'll try again next time around.
+}
+
+func (c *Session) deletePackage(ctx context.Context, endpoint *url.URL) error {
+	_, err := Request(
+		DELETE, At(endpoint),
+		c.NbiAccessToken(),
+		Accept(MediaType.JSON),
+	).
+		SetHandler(ExpectNbiSuccess(http.MethodDelete, endpoint)).
+		RunWith(ctx, c.transport)
+	return err
+}
@@ -0,0 +1,206 @@
+package nbic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/source-watcher/osmops/pkgr"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+func serveHeldPackage(t *testing.T, nbi *mockNbi, pkgDir string) {
+	pkg, err := pkgr.Pack(findTestDataDir(pkgDir))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+	held, err := io.ReadAll(pkg.Data)
+	if err != nil {
+		t.Fatalf("want: package data; got: %v", err)
+	}
+
+	endpoint := newConn().VnfPackageArchive("4ffdeb67-92e7-46fa-9fa2-331a4d674137")
+	nbi.handlers[handlerKey("GET", endpoint.Path)] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewReader(held)),
+			}, nil
+		}
+}
+
+func TestCreatePackageVersionLeavesExistingOneAlone(t *testing.T) {
+	nbi := newMockNbi()
+	serveHeldPackage(t, nbi, "openldap_knf")
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	outcome, err := nbic.CreatePackageVersion(context.TODO(),
+		findTestDataDir("openldap_knf"))
+	if err != nil {
+		t.Fatalf("want: outcome; got: %v", err)
+	}
+	want := Outcome{Id: "4ffdeb67-92e7-46fa-9fa2-331a4d674137", Unchanged: true}
+	if *outcome != want {
+		t.Errorf("want: %+v; got: %+v", want, *outcome)
+	}
+	if len(nbi.exchanges) != 3 { // #1 = get token
+		t.Errorf("want: one req to lookup package, then one to download it; got: %d",
+			len(nbi.exchanges)-1)
+	}
+}
+
+func TestCreatePackageVersionErrOnChangedContent(t *testing.T) {
+	nbi := newMockNbi()
+	serveHeldPackage(t, nbi, "openldap_knf")
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	tempDir, _ := file.ParseAbsPath(t.TempDir())
+	pkgSrc := tempDir.Join("openldap_knf")
+	if err := file.CopyTree(findTestDataDir("openldap_knf"), pkgSrc); err != nil {
+		t.Fatalf("couldn't copy package: %v", err)
+	}
+	vnfd := pkgSrc.Join("openldap_vnfd.yaml").Value()
+	content, _ := os.ReadFile(vnfd)
+	content = append(content, []byte("# edited\n")...)
+	if err := os.WriteFile(vnfd, content, 0644); err != nil {
+		t.Fatalf("couldn't edit package: %v", err)
+	}
+
+	outcome, err := nbic.CreatePackageVersion(context.TODO(), pkgSrc)
+	if err == nil {
+		t.Fatalf("want: error; got: %+v", outcome)
+	}
+	if !strings.Contains(err.Error(), "version not bumped") {
+		t.Errorf("want: version not bumped; got: %v", err)
+	}
+	for _, rr := range nbi.exchanges {
+		if rr.req.Method != http.MethodGet && rr.req.Method != http.MethodPost {
+			t.Errorf("want: no package upload; got: %s", rr.req.Method)
+		}
+		if rr.req.Method == http.MethodPost &&
+			!strings.HasSuffix(rr.req.URL.Path, "/tokens") {
+			t.Errorf("want: no package upload; got: POST %s", rr.req.URL)
+		}
+	}
+}
+
+func TestCreatePackageVersionUploadsNewVersion(t *testing.T) {
+	nbi := newMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+	versionedId := pkgr.VersionedId("openldap_knf", "2.0")
+
+	outcome, err := nbic.CreatePackageVersion(context.TODO(),
+		findTestDataDir("openldap_knf"),
+		pkgr.WithDescriptorIds(map[string]string{"openldap_knf": versionedId}))
+	if err != nil {
+		t.Fatalf("want: outcome; got: %v", err)
+	}
+	if !outcome.Created || outcome.Id != versionedId {
+		t.Errorf("want: created %s; got: %+v", versionedId, outcome)
+	}
+	if len(nbi.exchanges) != 3 { // #1 = get token
+		t.Fatalf("want: one req to lookup package, then one to create it; got: %d",
+			len(nbi.exchanges)-1)
+	}
+	checkUploadedPackage(t, nbi, nbi.exchanges[2].req, versionedId, versionedId)
+}
+
+var vnfPackageVersions = `[
+    {"_id": "a", "id": "openldap_knf-1.0", "_admin": {"created": 1}},
+    {"_id": "b", "id": "openldap_knf-1.1", "_admin": {"created": 2}},
+    {"_id": "c", "id": "openldap_knf-1.2", "_admin": {"created": 3}},
+    {"_id": "d", "id": "openldap_knf-1.3", "_admin": {"created": 4}},
+    {"_id": "e", "id": "openldap_knf", "_admin": {"created": 0}},
+    {"_id": "f", "id": "dummy_knf-1.0", "_admin": {"created": 0}},
+    {"_id": "g", "id": "openldap_knf-other_knf-1.0", "_admin": {"created": 0}}
+]`
+
+func newPruneMockNbi(inUse ...string) *mockNbi {
+	nbi := newMockNbi()
+	nbi.handlers[handlerKey("GET", "/osm/vnfpkgm/v1/vnf_packages_content")] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       stringReader(vnfPackageVersions),
+			}, nil
+		}
+	nbi.handlers[handlerKey("DELETE", "/osm/vnfpkgm/v1/vnf_packages_content/")] =
+		func(req *http.Request) (*http.Response, error) {
+			for _, id := range inUse {
+				if path.Base(req.URL.Path) == id {
+					return &http.Response{StatusCode: http.StatusConflict}, nil
+				}
+			}
+			return &http.Response{StatusCode: http.StatusNoContent}, nil
+		}
+	return nbi
+}
+
+func deletedPackageIds(nbi *mockNbi) []string {
+	ids := []string{}
+	for _, rr := range nbi.exchanges {
+		if rr.req.Method == http.MethodDelete {
+			ids = append(ids, path.Base(rr.req.URL.Path))
+		}
+	}
+	return ids
+}
+
+func TestPrunePackageVersions(t *testing.T) {
+	nbi := newPruneMockNbi("a")
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	deleted, err := nbic.PrunePackageVersions(context.TODO(), "openldap_knf",
+		1, "openldap_knf-1.1")
+	if err != nil {
+		t.Fatalf("want: pruned versions; got: %v", err)
+	}
+	if want := []string{"openldap_knf-1.2"}; !reflect.DeepEqual(want, deleted) {
+		t.Errorf("want: %v; got: %v", want, deleted)
+	}
+	if want := []string{"c", "a"}; !reflect.DeepEqual(want, deletedPackageIds(nbi)) {
+		t.Errorf("want: %v; got: %v", want, deletedPackageIds(nbi))
+	}
+}
+
+func TestPrunePackageVersionsKeepAll(t *testing.T) {
+	nbi := newPruneMockNbi()
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	deleted, err := nbic.PrunePackageVersions(context.TODO(), "openldap_knf", 0)
+	if err != nil || len(deleted) != 0 {
+		t.Errorf("want: nothing deleted; got: %v, %v", deleted, err)
+	}
+	if len(nbi.exchanges) != 0 {
+		t.Errorf("want: no NBI calls; got: %d", len(nbi.exchanges))
+	}
+}
+
+func TestPrunePackageVersionsNbiError(t *testing.T) {
+	nbi := newPruneMockNbi()
+	nbi.handlers[handlerKey("DELETE", "/osm/vnfpkgm/v1/vnf_packages_content/")] =
+		func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusForbidden}, nil
+		}
+	nbic, _ := New(newConn(), usrCreds, nbi.exchange)
+
+	_, err := nbic.PrunePackageVersions(context.TODO(), "openldap_knf", 2)
+	var nbiErr *NbiError
+	if !errors.As(err, &nbiErr) || nbiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("want: forbidden; got: %v", err)
+	}
+}
+
+func TestPruneUnsupportedPackageVersions(t *testing.T) {
+	nbic, _ := New(newConn(), usrCreds, newMockNbi().exchange)
+	_, err := nbic.PrunePackageVersions(context.TODO(), "unsupported", 1)
+	checkUnsupportedPackageErr(t, err)
+}
@@ -1,7 +1,9 @@
 package nbic
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 )
 
 type vimAccountView struct { // only the response fields we care about.
@@ -12,11 +14,15 @@ type vimAccountView struct { // only the response fields we care about.
 type vimAccountMap map[string]string
 
 func buildVimAccountMap(vs []vimAccountView) vimAccountMap {
-	accountMap := map[string]string{}
+	accountMap := vimAccountMap{}
+	accountMap.add(vs)
+	return accountMap
+}
+
+func (m vimAccountMap) add(vs []vimAccountView) {
 	for _, v := range vs {
-		accountMap[v.Name] = v.Id
+		m[v.Name] = v.Id
 	}
-	return accountMap
 }
 
 // NOTE. VIM account name to ID lookup.
@@ -32,20 +38,38 @@ func buildVimAccountMap(vs []vimAccountView) vimAccountMap {
 // detail: name 'openvim-site' already exists for vim_accounts
 // status: 409
 
-func (c *Session) getVimAccounts() ([]vimAccountView, error) {
+func (c *Session) getVimAccounts(ctx context.Context, endpoint *url.URL) (
+	[]vimAccountView, error) {
 	data := []vimAccountView{}
-	if _, err := c.getJson(c.conn.VimAccounts(), &data); err != nil {
+	if err := c.getJsonList(ctx, endpoint, &data); err != nil {
 		return nil, err
 	}
 	return data, nil
 }
 
-func (c *Session) lookupVimAccountId(name string) (string, error) {
+func (c *Session) fetchVimAccounts(ctx context.Context, name string) error {
+	endpoint := c.conn.VimAccounts()
+	if c.lookup == Lookup.FILTERED {
+		endpoint = withFilter(endpoint, "name", name)
+	}
+	vs, err := c.getVimAccounts(ctx, endpoint)
+	if err != nil {
+		return err
+	}
 	if c.vimAccMap == nil {
-		if vs, err := c.getVimAccounts(); err != nil {
+		c.vimAccMap = buildVimAccountMap(vs)
+	} else {
+		c.vimAccMap.add(vs)
+	}
+	return nil
+}
+
+func (c *Session) lookupVimAccountId(ctx context.Context,
+	name string) (string, error) {
+	c.expireLookups()
+	if _, ok := c.vimAccMap[name]; !ok && c.mustFetch(c.vimAccMap == nil) {
+		if err := c.fetchVimAccounts(ctx, name); err != nil {
 			return "", err
-		} else {
-			c.vimAccMap = buildVimAccountMap(vs)
 		}
 	}
 	if id, ok := c.vimAccMap[name]; !ok {
@@ -1,6 +1,7 @@
 package nbic
 
 import (
+	"context"
 	"testing"
 )
 
@@ -8,7 +9,7 @@ func TestLookupVimAccIdUseCachedData(t *testing.T) {
 	nbic := &Session{
 		vimAccMap: map[string]string{"silly_vim": "324567"},
 	}
-	id, err := nbic.lookupVimAccountId("silly_vim")
+	id, err := nbic.lookupVimAccountId(context.TODO(), "silly_vim")
 	if err != nil {
 		t.Errorf("want: 324567; got: %v", err)
 	}
@@ -21,7 +22,7 @@ func TestLookupVimAccIdErrorOnMiss(t *testing.T) {
 	nbic := &Session{
 		vimAccMap: map[string]string{"silly_vim": "324567"},
 	}
-	if _, err := nbic.lookupVimAccountId("not there!"); err == nil {
+	if _, err := nbic.lookupVimAccountId(context.TODO(), "not there!"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -32,7 +33,7 @@ func TestLookupVimAccIdFetchDataFromServer(t *testing.T) {
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
 	wantId := "4a4425f7-3e72-4d45-a4ec-4241186f3547"
-	if gotId, err := nbic.lookupVimAccountId("mylocation1"); err != nil {
+	if gotId, err := nbic.lookupVimAccountId(context.TODO(), "mylocation1"); err != nil {
 		t.Errorf("want: %s; got: %v", wantId, err)
 	} else {
 		if gotId != wantId {
@@ -57,7 +58,7 @@ func TestLookupVimAccIdFetchDataFromServerTokenError(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, UserCredentials{}, nbi.exchange)
 
-	if _, err := nbic.lookupVimAccountId("mylocation1"); err == nil {
+	if _, err := nbic.lookupVimAccountId(context.TODO(), "mylocation1"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 
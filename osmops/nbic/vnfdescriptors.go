@@ -1,7 +1,9 @@
 package nbic
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 )
 
 type vnfDescView struct { // only the response fields we care about.
@@ -12,11 +14,15 @@ type vnfDescView struct { // only the response fields we care about.
 type vnfDescMap map[string]string
 
 func buildVnfDescMap(ds []vnfDescView) vnfDescMap {
-	descMap := map[string]string{}
+	descMap := vnfDescMap{}
+	descMap.add(ds)
+	return descMap
+}
+
+func (m vnfDescMap) add(ds []vnfDescView) {
 	for _, d := range ds {
-		descMap[d.Name] = d.Id
+		m[d.Name] = d.Id
 	}
-	return descMap
 }
 
 // NOTE. VNFD name to ID lookup.
@@ -33,18 +39,36 @@ func buildVnfDescMap(ds []vnfDescView) vnfDescMap {
 //     "detail": "vnfd with id 'openldap_knf' already exists for this project"
 // }
 
-func (c *Session) getVnfDescriptors() ([]vnfDescView, error) {
+func (c *Session) getVnfDescriptors(ctx context.Context, endpoint *url.URL) (
+	[]vnfDescView, error) {
 	data := []vnfDescView{}
-	_, err := c.getJson(c.conn.VnfPackagesContent(), &data)
+	err := c.getJsonList(ctx, endpoint, &data)
 	return data, err
 }
 
-func (c *Session) lookupVnfDescriptorId(name string) (string, error) {
+func (c *Session) fetchVnfDescriptors(ctx context.Context, name string) error {
+	endpoint := c.conn.VnfPackagesContent()
+	if c.lookup == Lookup.FILTERED {
+		endpoint = withFilter(endpoint, "id", name)
+	}
+	ds, err := c.getVnfDescriptors(ctx, endpoint)
+	if err != nil {
+		return err
+	}
 	if c.vnfdMap == nil {
-		if ds, err := c.getVnfDescriptors(); err != nil {
+		c.vnfdMap = buildVnfDescMap(ds)
+	} else {
+		c.vnfdMap.add(ds)
+	}
+	return nil
+}
+
+func (c *Session) lookupVnfDescriptorId(ctx context.Context,
+	name string) (string, error) {
+	c.expireLookups()
+	if _, ok := c.vnfdMap[name]; !ok && c.mustFetch(c.vnfdMap == nil) {
+		if err := c.fetchVnfDescriptors(ctx, name); err != nil {
 			return "", err
-		} else {
-			c.vnfdMap = buildVnfDescMap(ds)
 		}
 	}
 	if id, ok := c.vnfdMap[name]; !ok {
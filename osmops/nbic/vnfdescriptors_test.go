@@ -1,6 +1,7 @@
 package nbic
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -9,7 +10,7 @@ func TestLookupVnfDescIdUseCachedData(t *testing.T) {
 	nbic := &Session{
 		vnfdMap: map[string]string{"silly_ns": "324567"},
 	}
-	id, err := nbic.lookupVnfDescriptorId("silly_ns")
+	id, err := nbic.lookupVnfDescriptorId(context.TODO(), "silly_ns")
 	if err != nil {
 		t.Errorf("want: 324567; got: %v", err)
 	}
@@ -22,7 +23,7 @@ func TestLookupVnfDescIdErrorOnMiss(t *testing.T) {
 	nbic := &Session{
 		vnfdMap: map[string]string{"silly_ns": "324567"},
 	}
-	if _, err := nbic.lookupVnfDescriptorId("not there!"); err == nil {
+	if _, err := nbic.lookupVnfDescriptorId(context.TODO(), "not there!"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 }
@@ -33,7 +34,7 @@ func TestLookupVnfDescIdFetchDataFromServer(t *testing.T) {
 	nbic, _ := New(urls, usrCreds, nbi.exchange)
 
 	wantId := "4ffdeb67-92e7-46fa-9fa2-331a4d674137"
-	if gotId, err := nbic.lookupVnfDescriptorId("openldap_knf"); err != nil {
+	if gotId, err := nbic.lookupVnfDescriptorId(context.TODO(), "openldap_knf"); err != nil {
 		t.Errorf("want: %s; got: %v", wantId, err)
 	} else {
 		if gotId != wantId {
@@ -58,7 +59,7 @@ func TestLookupVnfDescIdFetchDataFromServerTokenError(t *testing.T) {
 	urls := newConn()
 	nbic, _ := New(urls, UserCredentials{}, nbi.exchange)
 
-	if _, err := nbic.lookupVnfDescriptorId("openldap_knf"); err == nil {
+	if _, err := nbic.lookupVnfDescriptorId(context.TODO(), "openldap_knf"); err == nil {
 		t.Errorf("want: error; got: nil")
 	}
 
@@ -78,8 +78,8 @@ func TestPackOpenLdapNested(t *testing.T) {
 func verifyPackage(t *testing.T, wantName, wantHash, wantChecksum string,
 	wantPaths []string) {
 	source := findTestDataDir(wantName)
-	epochStart := time.Unix(0, 0)                             // (*) see NOTE
-	pkg, err := doPack(source, tgz.WithEntryTime(epochStart)) // (*) see NOTE
+	epochStart := time.Unix(0, 0)                                             // (*) see NOTE
+	pkg, err := doPack(source, makePackOpts(), tgz.WithEntryTime(epochStart)) // (*) see NOTE
 	if err != nil {
 		t.Fatalf("want: no error; got: %v", err)
 	}
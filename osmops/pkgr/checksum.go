@@ -2,34 +2,104 @@ package pkgr
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"strings"
 
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/bytez"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
 const ChecksumFileName = "checksums.txt"
 
+// HashAlgorithm enumerates the hash functions Pack can use to build the
+// checksum files in a package. MD5 is what OSM expects, so Pack always
+// writes an MD5 checksum file and uses MD5 for the package hash. The
+// other algorithms are there for those who need stronger hashes, e.g. to
+// sign the package. (See: WithChecksums)
+var HashAlgorithm = struct {
+	u.StrEnum
+	MD5, SHA256, SHA512 u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("md5", "sha256", "sha512"),
+	MD5:     0,
+	SHA256:  1,
+	SHA512:  2,
+}
+
+func newHash(alg u.EnumIx) hash.Hash {
+	switch alg {
+	case HashAlgorithm.SHA256:
+		return sha256.New()
+	case HashAlgorithm.SHA512:
+		return sha512.New()
+	default:
+		return md5.New()
+	}
+}
+
+// ChecksumFileNameFor returns the name of the checksum file holding the
+// hashes computed with the given algorithm. That's ChecksumFileName for
+// MD5 and "checksums.<alg>.txt" for any other algorithm, e.g.
+// "checksums.sha256.txt".
+func ChecksumFileNameFor(alg u.EnumIx) string {
+	if alg == HashAlgorithm.MD5 {
+		return ChecksumFileName
+	}
+	return fmt.Sprintf("checksums.%s.txt", HashAlgorithm.LabelOf(alg))
+}
+
 func md5string(data []byte) string {
 	hash := md5.Sum(data)
 	return fmt.Sprintf("%x", hash)
 }
 
 func computeChecksum(target file.AbsPath) (string, error) {
-	content, err := os.ReadFile(target.Value())
+	hashes, err := computeChecksums(target, HashAlgorithm.MD5)
 	if err != nil {
 		return "", err
 	}
-	return md5string(content), nil
+	return hashes[HashAlgorithm.MD5], nil
+}
+
+// computeChecksums hashes the target file with each of the given algorithms
+// reading the file only once.
+func computeChecksums(target file.AbsPath, algs ...u.EnumIx) (
+	map[u.EnumIx]string, error) {
+	fd, err := os.Open(target.Value())
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	hashes := map[u.EnumIx]hash.Hash{}
+	sinks := []io.Writer{}
+	for _, alg := range algs {
+		if _, ok := hashes[alg]; !ok {
+			hashes[alg] = newHash(alg)
+			sinks = append(sinks, hashes[alg])
+		}
+	}
+	if _, err := io.Copy(io.MultiWriter(sinks...), fd); err != nil {
+		return nil, err
+	}
+
+	digests := map[u.EnumIx]string{}
+	for alg, h := range hashes {
+		digests[alg] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return digests, nil
 }
 
-func writeCheckSumFileContent(src PackageSource) io.Reader {
+func writeCheckSumFileContent(src PackageSource, alg u.EnumIx) io.Reader {
 	buf := bytez.NewBuffer()
 	for _, filePath := range src.SortedFilePaths() {
-		hash := src.FileHash(filePath)
+		hash := src.FileDigest(filePath, alg)
 		line := fmt.Sprintf("%s\t%s\n", hash, filePath)
 		io.Copy(buf, strings.NewReader(line))
 	}
@@ -0,0 +1,261 @@
+package pkgr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+// PackageFormat enumerates the package formats Pack can build.
+//
+// OSM is OSM's own format, a gzipped tar with the package source files in
+// a root directory named after the package plus the checksum files. (See:
+// Package) CSAR is the ETSI SOL004 format, a zip with the package source
+// files at the archive root plus a TOSCA metadata file pointing to the
+// package descriptor and a manifest file listing the digest of each file
+// in the package. (See: ToscaMetaFilePath)
+var PackageFormat = struct {
+	u.StrEnum
+	OSM, CSAR u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("osm", "csar"),
+	OSM:     0,
+	CSAR:    1,
+}
+
+// ToscaMetaFilePath is the path, within a CSAR package, of the TOSCA
+// metadata file. The file names the package descriptor, i.e. the entry
+// definitions, and the package manifest file.
+const ToscaMetaFilePath = "TOSCA-Metadata/TOSCA.meta"
+
+// CsarManifestFileName returns the name of the manifest file Pack puts at
+// the root of a CSAR package with the given name, e.g. "my-pkg.mf".
+func CsarManifestFileName(pkgName string) string {
+	return pkgName + ".mf"
+}
+
+// WithPackageFormat makes Pack build the package in the given format
+// rather than in the OSM one. (See: PackageFormat)
+func WithPackageFormat(format u.EnumIx) PackOption {
+	return func(opts *packOpts) {
+		opts.format = format
+	}
+}
+
+// csarDigestAlgorithm is the hash algorithm for the CSAR manifest digests.
+// SOL004 wants SHA-256 or stronger, so that's SHA-512 if you asked for it
+// through WithChecksums, SHA-256 otherwise.
+func (opts *packOpts) csarDigestAlgorithm() u.EnumIx {
+	for _, alg := range opts.checksums {
+		if alg == HashAlgorithm.SHA512 {
+			return alg
+		}
+	}
+	return HashAlgorithm.SHA256
+}
+
+func (opts *packOpts) csarReleaseTime() time.Time {
+	if opts.reproducible {
+		return opts.entryTime.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// csarEntry is what we know about the package descriptor a CSAR package
+// points to.
+type csarEntry struct {
+	path     string // relative to the package root
+	metadata [][2]string
+}
+
+type csarDescriptorView struct { // only the fields we care about.
+	Vnfd *struct {
+		Id          string `yaml:"id"`
+		Provider    string `yaml:"provider"`
+		ProductName string `yaml:"product-name"`
+		Version     string `yaml:"version"`
+	} `yaml:"vnfd"`
+	Nsd *struct {
+		Nsd []struct {
+			Id       string `yaml:"id"`
+			Name     string `yaml:"name"`
+			Designer string `yaml:"designer"`
+			Version  string `yaml:"version"`
+		} `yaml:"nsd"`
+	} `yaml:"nsd"`
+}
+
+func writeCsarData(source *pkgSrc, sink io.WriteCloser, cfg *packOpts,
+	opts ...tgz.WriterOption) error {
+	if cfg.signingKey != nil {
+		return fmt.Errorf("can't sign CSAR packages")
+	}
+	writer, err := tgz.NewWriter("", sink, opts...) // (*)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	if err := collectPackageItems(source, writer); err != nil {
+		return err
+	}
+	entry, err := findCsarEntry(source, cfg.csarReleaseTime())
+	if err != nil {
+		return err
+	}
+
+	manifestName := CsarManifestFileName(source.DirectoryName())
+	meta := writeToscaMeta(entry, manifestName)
+	alg := cfg.csarDigestAlgorithm()
+	manifest := writeCsarManifest(source, entry, alg, meta)
+	err = writer.AddEntry(ToscaMetaFilePath, bytes.NewReader(meta))
+	if err != nil {
+		return err
+	}
+	return writer.AddEntry(manifestName, bytes.NewReader(manifest))
+
+	// (*) SOL004 wants TOSCA-Metadata at the archive root, so no root dir.
+}
+
+// csarPath turns a path returned by PackageSource.SortedFilePaths into
+// the path of the file within the CSAR archive.
+func csarPath(source *pkgSrc, filePath string) string {
+	return strings.TrimPrefix(filePath, source.DirectoryName()+"/")
+}
+
+// findCsarEntry looks for the VNFD or NSD named after the package or, if
+// there's none, the only descriptor in the package.
+func findCsarEntry(source *pkgSrc, released time.Time) (*csarEntry, error) {
+	found := []*csarEntry{}
+	for _, filePath := range source.SortedFilePaths() {
+		relPath := csarPath(source, filePath)
+		name := strings.ToLower(relPath)
+		if isInArtefactDir(relPath) || !(strings.HasSuffix(name, ".yaml") ||
+			strings.HasSuffix(name, ".yml")) {
+			continue
+		}
+		content, err := source.FileContent(filePath)
+		if err != nil {
+			return nil, err
+		}
+		entry, id := readCsarEntry(relPath, content, released,
+			source.DirectoryName())
+		if entry == nil {
+			continue
+		}
+		if id == source.DirectoryName() {
+			return entry, nil
+		}
+		found = append(found, entry)
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return nil, fmt.Errorf(
+		"%v: can't tell which descriptor is the CSAR entry definitions",
+		source.Directory())
+}
+
+// readCsarEntry reads the CSAR metadata off the VNFD or NSD in content.
+// An NSD file can hold a catalogue of NSDs, in which case we pick the one
+// named after the package or, if there's none, the first one.
+//
+// NOTE. NSD file structure version. SOL004 wants the version of the NSD
+// file structure in the manifest, not the version of the NSD itself. OSM
+// descriptors don't tell, so we leave it out.
+func readCsarEntry(relPath string, content []byte, released time.Time,
+	pkgName string) (*csarEntry, string) {
+	view := csarDescriptorView{}
+	if err := yaml.Unmarshal(content, &view); err != nil {
+		return nil, "" // not a descriptor
+	}
+	releaseTime := released.Format(time.RFC3339)
+	if view.Vnfd != nil && view.Vnfd.Id != "" {
+		return &csarEntry{
+			path: relPath,
+			metadata: [][2]string{
+				{"vnf_provider_id", view.Vnfd.Provider},
+				{"vnf_product_name", view.Vnfd.ProductName},
+				{"vnf_release_date_time", releaseTime},
+				{"vnf_package_version", view.Vnfd.Version},
+			},
+		}, view.Vnfd.Id
+	}
+	if view.Nsd == nil {
+		return nil, ""
+	}
+	found := -1
+	for k, nsd := range view.Nsd.Nsd {
+		if nsd.Id == pkgName {
+			found = k
+			break
+		}
+		if found < 0 && nsd.Id != "" {
+			found = k
+		}
+	}
+	if found < 0 {
+		return nil, ""
+	}
+	nsd := view.Nsd.Nsd[found]
+	return &csarEntry{
+		path: relPath,
+		metadata: [][2]string{
+			{"nsd_designer", nsd.Designer},
+			{"nsd_invariant_id", nsd.Id},
+			{"nsd_name", nsd.Name},
+			{"nsd_release_date_time", releaseTime},
+		},
+	}, nsd.Id
+}
+
+func writeToscaMeta(entry *csarEntry, manifestName string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "TOSCA-Meta-File-Version: 1.0")
+	fmt.Fprintln(buf, "CSAR-Version: 1.1")
+	fmt.Fprintln(buf, "Created-By: OSM Ops")
+	fmt.Fprintf(buf, "Entry-Definitions: %s\n", entry.path)
+	fmt.Fprintf(buf, "ETSI-Entry-Manifest: %s\n", manifestName)
+	return buf.Bytes()
+}
+
+var csarAlgorithmNames = map[u.EnumIx]string{
+	HashAlgorithm.SHA256: "SHA-256",
+	HashAlgorithm.SHA512: "SHA-512",
+}
+
+// writeCsarManifest lists the metadata of the package descriptor and then
+// the digest of each file in the package, TOSCA metadata file included.
+// Metadata without a value in the descriptor are left out.
+func writeCsarManifest(source *pkgSrc, entry *csarEntry, alg u.EnumIx,
+	toscaMeta []byte) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "metadata:")
+	for _, kv := range entry.metadata {
+		if kv[1] != "" {
+			fmt.Fprintf(buf, "%s: %s\n", kv[0], kv[1])
+		}
+	}
+
+	writeSource := func(filePath, digest string) {
+		fmt.Fprintf(buf, "\nSource: %s\n", filePath)
+		fmt.Fprintf(buf, "Algorithm: %s\n", csarAlgorithmNames[alg])
+		fmt.Fprintf(buf, "Hash: %s\n", digest)
+	}
+	for _, filePath := range source.SortedFilePaths() {
+		writeSource(csarPath(source, filePath),
+			source.FileDigest(filePath, alg))
+	}
+	metaHash := newHash(alg)
+	metaHash.Write(toscaMeta)
+	writeSource(ToscaMetaFilePath, fmt.Sprintf("%x", metaHash.Sum(nil)))
+
+	return buf.Bytes()
+}
@@ -0,0 +1,185 @@
+package pkgr
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+func csarFiles(t *testing.T, pkg *Package) map[string][]byte {
+	data, err := pkg.OpenData()
+	if err != nil {
+		t.Fatalf("couldn't open package data: %v", err)
+	}
+	reader, err := tgz.NewFormatReader(tgz.Format.ZIP, data)
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	files := map[string][]byte{}
+	err = reader.IterateEntries(
+		func(archivePath string, fi os.FileInfo, content io.Reader) error {
+			if fi.Mode().IsRegular() {
+				files[archivePath], err = io.ReadAll(content)
+			}
+			return err
+		})
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	return files
+}
+
+func packCsar(t *testing.T, dataDirName string, opts ...PackOption) *Package {
+	opts = append(opts, WithPackageFormat(PackageFormat.CSAR))
+	pkg, err := Pack(findTestDataDir(dataDirName), opts...)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	t.Cleanup(func() { pkg.Data.Close() })
+	return pkg
+}
+
+func TestPackKnfCsar(t *testing.T) {
+	pkg := packCsar(t, "openldap_knf")
+	if pkg.Format != PackageFormat.CSAR || pkg.FileName() != "openldap_knf.zip" {
+		t.Errorf("want: openldap_knf.zip; got: %s", pkg.FileName())
+	}
+
+	files := csarFiles(t, pkg)
+	paths := []string{}
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	wantPaths := []string{
+		ToscaMetaFilePath, "openldap_knf.mf", "openldap_vnfd.yaml",
+	}
+	if !reflect.DeepEqual(wantPaths, paths) {
+		t.Errorf("want: %v; got: %v", wantPaths, paths)
+	}
+
+	wantMeta := "TOSCA-Meta-File-Version: 1.0\n" +
+		"CSAR-Version: 1.1\n" +
+		"Created-By: OSM Ops\n" +
+		"Entry-Definitions: openldap_vnfd.yaml\n" +
+		"ETSI-Entry-Manifest: openldap_knf.mf\n"
+	if got := string(files[ToscaMetaFilePath]); got != wantMeta {
+		t.Errorf("want: %s; got: %s", wantMeta, got)
+	}
+
+	vnfdHash := sha256.Sum256(files["openldap_vnfd.yaml"])
+	metaHash := sha256.Sum256(files[ToscaMetaFilePath])
+	wantManifest := "metadata:\n" +
+		"vnf_provider_id: Telefonica\n" +
+		"vnf_product_name: openldap_knf\n" +
+		"vnf_release_date_time: 1970-01-01T00:00:00Z\n" +
+		"vnf_package_version: 1.0\n" +
+		"\nSource: openldap_vnfd.yaml\nAlgorithm: SHA-256\n" +
+		fmt.Sprintf("Hash: %x\n", vnfdHash) +
+		"\nSource: TOSCA-Metadata/TOSCA.meta\nAlgorithm: SHA-256\n" +
+		fmt.Sprintf("Hash: %x\n", metaHash)
+	if got := string(files["openldap_knf.mf"]); got != wantManifest {
+		t.Errorf("want: %s; got: %s", wantManifest, got)
+	}
+}
+
+func TestPackNsCsar(t *testing.T) {
+	files := csarFiles(t, packCsar(t, "openldap_ns"))
+
+	meta := string(files[ToscaMetaFilePath])
+	if !strings.Contains(meta, "Entry-Definitions: openldap_nsd.yaml\n") {
+		t.Errorf("want: nsd entry definitions; got: %s", meta)
+	}
+	manifest := string(files["openldap_ns.mf"])
+	for _, want := range []string{
+		"nsd_designer: OSM\n", "nsd_invariant_id: openldap_ns\n",
+		"nsd_name: openldap_ns\n", "\nSource: README.md\n",
+	} {
+		if !strings.Contains(manifest, want) {
+			t.Errorf("want: %q in manifest; got: %s", want, manifest)
+		}
+	}
+	if strings.Contains(manifest, "nsd_file_structure_version") {
+		t.Errorf("want: no nsd file structure version; got: %s", manifest)
+	}
+}
+
+func TestReadCsarEntryPicksNsdNamedAfterPackage(t *testing.T) {
+	content := []byte(`
+nsd:
+  nsd:
+  - id: other_ns
+    name: other
+  - id: my_ns
+    name: mine
+    designer: me
+`)
+	released := time.Unix(0, 0).UTC()
+	entry, id := readCsarEntry("nsd.yaml", content, released, "my_ns")
+	if entry == nil || id != "my_ns" {
+		t.Fatalf("want: my_ns; got: %s", id)
+	}
+	want := [][2]string{
+		{"nsd_designer", "me"},
+		{"nsd_invariant_id", "my_ns"},
+		{"nsd_name", "mine"},
+		{"nsd_release_date_time", "1970-01-01T00:00:00Z"},
+	}
+	if !reflect.DeepEqual(want, entry.metadata) {
+		t.Errorf("want: %v; got: %v", want, entry.metadata)
+	}
+
+	entry, id = readCsarEntry("nsd.yaml", content, released, "nope")
+	if entry == nil || id != "other_ns" {
+		t.Errorf("want: other_ns candidate; got: %s", id)
+	}
+}
+
+func TestPackCsarWithSha512Digests(t *testing.T) {
+	pkg := packCsar(t, "openldap_knf", WithChecksums(HashAlgorithm.SHA512))
+	files := csarFiles(t, pkg)
+
+	vnfdHash := sha512.Sum512(files["openldap_vnfd.yaml"])
+	want := fmt.Sprintf("Algorithm: SHA-512\nHash: %x\n", vnfdHash)
+	manifest := string(files["openldap_knf.mf"])
+	if !strings.Contains(manifest, want) {
+		t.Errorf("want: %q in manifest; got: %s", want, manifest)
+	}
+}
+
+func TestPackCsarErrOnManyCandidateEntries(t *testing.T) {
+	csar := WithPackageFormat(PackageFormat.CSAR)
+	if _, err := Pack(findTestDataDir("openldap_nested"), csar); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestPackCsarErrOnSigningKey(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(nil)
+	csar := WithPackageFormat(PackageFormat.CSAR)
+	_, err := Pack(findTestDataDir("openldap_knf"), csar, WithSigningKey(key))
+	if err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestOsmPackageFileName(t *testing.T) {
+	pkg, err := Pack(findTestDataDir("openldap_knf"))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+	if pkg.FileName() != "openldap_knf.tar.gz" {
+		t.Errorf("want: openldap_knf.tar.gz; got: %s", pkg.FileName())
+	}
+}
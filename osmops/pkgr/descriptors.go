@@ -0,0 +1,141 @@
+package pkgr
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// Descriptors holds the IDs of the OSM descriptors defined in a package.
+type Descriptors struct {
+	// IDs of the VNFDs defined in the package.
+	Vnfds []string
+	// IDs of the NSDs defined in the package, each mapped to the IDs of
+	// the VNFDs it references.
+	Nsds map[string][]string
+	// The version of each VNFD and NSD defined in the package, keyed by
+	// descriptor ID. Descriptors without a version aren't in the map.
+	Versions map[string]string
+	// SHA-256 of the package content, hex-encoded. It covers the path and
+	// content of each file Pack would archive, so it changes if and only
+	// if any of those files does.
+	Digest string
+}
+
+// IsEmpty tells if no VNFD or NSD was found in the package.
+func (d *Descriptors) IsEmpty() bool {
+	return len(d.Vnfds) == 0 && len(d.Nsds) == 0
+}
+
+type descriptorView struct { // only the fields we care about.
+	Vnfd *vnfdView       `yaml:"vnfd"`
+	Nsd  *nsdCatalogView `yaml:"nsd"`
+}
+
+type vnfdView struct {
+	Id      string `yaml:"id"`
+	Version string `yaml:"version"`
+}
+
+type nsdCatalogView struct {
+	Nsd []nsdView `yaml:"nsd"`
+}
+
+type nsdView struct {
+	Id      string   `yaml:"id"`
+	VnfdIds []string `yaml:"vnfd-id"`
+	Version string   `yaml:"version"`
+}
+
+// ReadDescriptors looks for VNFDs and NSDs in the YAML files found in
+// the given package source directory and its sub-directories.
+//
+// ReadDescriptors only understands the OSM SOL006 descriptor layout,
+// i.e. a top-level "vnfd" object with an "id" field for a VNFD and a
+// top-level "nsd" object with a list of NSDs in its "nsd" field. Each
+// NSD in the list has an "id" field and a "vnfd-id" field listing the
+// IDs of the VNFDs the NSD references. Both VNFDs and NSDs can have a
+// "version" field.
+// ReadDescriptors skips YAML files it can't parse as descriptors---e.g.
+// Helm values files---but returns an error if it can't scan the source
+// directory or read a file in it. Like Pack, ReadDescriptors skips ignored
+// files, so pass in the same options you'd pass to Pack. Likewise, if
+// there's a package manifest, ReadDescriptors reads the rendered templates.
+// (See: Manifest)
+func ReadDescriptors(source file.AbsPath, opts ...PackOption) (
+	*Descriptors, error) {
+	staged, err := stageSource(source, makePackOpts(opts...))
+	if err != nil {
+		return nil, err
+	}
+	defer staged.cleanup()
+
+	descs := &Descriptors{
+		Vnfds:    []string{},
+		Nsds:     map[string][]string{},
+		Versions: map[string]string{},
+	}
+	digest := sha256.New()
+	scanner := file.NewTreeScanner(staged.dir) // (*)
+	es := scanner.Visit(staged.ignores.Filter(func(node file.TreeNode) error {
+		if !node.FsMeta.Mode().IsRegular() || isPackageIgnoreFile(node) {
+			return nil
+		}
+		content, err := os.ReadFile(node.NodePath.Value())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(digest, "%s\x00%x\n", node.RelPath, sha256.Sum256(content))
+		if isYamlFile(node) {
+			collectDescriptors(content, descs)
+		}
+		return nil
+	}))
+	if len(es) > 0 {
+		return nil, es[0]
+	}
+	descs.Digest = fmt.Sprintf("%x", digest.Sum(nil))
+	return descs, nil
+
+	// (*) TreeScanner visits files in lexical order, so the digest doesn't
+	// depend on the order the file system lists them in.
+}
+
+func isYamlFile(node file.TreeNode) bool {
+	if !node.FsMeta.Mode().IsRegular() {
+		return false
+	}
+	name := strings.ToLower(node.FsMeta.Name())
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+func collectDescriptors(content []byte, descs *Descriptors) {
+	view := descriptorView{}
+	if err := yaml.Unmarshal(content, &view); err != nil {
+		return // not a descriptor
+	}
+	if view.Vnfd != nil && view.Vnfd.Id != "" {
+		descs.Vnfds = append(descs.Vnfds, view.Vnfd.Id)
+		descs.addVersion(view.Vnfd.Id, view.Vnfd.Version)
+	}
+	if view.Nsd != nil {
+		for _, nsd := range view.Nsd.Nsd {
+			if nsd.Id != "" {
+				descs.Nsds[nsd.Id] = append(descs.Nsds[nsd.Id],
+					nsd.VnfdIds...)
+				descs.addVersion(nsd.Id, nsd.Version)
+			}
+		}
+	}
+}
+
+func (d *Descriptors) addVersion(id, version string) {
+	if version = strings.TrimSpace(version); version != "" {
+		d.Versions[id] = version
+	}
+}
@@ -0,0 +1,116 @@
+package pkgr
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+func TestReadKnfDescriptors(t *testing.T) {
+	descs, err := ReadDescriptors(findTestDataDir("openldap_knf"))
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	if want := []string{"openldap_knf"}; !reflect.DeepEqual(want, descs.Vnfds) {
+		t.Errorf("want: %v; got: %v", want, descs.Vnfds)
+	}
+	if len(descs.Nsds) != 0 {
+		t.Errorf("want: no nsd; got: %v", descs.Nsds)
+	}
+}
+
+func TestReadNsDescriptors(t *testing.T) {
+	descs, err := ReadDescriptors(findTestDataDir("openldap_ns"))
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	if len(descs.Vnfds) != 0 {
+		t.Errorf("want: no vnfd; got: %v", descs.Vnfds)
+	}
+	want := map[string][]string{"openldap_ns": {"openldap_knf"}}
+	if !reflect.DeepEqual(want, descs.Nsds) {
+		t.Errorf("want: %v; got: %v", want, descs.Nsds)
+	}
+}
+
+func TestReadNestedDescriptors(t *testing.T) {
+	descs, err := ReadDescriptors(findTestDataDir("openldap_nested"))
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	if descs.IsEmpty() {
+		t.Fatalf("want: descriptors; got: empty")
+	}
+	if want := []string{"openldap_knf"}; !reflect.DeepEqual(want, descs.Vnfds) {
+		t.Errorf("want: %v; got: %v", want, descs.Vnfds)
+	}
+	if _, ok := descs.Nsds["openldap_ns"]; !ok {
+		t.Errorf("want: openldap_ns; got: %v", descs.Nsds)
+	}
+}
+
+func TestReadDescriptorsDigestFollowsContent(t *testing.T) {
+	tempDir, _ := file.ParseAbsPath(t.TempDir())
+	source := tempDir.Join("openldap_knf")
+	if err := file.CopyTree(findTestDataDir("openldap_knf"), source); err != nil {
+		t.Fatalf("couldn't copy package: %v", err)
+	}
+	digest := func() string {
+		descs, err := ReadDescriptors(source)
+		if err != nil {
+			t.Fatalf("want: descriptors; got: %v", err)
+		}
+		return descs.Digest
+	}
+	write := func(name, content string) {
+		err := os.WriteFile(source.Join(name).Value(), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("couldn't write %s: %v", name, err)
+		}
+	}
+
+	original := digest()
+	if len(original) != 64 {
+		t.Errorf("want: sha256 hex digest; got: %s", original)
+	}
+	if got := digest(); got != original {
+		t.Errorf("want: %s; got: %s", original, got)
+	}
+
+	write(IgnoreFileName, "*.log\n")
+	withIgnoreFile := digest()
+	if withIgnoreFile != original {
+		t.Errorf("want: ignore file left out; got: %s", withIgnoreFile)
+	}
+	write("build.log", "noise")
+	if got := digest(); got != original {
+		t.Errorf("want: ignored files left out; got: %s", got)
+	}
+
+	write("README.md", "hello")
+	if got := digest(); got == original {
+		t.Errorf("want: new digest on new file; got: %s", got)
+	}
+}
+
+func TestReadDescriptorsErrOnMissingDir(t *testing.T) {
+	source, _ := file.ParseAbsPath("not/there")
+	if _, err := ReadDescriptors(source); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestCollectDescriptorsSkipNonDescriptorYaml(t *testing.T) {
+	descs := &Descriptors{
+		Vnfds: []string{}, Nsds: map[string][]string{},
+		Versions: map[string]string{},
+	}
+	collectDescriptors([]byte("vnfd: [1, 2]"), descs)
+	collectDescriptors([]byte("replicaCount: 2"), descs)
+	collectDescriptors([]byte("{{ not yaml"), descs)
+	if !descs.IsEmpty() {
+		t.Errorf("want: empty; got: %+v", descs)
+	}
+}
@@ -0,0 +1,37 @@
+package pkgr
+
+import (
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// IgnoreFileName is the name of the file listing, in gitignore syntax,
+// the files to leave out of a package---e.g. editor backups or local test
+// values. Pack looks for it in the package source directory, but you can
+// also pass in other ignore files, e.g. a global one in the OsmOps target
+// directory, through WithIgnoreFile. Patterns are relative to the directory
+// containing the ignore file and the package's own ignore file takes
+// precedence over any other. The package's ignore file itself never makes
+// it into the package.
+const IgnoreFileName = ".osmopsignore"
+
+func loadIgnoreList(source file.AbsPath, opts *packOpts) (
+	file.IgnoreList, error) {
+	ignoreFiles := append([]file.AbsPath{}, opts.ignoreFiles...)
+	ignoreFiles = append(ignoreFiles, source.Join(IgnoreFileName)) // (*)
+
+	ignores := file.IgnoreList{}
+	for _, ignoreFile := range ignoreFiles {
+		rules, err := file.LoadIgnoreFile(ignoreFile)
+		if err != nil {
+			return nil, err
+		}
+		ignores = append(ignores, rules)
+	}
+	return ignores, nil
+
+	// (*) last, so its rules take precedence.
+}
+
+func isPackageIgnoreFile(node file.TreeNode) bool {
+	return node.RelPath == IgnoreFileName
+}
@@ -0,0 +1,144 @@
+package pkgr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+func writeIgnoreFile(t *testing.T, dir file.AbsPath, lines ...string) file.AbsPath {
+	ignoreFile := dir.Join(IgnoreFileName)
+	content := []byte(strings.Join(lines, "\n"))
+	if err := os.WriteFile(ignoreFile.Value(), content, 0644); err != nil {
+		t.Fatalf("couldn't write ignore file: %v", err)
+	}
+	return ignoreFile
+}
+
+func archiveEntries(t *testing.T, pkg *Package) []string {
+	data, err := pkg.OpenData()
+	if err != nil {
+		t.Fatalf("couldn't open package data: %v", err)
+	}
+	reader, err := tgz.NewReader(data)
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	entries := []string{}
+	err = reader.IterateEntries(
+		func(archivePath string, fi os.FileInfo, content io.Reader) error {
+			if !fi.IsDir() {
+				entries = append(entries, archivePath)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	return entries
+}
+
+func TestPackLeavesOutIgnoredFiles(t *testing.T) {
+	source := copyPackageSource(t, "openldap_nested", time.Now(), 0644)
+	writeIgnoreFile(t, source, "*.md")
+
+	pkg, err := Pack(source)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	want := []string{
+		"openldap_nested/knf/openldap_vnfd.yaml",
+		"openldap_nested/openldap_nsd.yaml",
+	}
+	if got := pkg.Source.SortedFilePaths(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	wantEntries := append(want, "openldap_nested/checksums.txt")
+	if got := archiveEntries(t, pkg); !sameStrings(wantEntries, got) {
+		t.Errorf("want: %v; got: %v", wantEntries, got)
+	}
+}
+
+func TestPackWithGlobalIgnoreFile(t *testing.T) {
+	source := copyPackageSource(t, "openldap_nested", time.Now(), 0644)
+	globalDir, _ := file.ParseAbsPath(t.TempDir())
+	globalIgnoreFile := writeIgnoreFile(t, globalDir, "*.md", "knf/")
+
+	pkg, err := Pack(source, WithIgnoreFile(globalIgnoreFile))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	want := []string{
+		"openldap_nested/README.md",
+		"openldap_nested/knf/openldap_vnfd.yaml",
+		"openldap_nested/openldap_nsd.yaml",
+	}
+	if got := pkg.Source.SortedFilePaths(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: global rules outside package dir ignored; got: %v",
+			got)
+	}
+}
+
+func TestPackageIgnoreFileTakesPrecedence(t *testing.T) {
+	source := copyPackageSource(t, "openldap_nested", time.Now(), 0644)
+	parentDir, _ := file.ParseAbsPath(filepath.Dir(source.Value()))
+	globalIgnoreFile := writeIgnoreFile(t, parentDir, "*.md", "knf/")
+	writeIgnoreFile(t, source, "!README.md")
+
+	pkg, err := Pack(source, WithIgnoreFile(globalIgnoreFile))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	want := []string{
+		"openldap_nested/README.md",
+		"openldap_nested/openldap_nsd.yaml",
+	}
+	if got := pkg.Source.SortedFilePaths(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestReadDescriptorsSkipsIgnoredFiles(t *testing.T) {
+	source := copyPackageSource(t, "openldap_nested", time.Now(), 0644)
+	writeIgnoreFile(t, source, "knf/")
+
+	descs, err := ReadDescriptors(source)
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	if len(descs.Vnfds) != 0 {
+		t.Errorf("want: no vnfd; got: %v", descs.Vnfds)
+	}
+	if len(descs.Nsds) != 1 {
+		t.Errorf("want: one nsd; got: %v", descs.Nsds)
+	}
+}
+
+func sameStrings(xs, ys []string) bool {
+	seen := map[string]int{}
+	for _, x := range xs {
+		seen[x]++
+	}
+	for _, y := range ys {
+		seen[y]--
+	}
+	for _, n := range seen {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,291 @@
+package pkgr
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// LintError is a problem Lint found in a package descriptor.
+type LintError struct {
+	// Path of the descriptor file, prefixed by the package source
+	// directory's name, e.g. "my-pkg/d/vnfd.yaml". (Same as the paths
+	// returned by PackageSource.SortedFilePaths.)
+	File string
+	// Line and column of the offending YAML node. Both start from 1.
+	Line   int
+	Column int
+	// Path of the offending field within the descriptor, e.g.
+	// "vnfd.kdu[0].helm-chart". It's empty for file-level problems like
+	// YAML syntax errors.
+	Field string
+	// What's wrong.
+	Msg string
+}
+
+// Error implements the standard error interface.
+func (e *LintError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column,
+		e.Field, e.Msg)
+}
+
+// LintErrors collects all the problems Lint found in a package.
+type LintErrors []*LintError
+
+// Error implements the standard error interface, listing each problem
+// on a separate line.
+func (es LintErrors) Error() string {
+	lines := make([]string, len(es))
+	for k, e := range es {
+		lines[k] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Package directories holding the artefacts descriptors refer to by name.
+// That's the layout OSM client generates and OSM LCM expects.
+const (
+	cloudInitDirName   = "cloud_init"
+	helmChartsDirName  = "helm-charts"
+	jujuBundlesDirName = "juju-bundles"
+	charmsDirName      = "charms"
+	iconsDirName       = "icons"
+)
+
+var artefactDirNames = []string{
+	cloudInitDirName, helmChartsDirName, jujuBundlesDirName, charmsDirName,
+	iconsDirName,
+}
+
+var descriptorSchema *schema
+
+func init() {
+	var err error
+	if descriptorSchema, err = parseSchema(descriptorSchemaData); err != nil {
+		panic(err) // can only happen if someone botched the schema file
+	}
+}
+
+// Lint checks the VNFDs and NSDs in the given package source directory
+// before you upload the package to OSM. Lint parses each YAML file in the
+// package, validates any descriptor it finds against the OSM descriptor
+// schema and then makes sure any file the descriptor refers to is in the
+// package. Specifically, Lint looks for
+//
+// - "cloud-init-file" of each VDU in "cloud_init";
+// - "helm-chart" of each KDU in "helm-charts", unless in the "repo/chart"
+//   form;
+// - "juju-bundle" of each KDU in "juju-bundles", unless it's a URL or a
+//   charm store reference;
+// - "charm" of each execution environment in "charms";
+// - "logo" of each VNFD and NSD in "icons".
+//
+// Lint skips YAML files in those directories as well as YAML files that
+// aren't descriptors, e.g. Helm values, but reports syntax errors in any
+// other YAML file since it can't tell if it's a broken descriptor. Like
+// Pack, Lint skips ignored files, so pass in the same options you'd pass
+// to Pack. Notice a referenced file that's ignored is as good as missing
+// since it won't make it into the package. If there's a package manifest,
+// Lint checks the files Pack would build the package out of, i.e. with the
+// manifest charts and rendered templates. (See: Manifest)
+//
+// Lint returns nil if the package is fine, LintErrors if it found any
+// problem, or any I/O error that stopped it from scanning the package.
+func Lint(source file.AbsPath, opts ...PackOption) error {
+	staged, err := stageSource(source, makePackOpts(opts...))
+	if err != nil {
+		return err
+	}
+	defer staged.cleanup()
+
+	linter := &pkgLinter{
+		source:     staged.dir,
+		sourceName: path.Base(source.Value()),
+		ignores:    staged.ignores,
+		found:      LintErrors{},
+	}
+	scanner := file.NewTreeScanner(staged.dir)
+	if es := scanner.Visit(staged.ignores.Filter(linter.visit)); len(es) > 0 {
+		return es[0]
+	}
+	if len(linter.found) > 0 {
+		return linter.found
+	}
+	return nil
+}
+
+type pkgLinter struct {
+	source     file.AbsPath
+	sourceName string
+	ignores    file.IgnoreList
+	found      LintErrors
+}
+
+func (l *pkgLinter) visit(node file.TreeNode) error {
+	if !isYamlFile(node) || isInArtefactDir(node.RelPath) {
+		return nil
+	}
+	content, err := os.ReadFile(node.NodePath.Value())
+	if err != nil {
+		return err
+	}
+	l.lintFile(path.Join(l.sourceName, node.RelPath), content)
+	return nil
+}
+
+func isInArtefactDir(relPath string) bool {
+	topDir := strings.SplitN(relPath, "/", 2)[0]
+	for _, name := range artefactDirNames {
+		if topDir == name && topDir != relPath {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *pkgLinter) lintFile(filePath string, content []byte) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		l.found = append(l.found, yamlSyntaxError(filePath, err))
+		return
+	}
+	if len(doc.Content) == 0 {
+		return // empty file
+	}
+	root := deref(doc.Content[0])
+	fields := mappingFields(root)
+	if fields["vnfd"] == nil && fields["nsd"] == nil {
+		return // not a descriptor
+	}
+
+	for _, v := range descriptorSchema.validate("", root) {
+		l.add(filePath, v.node, v.path, v.msg)
+	}
+	l.checkVnfdRefs(filePath, fields["vnfd"])
+	l.checkNsdRefs(filePath, fields["nsd"])
+}
+
+func yamlSyntaxError(filePath string, err error) *LintError {
+	line := 0
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	if n, rest, ok := splitYamlLine(msg); ok {
+		line, msg = n, rest
+	}
+	return &LintError{File: filePath, Line: line, Column: 0, Msg: msg}
+}
+
+// yaml.v3 syntax errors look like "yaml: line 3: mapping values are not
+// allowed in this context".
+func splitYamlLine(msg string) (int, string, bool) {
+	var line int
+	if _, err := fmt.Sscanf(msg, "line %d:", &line); err != nil {
+		return 0, msg, false
+	}
+	if ix := strings.Index(msg, ": "); ix >= 0 {
+		return line, msg[ix+2:], true
+	}
+	return line, msg, true
+}
+
+func (l *pkgLinter) add(filePath string, node *yaml.Node, field, msg string) {
+	l.found = append(l.found, &LintError{
+		File:   filePath,
+		Line:   node.Line,
+		Column: node.Column,
+		Field:  field,
+		Msg:    msg,
+	})
+}
+
+func (l *pkgLinter) checkVnfdRefs(filePath string, vnfd *yaml.Node) {
+	fields := mappingFields(vnfd)
+	l.checkRef(filePath, "vnfd.logo", fields["logo"], iconsDirName)
+	forEachItem(fields["vdu"], "vnfd.vdu", func(p string, vdu *yaml.Node) {
+		ref := mappingFields(vdu)["cloud-init-file"]
+		l.checkRef(filePath, p+".cloud-init-file", ref, cloudInitDirName)
+	})
+	forEachItem(fields["kdu"], "vnfd.kdu", func(p string, kdu *yaml.Node) {
+		kduFields := mappingFields(kdu)
+		if chart := kduFields["helm-chart"]; !isRemoteChart(chart) {
+			l.checkRef(filePath, p+".helm-chart", chart, helmChartsDirName)
+		}
+		if bundle := kduFields["juju-bundle"]; !isRemoteBundle(bundle) {
+			l.checkRef(filePath, p+".juju-bundle", bundle,
+				jujuBundlesDirName)
+		}
+	})
+	forEachItem(fields["df"], "vnfd.df", func(p string, df *yaml.Node) {
+		opConfig := mappingFields(mappingFields(
+			df)["lcm-operations-configuration"])["operate-vnf-op-config"]
+		p += ".lcm-operations-configuration.operate-vnf-op-config.day1-2"
+		forEachItem(mappingFields(opConfig)["day1-2"], p,
+			func(p string, day12 *yaml.Node) {
+				envs := mappingFields(day12)["execution-environment-list"]
+				p += ".execution-environment-list"
+				forEachItem(envs, p, func(p string, env *yaml.Node) {
+					charm := mappingFields(mappingFields(env)["juju"])["charm"]
+					l.checkRef(filePath, p+".juju.charm", charm,
+						charmsDirName)
+				})
+			})
+	})
+}
+
+func (l *pkgLinter) checkNsdRefs(filePath string, catalog *yaml.Node) {
+	nsds := mappingFields(catalog)["nsd"]
+	forEachItem(nsds, "nsd.nsd", func(p string, nsd *yaml.Node) {
+		logo := mappingFields(nsd)["logo"]
+		l.checkRef(filePath, p+".logo", logo, iconsDirName)
+	})
+}
+
+func forEachItem(seq *yaml.Node, seqPath string,
+	process func(itemPath string, item *yaml.Node)) {
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return
+	}
+	for k, item := range seq.Content {
+		process(fmt.Sprintf("%s[%d]", seqPath, k), deref(item))
+	}
+}
+
+// isRemoteChart tells if the chart is in a Helm repo, i.e. the reference
+// is in the "repo/chart" form, rather than in the package.
+func isRemoteChart(ref *yaml.Node) bool {
+	return ref != nil && strings.Contains(ref.Value, "/")
+}
+
+// isRemoteBundle tells if the bundle is outside of the package, i.e. the
+// reference is a URL or a charm store reference like "cs:bundle/x".
+func isRemoteBundle(ref *yaml.Node) bool {
+	return ref != nil && strings.Contains(ref.Value, ":")
+}
+
+func (l *pkgLinter) checkRef(filePath, field string, ref *yaml.Node,
+	dirName string) {
+	if ref == nil || ref.Kind != yaml.ScalarNode || ref.Value == "" { // (*)
+		return
+	}
+	refPath := path.Join(dirName, ref.Value)
+	if !strings.HasPrefix(refPath, dirName+"/") {
+		msg := fmt.Sprintf("reference outside of %s: %s", dirName, ref.Value)
+		l.add(filePath, ref, field, msg)
+		return
+	}
+	target := l.source.Join(refPath)
+	info, err := os.Stat(target.Value())
+	if err != nil || l.ignores.Ignores(target, info.IsDir()) {
+		msg := fmt.Sprintf("referenced file not in package: %s", refPath)
+		l.add(filePath, ref, field, msg)
+	}
+
+	// (*) schema validation already reports wrong types and empty values.
+}
@@ -0,0 +1,241 @@
+package pkgr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+func writePackage(t *testing.T, files map[string]string) file.AbsPath {
+	srcDir := filepath.Join(t.TempDir(), "my_knf")
+	for name, content := range files {
+		target := filepath.Join(srcDir, name)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatalf("couldn't create package dir: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("couldn't write package file: %v", err)
+		}
+	}
+	p, _ := file.ParseAbsPath(srcDir)
+	return p
+}
+
+func lintErrors(t *testing.T, source file.AbsPath,
+	opts ...PackOption) []string {
+	err := Lint(source, opts...)
+	if err == nil {
+		return []string{}
+	}
+	es, ok := err.(LintErrors)
+	if !ok {
+		t.Fatalf("want: lint errors; got: %v", err)
+	}
+	got := []string{}
+	for _, e := range es {
+		got = append(got, e.Error())
+	}
+	return got
+}
+
+func assertLintErrors(t *testing.T, want []string, got []string) {
+	if len(want) != len(got) {
+		t.Fatalf("want: %d errors; got: %v", len(want), got)
+	}
+	for k := range want {
+		if !strings.HasPrefix(got[k], want[k]) {
+			t.Errorf("[%d] want: %s...; got: %s", k, want[k], got[k])
+		}
+	}
+}
+
+const validVnfd = `vnfd:
+  id: my_knf
+  provider: me
+  product-name: my_knf
+  version: '1.0'
+  df:
+  - id: default-df
+`
+
+func TestLintTestDataPackages(t *testing.T) {
+	for _, name := range []string{"openldap_knf", "openldap_ns",
+		"openldap_nested"} {
+		if err := Lint(findTestDataDir(name)); err != nil {
+			t.Errorf("[%s] want: no errors; got: %v", name, err)
+		}
+	}
+}
+
+func TestLintSchemaViolations(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": `vnfd:
+  id: my_knf
+  product-name: [my_knf]
+  version: '1.0'
+  df: []
+  kdu:
+  - helm-chart: stable/openldap
+    helm-version: v4
+`,
+	})
+	want := []string{
+		"my_knf/vnfd.yaml:2:3: vnfd: missing required field: provider",
+		"my_knf/vnfd.yaml:3:17: vnfd.product-name: want: string; got: array",
+		"my_knf/vnfd.yaml:5:7: vnfd.df: want: at least 1 items; got: 0",
+		"my_knf/vnfd.yaml:7:5: vnfd.kdu[0]: missing required field: name",
+		"my_knf/vnfd.yaml:8:19: vnfd.kdu[0].helm-version: want: one of",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintNsdSchemaViolations(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"nsd.yaml": `nsd:
+  nsd:
+  - id: my_ns
+    name: my_ns
+    vnfd-id: [my_knf]
+    df:
+    - id: default-df
+      vnf-profile:
+      - id: p1
+  - name: other
+`,
+	})
+	want := []string{
+		"my_knf/nsd.yaml:9:9: nsd.nsd[0].df[0].vnf-profile[0]: missing required field: vnfd-id",
+		"my_knf/nsd.yaml:10:5: nsd.nsd[1]: missing required field: id",
+		"my_knf/nsd.yaml:10:5: nsd.nsd[1]: missing required field: vnfd-id",
+		"my_knf/nsd.yaml:10:5: nsd.nsd[1]: missing required field: df",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintMissingReferencedFiles(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": validVnfd + `  logo: logo.png
+  vdu:
+  - id: vdu1
+    name: vdu1
+    cloud-init-file: init.cfg
+  kdu:
+  - name: k1
+    helm-chart: local-chart
+  - name: k2
+    helm-chart: stable/remote
+  - name: k3
+    juju-bundle: bundle
+  - name: k4
+    juju-bundle: cs:bundle/remote
+`,
+	})
+	want := []string{
+		"my_knf/vnfd.yaml:8:9: vnfd.logo: referenced file not in package: icons/logo.png",
+		"my_knf/vnfd.yaml:12:22: vnfd.vdu[0].cloud-init-file: referenced file not in package: cloud_init/init.cfg",
+		"my_knf/vnfd.yaml:15:17: vnfd.kdu[0].helm-chart: referenced file not in package: helm-charts/local-chart",
+		"my_knf/vnfd.yaml:19:18: vnfd.kdu[2].juju-bundle: referenced file not in package: juju-bundles/bundle",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintFindsReferencedFiles(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": validVnfd + `  logo: logo.png
+  vdu:
+  - id: vdu1
+    name: vdu1
+    cloud-init-file: init.cfg
+  kdu:
+  - name: k1
+    helm-chart: local-chart
+`,
+		"icons/logo.png":                           "png",
+		"cloud_init/init.cfg":                      "#cloud-config",
+		"helm-charts/local-chart/Chart.yaml":       "name: local-chart",
+		"helm-charts/local-chart/templates/x.yaml": "{{ broken: [",
+	})
+	assertLintErrors(t, []string{}, lintErrors(t, source))
+}
+
+func TestLintCharmReferences(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": `vnfd:
+  id: my_knf
+  provider: me
+  product-name: my_knf
+  version: '1.0'
+  df:
+  - id: default-df
+    lcm-operations-configuration:
+      operate-vnf-op-config:
+        day1-2:
+        - id: my_knf
+          execution-environment-list:
+          - id: ee1
+            juju:
+              charm: here
+          - id: ee2
+            juju:
+              charm: missing
+`,
+		"charms/here/metadata.yaml": "name: here",
+	})
+	want := []string{
+		"my_knf/vnfd.yaml:18:22: vnfd.df[0].lcm-operations-configuration.operate-vnf-op-config.day1-2[0].execution-environment-list[1].juju.charm: referenced file not in package: charms/missing",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintIgnoredReferencedFileIsMissing(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml":      validVnfd + "  logo: logo.png\n",
+		"icons/logo.png": "png",
+		IgnoreFileName:   "icons/\n",
+	})
+	want := []string{
+		"my_knf/vnfd.yaml:8:9: vnfd.logo: referenced file not in package",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintReferenceOutsideOfArtefactDir(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": validVnfd + "  logo: ../vnfd.yaml\n",
+	})
+	want := []string{
+		"my_knf/vnfd.yaml:8:9: vnfd.logo: reference outside of icons",
+	}
+	assertLintErrors(t, want, lintErrors(t, source))
+}
+
+func TestLintYamlSyntaxError(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": "vnfd:\n  id: x\n  df: [\n",
+	})
+	got := lintErrors(t, source)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "my_knf/vnfd.yaml:") {
+		t.Errorf("want: syntax error; got: %v", got)
+	}
+}
+
+func TestLintSkipsNonDescriptors(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml":   validVnfd,
+		"values.yaml": "replicaCount: 2\n",
+		"empty.yaml":  "",
+		"README.md":   "vnfd: [",
+	})
+	assertLintErrors(t, []string{}, lintErrors(t, source))
+}
+
+func TestLintScanError(t *testing.T) {
+	source, _ := file.ParseAbsPath("no/where")
+	err := Lint(source)
+	if _, ok := err.(LintErrors); ok || err == nil {
+		t.Errorf("want: scan error; got: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package pkgr
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+// ManifestFileName is the name of the file telling Pack how to build a
+// package out of files that aren't in the package source directory, e.g.
+// Helm charts maintained elsewhere in the repo. (See: Manifest)
+const ManifestFileName = "package.osmops.yaml"
+
+// Manifest holds the instructions to build a package. If there's a
+// manifest file in the package source directory, Pack, Lint and
+// ReadDescriptors work on a copy of the package source directory where
+// they first
+//
+// 1. add each of the Charts to the "helm-charts" directory;
+// 2. render each of the Templates in place.
+//
+// Here's an example manifest:
+//
+//     charts:
+//       - path: ../../../charts/openldap
+//       - path: ../../../dist/mongodb-10.0.0.tgz
+//         name: mongo
+//     templates:
+//       - openldap_vnfd.yaml
+//     values:
+//       release: "1.2"
+//
+// The copy doesn't contain the manifest or any ignored file. (See:
+// IgnoreFileName)
+type Manifest struct {
+	// Helm charts to add to the package.
+	Charts []ChartSource `yaml:"charts"`
+	// Paths, relative to the package source directory, of the files to
+	// render as Go templates. (See: TemplateData)
+	Templates []string `yaml:"templates"`
+	// Any values you'd like to use in the templates.
+	Values map[string]interface{} `yaml:"values"`
+}
+
+// ChartSource tells where to get a Helm chart from.
+type ChartSource struct {
+	// Path, relative to the package source directory, to either a chart
+	// directory or a chart archive, i.e. the output of `helm package`.
+	Path string `yaml:"path"`
+	// The name of the chart directory in the package, i.e. the name KDUs
+	// use in their "helm-chart" field. Defaults to the chart name in
+	// "Chart.yaml".
+	Name string `yaml:"name"`
+}
+
+// TemplateData is the data Pack renders the manifest templates with.
+// For example, say the manifest pulls in an "openldap" chart, then you
+// could write a KDU like this
+//
+//     kdu:
+//       - name: ldap
+//         helm-chart: openldap
+//         # chart version: {{ .Charts.openldap.Version }}
+//
+// and get the chart version out of the chart's "Chart.yaml" file.
+type TemplateData struct {
+	// The package name, i.e. the name of the package source directory.
+	Name string
+	// The Values in the manifest.
+	Values map[string]interface{}
+	// The charts Pack added to the package, each keyed by its name in the
+	// package. (See: ChartSource)
+	Charts map[string]ChartInfo
+}
+
+// ChartInfo holds the data in a chart's "Chart.yaml" file templates can
+// use.
+type ChartInfo struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion"`
+}
+
+func readManifest(source file.AbsPath) (*Manifest, error) {
+	content, err := os.ReadFile(source.Join(ManifestFileName).Value())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	if err := yaml.UnmarshalStrict(content, manifest); err != nil {
+		return nil, fmt.Errorf("%s: %v", ManifestFileName, err)
+	}
+	return manifest, nil
+}
+
+func isManifestFile(node file.TreeNode) bool {
+	return node.RelPath == ManifestFileName
+}
+
+// stagedSource is the directory where Pack, Lint and ReadDescriptors find
+// the package source files, along with the ignore list to apply to it.
+// That's the package source directory unless there's a manifest or we've
+// got to rename descriptors, in which case it's a temp copy we build
+// according to the manifest and with the renamed descriptors. Call cleanup
+// when done to delete the copy.
+type stagedSource struct {
+	dir     file.AbsPath
+	ignores file.IgnoreList
+	cleanup func()
+}
+
+func stageSource(source file.AbsPath, cfg *packOpts) (*stagedSource, error) {
+	ignores, err := loadIgnoreList(source, cfg)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(source)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil && len(cfg.descriptorIds) == 0 {
+		return &stagedSource{
+			dir: source, ignores: ignores, cleanup: func() {},
+		}, nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "osm-pkg-build-*")
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &Manifest{}
+	}
+	name := filepath.Base(source.Value())
+	if newId, ok := cfg.descriptorIds[name]; ok {
+		name = newId
+	}
+	staged := &stagedSource{cleanup: func() { os.RemoveAll(tempDir) }}
+	staged.dir, _ = file.ParseAbsPath(filepath.Join(tempDir, name)) // (1)
+	err = buildSource(source, staged.dir, manifest, ignores)
+	if err == nil && len(cfg.descriptorIds) > 0 {
+		err = renameDescriptors(staged.dir, cfg.descriptorIds)
+	}
+	if err == nil {
+		staged.ignores, err = loadIgnoreList(staged.dir, cfg) // (2)
+	}
+	if err != nil {
+		staged.cleanup()
+		return nil, err
+	}
+	return staged, nil
+
+	// NOTE.
+	// 1. Same name as the source dir since that's the package name, unless
+	// we're renaming the package descriptor. (See: WithDescriptorIds)
+	// 2. The copy has no ignored files, but the package's own ignore file
+	// rules should also apply to the charts we added.
+}
+
+func buildSource(source, target file.AbsPath, manifest *Manifest,
+	ignores file.IgnoreList) error {
+	copyFile := file.CopyVisitor(target)
+	visitor := ignores.Filter(func(node file.TreeNode) error {
+		if isManifestFile(node) {
+			return nil
+		}
+		return copyFile(node)
+	})
+	if es := file.NewTreeScanner(source).Visit(visitor); len(es) > 0 {
+		return es[0]
+	}
+
+	data := &TemplateData{
+		Name:   filepath.Base(source.Value()),
+		Values: manifest.Values,
+		Charts: map[string]ChartInfo{},
+	}
+	for _, chart := range manifest.Charts {
+		info, name, err := addChart(source, target, chart)
+		if err != nil {
+			return err
+		}
+		if _, ok := data.Charts[name]; ok {
+			return fmt.Errorf("%s: duplicate chart: %s", ManifestFileName,
+				name)
+		}
+		data.Charts[name] = *info
+	}
+	for _, tmpl := range manifest.Templates {
+		if err := renderTemplate(target, tmpl, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addChart copies the chart at the given path, relative to the package
+// source directory, to the "helm-charts" directory in the target. If the
+// chart is an archive, addChart extracts it.
+func addChart(source, target file.AbsPath, chart ChartSource) (
+	*ChartInfo, string, error) {
+	if strings.TrimSpace(chart.Path) == "" {
+		return nil, "", fmt.Errorf("%s: chart without path", ManifestFileName)
+	}
+	chartPath := source.Join(chart.Path)
+	fi, err := os.Stat(chartPath.Value())
+	if err != nil {
+		return nil, "", err
+	}
+
+	chartDir := chartPath
+	if !fi.IsDir() {
+		unpacked, err := os.MkdirTemp(target.Value(), ".chart-*")
+		if err != nil {
+			return nil, "", err
+		}
+		defer os.RemoveAll(unpacked)
+		if err := tgz.ExtractTarball(chartPath, unpacked); err != nil {
+			return nil, "", fmt.Errorf("%v: %v", chartPath, err)
+		}
+		if chartDir, err = singleSubDir(unpacked); err != nil {
+			return nil, "", fmt.Errorf("%v: %v", chartPath, err)
+		}
+	}
+
+	info, err := readChartInfo(chartDir)
+	if err != nil {
+		return nil, "", err
+	}
+	name := chart.Name
+	if name == "" {
+		name = info.Name
+	}
+	if name == "" || name != path.Base(name) || name == ".." {
+		return nil, "", fmt.Errorf("%v: invalid chart name: %q", chartPath,
+			name)
+	}
+
+	dest := target.Join(helmChartsDirName).Join(name)
+	if err := file.CopyTree(chartDir, dest); err != nil {
+		return nil, "", err
+	}
+	return info, name, nil
+}
+
+func singleSubDir(dir string) (file.AbsPath, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return file.AbsPath{}, err
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return file.AbsPath{}, fmt.Errorf("not a chart archive")
+	}
+	return file.ParseAbsPath(filepath.Join(dir, entries[0].Name()))
+}
+
+func readChartInfo(chartDir file.AbsPath) (*ChartInfo, error) {
+	content, err := os.ReadFile(chartDir.Join("Chart.yaml").Value())
+	if err != nil {
+		return nil, err
+	}
+	info := &ChartInfo{}
+	if err := yaml.Unmarshal(content, info); err != nil {
+		return nil, fmt.Errorf("%v: %v", chartDir.Join("Chart.yaml"), err)
+	}
+	return info, nil
+}
+
+func renderTemplate(target file.AbsPath, tmplPath string,
+	data *TemplateData) error {
+	relPath := path.Clean(filepath.ToSlash(tmplPath))
+	if path.IsAbs(relPath) || relPath == ".." ||
+		strings.HasPrefix(relPath, "../") {
+		return fmt.Errorf("%s: template outside of package: %s",
+			ManifestFileName, tmplPath)
+	}
+	tmplFile := target.Join(relPath)
+	content, err := os.ReadFile(tmplFile.Value())
+	if err != nil {
+		return fmt.Errorf("%s: template %s: %v", ManifestFileName, tmplPath,
+			err) // (*)
+	}
+
+	tmpl, err := template.New(relPath).Option("missingkey=error").
+		Parse(string(content))
+	if err != nil {
+		return err
+	}
+	rendered := &bytes.Buffer{}
+	if err := tmpl.Execute(rendered, data); err != nil {
+		return err
+	}
+	return os.WriteFile(tmplFile.Value(), rendered.Bytes(), 0644)
+
+	// (*) the path we read from is in the temp copy, so better report the
+	// path in the manifest.
+}
@@ -0,0 +1,210 @@
+package pkgr
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+const chartYaml = "apiVersion: v2\nname: openldap\nversion: 1.2.3\nappVersion: 2.4.57\n"
+
+func writeChartDir(t *testing.T, source file.AbsPath) {
+	chartDir := filepath.Join(filepath.Dir(source.Value()), "charts", "openldap")
+	files := map[string]string{
+		"Chart.yaml":            chartYaml,
+		"values.yaml":           "replicaCount: 1\n",
+		"templates/deploy.yaml": "kind: Deployment\n",
+	}
+	for name, content := range files {
+		target := filepath.Join(chartDir, name)
+		os.MkdirAll(filepath.Dir(target), 0755)
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("couldn't write chart file: %v", err)
+		}
+	}
+}
+
+func writeChartArchive(t *testing.T, source file.AbsPath) {
+	tarball := makeTarball(t,
+		tarEntry{name: "openldap/Chart.yaml", content: chartYaml},
+		tarEntry{name: "openldap/values.yaml", content: "replicaCount: 1\n"},
+	)
+	data, _ := io.ReadAll(tarball)
+	target := filepath.Join(filepath.Dir(source.Value()), "openldap-1.2.3.tgz")
+	if err := os.WriteFile(target, data, 0644); err != nil {
+		t.Fatalf("couldn't write chart archive: %v", err)
+	}
+}
+
+const templatedVnfd = `vnfd:
+  id: {{ .Name }}
+  provider: me
+  product-name: {{ .Name }}
+  version: '{{ .Values.release }}-{{ .Charts.ldap.Version }}'
+  df:
+  - id: default-df
+  kdu:
+  - name: ldap
+    helm-chart: ldap
+`
+
+func sortedKeys(files map[string][]byte) []string {
+	keys := []string{}
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPackWithManifestChartDir(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": templatedVnfd,
+		ManifestFileName: "charts:\n- path: ../charts/openldap\n  name: ldap\n" +
+			"templates:\n- vnfd.yaml\nvalues:\n  release: '1.0'\n",
+	})
+	writeChartDir(t, source)
+
+	pkg, err := Pack(source)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	files := archiveFiles(t, pkg)
+	staged := pkg.Source.Directory()
+	pkg.Data.Close()
+
+	want := []string{
+		"my_knf/checksums.txt",
+		"my_knf/helm-charts/ldap/Chart.yaml",
+		"my_knf/helm-charts/ldap/templates/deploy.yaml",
+		"my_knf/helm-charts/ldap/values.yaml",
+		"my_knf/vnfd.yaml",
+	}
+	if got := sortedKeys(files); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	vnfd := string(files["my_knf/vnfd.yaml"])
+	if !strings.Contains(vnfd, "id: my_knf") ||
+		!strings.Contains(vnfd, "version: '1.0-1.2.3'") {
+		t.Errorf("want: rendered vnfd; got: %s", vnfd)
+	}
+	if pkg.Name != "my_knf" {
+		t.Errorf("want: my_knf; got: %s", pkg.Name)
+	}
+	if _, err := os.Stat(staged.Value()); !os.IsNotExist(err) {
+		t.Errorf("want: staged copy deleted; got: %v", err)
+	}
+}
+
+func TestPackWithManifestChartArchive(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml":      validVnfd,
+		ManifestFileName: "charts:\n- path: ../openldap-1.2.3.tgz\n",
+	})
+	writeChartArchive(t, source)
+
+	pkg, err := Pack(source)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	want := []string{
+		"my_knf/checksums.txt",
+		"my_knf/helm-charts/openldap/Chart.yaml",
+		"my_knf/helm-charts/openldap/values.yaml",
+		"my_knf/vnfd.yaml",
+	}
+	if got := sortedKeys(archiveFiles(t, pkg)); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestPackWithManifestAppliesIgnoreFile(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml":      validVnfd,
+		"notes.md":       "notes",
+		ManifestFileName: "charts:\n- path: ../charts/openldap\n",
+	})
+	writeChartDir(t, source)
+	writeIgnoreFile(t, source, "*.md", "templates/")
+
+	pkg, err := Pack(source)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	want := []string{
+		"my_knf/checksums.txt",
+		"my_knf/helm-charts/openldap/Chart.yaml",
+		"my_knf/helm-charts/openldap/values.yaml",
+		"my_knf/vnfd.yaml",
+	}
+	if got := sortedKeys(archiveFiles(t, pkg)); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestPackWithManifestErrors(t *testing.T) {
+	manifests := []string{
+		"charts: [",
+		"unknown: field\n",
+		"charts:\n- name: x\n",
+		"charts:\n- path: ../charts/missing\n",
+		"charts:\n- path: ../charts/openldap\n- path: ../charts/openldap\n",
+		"charts:\n- path: ../charts/openldap\n  name: ../x\n",
+		"templates:\n- ../vnfd.yaml\n",
+		"templates:\n- missing.yaml\n",
+		"templates:\n- vnfd.yaml\n", // no values => missing key
+	}
+	for k, manifest := range manifests {
+		source := writePackage(t, map[string]string{
+			"vnfd.yaml":      templatedVnfd,
+			ManifestFileName: manifest,
+		})
+		writeChartDir(t, source)
+
+		if pkg, err := Pack(source); err == nil {
+			pkg.Data.Close()
+			t.Errorf("[%d] want: error; got: nil", k)
+		}
+	}
+}
+
+func TestLintWithManifest(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml": templatedVnfd,
+		ManifestFileName: "charts:\n- path: ../charts/openldap\n  name: ldap\n" +
+			"templates:\n- vnfd.yaml\nvalues:\n  release: '1.0'\n",
+	})
+	writeChartDir(t, source)
+
+	assertLintErrors(t, []string{}, lintErrors(t, source))
+
+	descs, err := ReadDescriptors(source)
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	if !reflect.DeepEqual(descs.Vnfds, []string{"my_knf"}) {
+		t.Errorf("want: [my_knf]; got: %v", descs.Vnfds)
+	}
+}
+
+func TestStageSourceWithoutManifest(t *testing.T) {
+	source := writePackage(t, map[string]string{"vnfd.yaml": validVnfd})
+	staged, err := stageSource(source, makePackOpts())
+	if err != nil {
+		t.Fatalf("want: staged source; got: %v", err)
+	}
+	defer staged.cleanup()
+	if staged.dir != source {
+		t.Errorf("want: %v; got: %v", source, staged.dir)
+	}
+}
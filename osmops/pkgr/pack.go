@@ -1,32 +1,190 @@
 package pkgr
 
 import (
+	"crypto/ed25519"
 	"io"
+	"time"
 
-	"github.com/fluxcd/source-watcher/osmops/util/bytez"
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 	"github.com/fluxcd/source-watcher/osmops/util/tgz"
 )
 
+// DefaultEntryTime is the mod time Pack gives to each file in the archive,
+// unless told otherwise. (See: WithEntryTime)
+var DefaultEntryTime = time.Unix(0, 0).UTC()
+
+type packOpts struct {
+	reproducible  bool
+	entryTime     time.Time
+	ignoreFiles   []file.AbsPath
+	checksums     []u.EnumIx
+	signingKey    ed25519.PrivateKey
+	descriptorIds map[string]string
+	format        u.EnumIx
+}
+
+func makePackOpts(opts ...PackOption) *packOpts {
+	cfg := &packOpts{reproducible: true, entryTime: DefaultEntryTime}
+	for _, setting := range opts {
+		if setting != nil {
+			setting(cfg)
+		}
+	}
+	return cfg
+}
+
+// PackOption tweaks the way Pack builds the package archive.
+type PackOption func(opts *packOpts)
+
+// WithEntryTime sets the mod time of each file in the archive to the given
+// time point, e.g. the commit time of the Git revision the package sources
+// come from. Notice that if the time changes, so does the package hash, even
+// if the sources stay the same.
+func WithEntryTime(when time.Time) PackOption {
+	return func(opts *packOpts) {
+		opts.entryTime = when
+	}
+}
+
+// WithHostFileMetadata makes Pack archive files with the mod time, owner
+// and permissions they have on the host, so packing the same sources at
+// different times or on different hosts results in different hashes.
+func WithHostFileMetadata() PackOption {
+	return func(opts *packOpts) {
+		opts.reproducible = false
+	}
+}
+
+// WithIgnoreFile makes Pack skip the files matching the patterns in the
+// given ignore file, on top of those in the package's own ignore file.
+// (See: IgnoreFileName)
+func WithIgnoreFile(ignoreFile file.AbsPath) PackOption {
+	return func(opts *packOpts) {
+		opts.ignoreFiles = append(opts.ignoreFiles, ignoreFile)
+	}
+}
+
+// WithChecksums makes Pack add a checksum file for each of the given hash
+// algorithms, on top of the MD5 one OSM needs. (See: HashAlgorithm)
+func WithChecksums(algs ...u.EnumIx) PackOption {
+	return func(opts *packOpts) {
+		opts.checksums = append(opts.checksums, algs...)
+	}
+}
+
+// WithSigningKey makes Pack sign the SHA-256 checksum file with the given
+// key and add the signature to the package. This implies a SHA-256
+// checksum file, even if you didn't ask for it through WithChecksums.
+// (See: SignatureFileName)
+func WithSigningKey(key ed25519.PrivateKey) PackOption {
+	return func(opts *packOpts) {
+		opts.signingKey = key
+	}
+}
+
+func (opts *packOpts) hashAlgorithms() []u.EnumIx {
+	if opts.format == PackageFormat.CSAR {
+		algs := append([]u.EnumIx{}, opts.checksums...)
+		return append(algs, opts.csarDigestAlgorithm())
+	}
+	if opts.signingKey != nil {
+		algs := append([]u.EnumIx{}, opts.checksums...)
+		return append(algs, HashAlgorithm.SHA256)
+	}
+	return opts.checksums
+}
+
+func (opts *packOpts) writerOptions() []tgz.WriterOption {
+	writerOpts := []tgz.WriterOption{tgz.WithBestCompression()}
+	if opts.reproducible {
+		writerOpts = append(writerOpts,
+			tgz.WithReproducibleEntries(opts.entryTime))
+	}
+	if opts.format == PackageFormat.CSAR {
+		writerOpts = append(writerOpts, tgz.WithFormat(tgz.Format.ZIP))
+	}
+	return writerOpts
+}
+
 // Pack creates an OSM package from the source files contained in the
-// specified directory. Pack writes the entire package content into a
-// memory buffer instead of streaming it. This shouldn't be a problem
-// since packages are usually very small, like less than 1Kb.
-func Pack(source file.AbsPath) (*Package, error) {
-	return doPack(source, tgz.WithBestCompression())
+// specified directory. Pack streams the package content to a temp file,
+// computing the package hash on the fly, so it never holds the whole
+// package in memory. Closing the returned Package's Data deletes the
+// temp file, so make sure you always do that.
+//
+// Packages are reproducible by default: the same sources always result
+// in the same archive and hash, regardless of file times, owners and
+// permission bits on the host. Pack adds files to the archive in lexical
+// order, sets their mod time to DefaultEntryTime, their owner to root and
+// their permissions to 0644, or 0755 for executables. Use WithEntryTime
+// to change the mod time and WithHostFileMetadata to turn off reproducible
+// archives.
+//
+// Pack leaves out of the archive, and of the checksum file, any file
+// matched by the patterns in the package's ignore file, if there's one,
+// or in the ignore files passed in through WithIgnoreFile. (See:
+// IgnoreFileName)
+//
+// If the source directory has a package manifest, Pack builds the package
+// out of a temp copy of the source directory with the charts and rendered
+// templates the manifest asks for. In that case, the returned Package's
+// Source points to the copy, which Pack deletes when you close Data.
+// (See: Manifest)
+//
+// On top of the MD5 checksum file OSM needs, Pack can add checksum files
+// for stronger hash algorithms, through WithChecksums, and sign the SHA-256
+// one, through WithSigningKey.
+//
+// With WithPackageFormat(PackageFormat.CSAR), Pack builds an ETSI SOL004
+// CSAR package instead. That's a zip archive with the package source files
+// at the root, a TOSCA metadata file and a manifest with the SHA-256 digest
+// of each file---SHA-512 if you asked for it through WithChecksums. The
+// TOSCA metadata file names the manifest and, as entry definitions, the
+// VNFD or NSD named after the package or the only descriptor there is.
+// The manifest metadata come from the entry definitions. CSAR packages
+// have no OSM checksum files and Pack can't sign them.
+func Pack(source file.AbsPath, opts ...PackOption) (*Package, error) {
+	cfg := makePackOpts(opts...)
+	return doPack(source, cfg, cfg.writerOptions()...)
 }
 
 // added for testability
-func doPack(source file.AbsPath, opts ...tgz.WriterOption) (*Package, error) {
-	sink := bytez.NewBuffer()
-	pkgSource := newPkgSrc(source)
-	if err := writePackageData(pkgSource, sink, opts...); err != nil {
+func doPack(source file.AbsPath, cfg *packOpts,
+	opts ...tgz.WriterOption) (*Package, error) {
+	staged, err := stageSource(source, cfg)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newSpool()
+	if err != nil {
+		staged.cleanup()
+		return nil, err
+	}
+	pkgSource := newPkgSrc(staged.dir, cfg.hashAlgorithms()...)
+	pkgSource.ignores = staged.ignores
+	if cfg.format == PackageFormat.CSAR {
+		err = writeCsarData(pkgSource, sink, cfg, opts...)
+	} else {
+		err = writePackageData(pkgSource, sink, cfg.signingKey, opts...)
+	}
+	if err != nil {
+		sink.remove()
+		staged.cleanup()
+		return nil, err
+	}
+	pkg, err := makePackage(pkgSource, sink, staged.cleanup)
+	if err != nil {
+		sink.remove()
+		staged.cleanup()
 		return nil, err
 	}
-	return makePackage(pkgSource, sink), nil
+	pkg.Format = cfg.format
+	return pkg, nil
 }
 
-func writePackageData(source *pkgSrc, sink io.WriteCloser, opts ...tgz.WriterOption) error {
+func writePackageData(source *pkgSrc, sink io.WriteCloser,
+	signingKey ed25519.PrivateKey, opts ...tgz.WriterOption) error {
 	archiveBaseDirName := source.DirectoryName()
 	writer, err := tgz.NewWriter(archiveBaseDirName, sink, opts...)
 	if err != nil {
@@ -37,12 +195,18 @@ func writePackageData(source *pkgSrc, sink io.WriteCloser, opts ...tgz.WriterOpt
 	if err := collectPackageItems(source, writer); err != nil {
 		return err
 	}
-	return addChecksumFile(source, writer)
+	if err := addChecksumFiles(source, writer); err != nil {
+		return err
+	}
+	if signingKey != nil {
+		return addSignatureFile(source, signingKey, writer)
+	}
+	return nil
 }
 
 func collectPackageItems(source *pkgSrc, writer tgz.Writer) error {
 	scanner := file.NewTreeScanner(source.Directory())
-	visitor := makeSourceVisitor(source, writer)
+	visitor := source.ignores.Filter(makeSourceVisitor(source, writer))
 	if es := scanner.Visit(visitor); len(es) > 0 {
 		return es[0]
 	}
@@ -52,6 +216,9 @@ func collectPackageItems(source *pkgSrc, writer tgz.Writer) error {
 func makeSourceVisitor(source *pkgSrc, writer tgz.Writer) file.Visitor {
 	collectFile := writer.Visitor()
 	return func(node file.TreeNode) error {
+		if isPackageIgnoreFile(node) {
+			return nil
+		}
 		if err := collectFile(node); err != nil {
 			return err
 		}
@@ -59,7 +226,23 @@ func makeSourceVisitor(source *pkgSrc, writer tgz.Writer) file.Visitor {
 	}
 }
 
-func addChecksumFile(source *pkgSrc, writer tgz.Writer) error {
-	content := writeCheckSumFileContent(source)
-	return writer.AddEntry(ChecksumFileName, content)
+func addChecksumFiles(source *pkgSrc, writer tgz.Writer) error {
+	for _, alg := range source.algs {
+		content := writeCheckSumFileContent(source, alg)
+		if err := writer.AddEntry(ChecksumFileNameFor(alg), content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addSignatureFile(source *pkgSrc, key ed25519.PrivateKey,
+	writer tgz.Writer) error {
+	manifest, err := io.ReadAll(
+		writeCheckSumFileContent(source, HashAlgorithm.SHA256))
+	if err != nil {
+		return err
+	}
+	content := writeSignatureContent(key, manifest)
+	return writer.AddEntry(SignatureFileName, content)
 }
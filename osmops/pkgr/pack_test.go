@@ -1,10 +1,13 @@
 package pkgr
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
@@ -30,7 +33,129 @@ func TestPackErrOnSourceDirAccess(t *testing.T) {
 func TestWritePackageDataErrOnNilSink(t *testing.T) {
 	srcDir, _ := file.ParseAbsPath("no/where")
 	pkgSrc := newPkgSrc(srcDir)
-	if err := writePackageData(pkgSrc, nil); err == nil {
+	if err := writePackageData(pkgSrc, nil, nil); err == nil {
 		t.Errorf("want: nil error; got: no error")
 	}
 }
+
+func TestPackDataIsSpooledToTempFile(t *testing.T) {
+	pkg, err := Pack(findTestDataDir("openldap_nested"))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	if _, err := os.Stat(pkg.dataPath); err != nil {
+		t.Fatalf("want: spool file; got: %v", err)
+	}
+
+	reopened, err := pkg.OpenData()
+	if err != nil {
+		t.Fatalf("want: data stream; got: %v", err)
+	}
+	defer reopened.Close()
+	content, _ := io.ReadAll(reopened)
+	if int64(len(content)) != pkg.Size {
+		t.Errorf("want size: %d; got: %d", pkg.Size, len(content))
+	}
+	if got := md5string(content); got != pkg.Hash {
+		t.Errorf("want hash: %s; got: %s", pkg.Hash, got)
+	}
+
+	if err := pkg.Data.Close(); err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+	if _, err := os.Stat(pkg.dataPath); !os.IsNotExist(err) {
+		t.Errorf("want: spool file deleted; got: %v", err)
+	}
+	if _, err := pkg.OpenData(); err == nil {
+		t.Errorf("want: error opening data of closed package; got: nil")
+	}
+}
+
+func TestPackRemovesSpoolFileOnError(t *testing.T) {
+	before, _ := filepath.Glob(filepath.Join(os.TempDir(), "osm-pkg-*.tar.gz"))
+	srcDir, _ := file.ParseAbsPath("no/where")
+	if _, err := Pack(srcDir); err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+	after, _ := filepath.Glob(filepath.Join(os.TempDir(), "osm-pkg-*.tar.gz"))
+	if len(after) > len(before) {
+		t.Errorf("want: no leftover spool files; got: %v", after)
+	}
+}
+
+func copyPackageSource(t *testing.T, pkgName string, mtime time.Time,
+	mode os.FileMode) file.AbsPath {
+	tempDir, err := ioutil.TempDir("", "pkgr-test")
+	if err != nil {
+		t.Fatalf("couldn't create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	srcDir := findTestDataDir(pkgName).Value()
+	dstDir := filepath.Join(tempDir, pkgName)
+	err = filepath.Walk(srcDir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(srcDir, p)
+		target := filepath.Join(dstDir, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return err
+		}
+		return os.Chtimes(target, mtime, mtime)
+	})
+	if err != nil {
+		t.Fatalf("couldn't copy package source: %v", err)
+	}
+
+	dst, _ := file.ParseAbsPath(dstDir)
+	return dst
+}
+
+func packHash(t *testing.T, source file.AbsPath, opts ...PackOption) string {
+	pkg, err := Pack(source, opts...)
+	if err != nil {
+		t.Fatalf("couldn't pack: %v; error: %v", source, err)
+	}
+	defer pkg.Data.Close()
+	return pkg.Hash
+}
+
+func TestPackIsReproducible(t *testing.T) {
+	src1 := copyPackageSource(t, "openldap_nested", time.Now(), 0600)
+	src2 := copyPackageSource(t, "openldap_nested",
+		time.Now().Add(-time.Hour), 0664)
+
+	if h1, h2 := packHash(t, src1), packHash(t, src2); h1 != h2 {
+		t.Errorf("want: same hash; got: %s, %s", h1, h2)
+	}
+
+	when := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	h1 := packHash(t, src1, WithEntryTime(when))
+	h2 := packHash(t, src2, WithEntryTime(when))
+	if h1 != h2 {
+		t.Errorf("want: same hash; got: %s, %s", h1, h2)
+	}
+	if h1 == packHash(t, src1) {
+		t.Errorf("want: entry time to change hash; got: same hash")
+	}
+}
+
+func TestPackWithHostFileMetadata(t *testing.T) {
+	src1 := copyPackageSource(t, "openldap_nested", time.Now(), 0600)
+	src2 := copyPackageSource(t, "openldap_nested",
+		time.Now().Add(-time.Hour), 0600)
+
+	h1 := packHash(t, src1, WithHostFileMetadata())
+	h2 := packHash(t, src2, WithHostFileMetadata(), nil)
+	if h1 == h2 {
+		t.Errorf("want: different hashes; got: %s", h1)
+	}
+}
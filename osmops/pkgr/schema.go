@@ -0,0 +1,274 @@
+package pkgr
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NOTE. Descriptor schema. OSM defines descriptors through YANG modules,
+// the OSM IM. Rather than pulling in a YANG toolchain, we vendor a JSON
+// schema we derived from the OSM IM and validate descriptors against it.
+// There's no JSON schema library among our deps, so we implement the few
+// draft-07 keywords the schema uses: $ref (local only), type, properties,
+// required, items, enum, pattern, minItems and minLength. We validate
+// the YAML node tree rather than the decoded data so we can tell on which
+// line and column each problem is.
+
+//go:embed schema/descriptors.schema.json
+var descriptorSchemaData []byte
+
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+type schema struct {
+	Ref         string             `json:"$ref"`
+	Type        schemaTypes        `json:"type"`
+	Properties  map[string]*schema `json:"properties"`
+	Required    []string           `json:"required"`
+	Items       *schema            `json:"items"`
+	Enum        []interface{}      `json:"enum"`
+	Pattern     string             `json:"pattern"`
+	MinItems    *int               `json:"minItems"`
+	MinLength   *int               `json:"minLength"`
+	Definitions map[string]*schema `json:"definitions"`
+
+	root    *schema
+	pattern *regexp.Regexp
+}
+
+func parseSchema(data []byte) (*schema, error) {
+	root := &schema{}
+	if err := json.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+	if err := root.link(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (s *schema) link(root *schema) error {
+	s.root = root
+	if s.Pattern != "" {
+		p, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = p
+	}
+	if s.Ref != "" {
+		if _, err := s.resolve(); err != nil {
+			return err
+		}
+	}
+	children := []*schema{s.Items}
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	for _, d := range s.Definitions {
+		children = append(children, d)
+	}
+	for _, c := range children {
+		if c != nil {
+			if err := c.link(root); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *schema) resolve() (*schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	name := strings.TrimPrefix(s.Ref, "#/definitions/")
+	if target, ok := s.root.Definitions[name]; ok && name != s.Ref {
+		return target.resolve()
+	}
+	return nil, fmt.Errorf("unsupported schema ref: %s", s.Ref)
+}
+
+// schemaViolation is a problem with the YAML node at the given path.
+type schemaViolation struct {
+	path string
+	node *yaml.Node
+	msg  string
+}
+
+// validate checks the given YAML node against the schema, collecting any
+// problem found in the node and its descendants.
+func (s *schema) validate(path string, node *yaml.Node) []schemaViolation {
+	s, _ = s.resolve() // (*)
+	node = deref(node)
+	violation := func(format string, args ...interface{}) []schemaViolation {
+		return []schemaViolation{
+			{path: path, node: node, msg: fmt.Sprintf(format, args...)},
+		}
+	}
+
+	if len(s.Type) > 0 && !hasType(node, s.Type) {
+		return violation("want: %s; got: %s", strings.Join(s.Type, " or "),
+			nodeType(node))
+	}
+	if len(s.Enum) > 0 && !inEnum(node, s.Enum) {
+		return violation("want: one of %v; got: %s", s.Enum, node.Value)
+	}
+
+	found := []schemaViolation{}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if s.MinLength != nil && len(node.Value) < *s.MinLength {
+			found = append(found,
+				violation("want: at least %d chars", *s.MinLength)...)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(node.Value) {
+			found = append(found,
+				violation("want: value matching %s; got: %s", s.Pattern,
+					node.Value)...)
+		}
+	case yaml.SequenceNode:
+		if s.MinItems != nil && len(node.Content) < *s.MinItems {
+			found = append(found,
+				violation("want: at least %d items; got: %d", *s.MinItems,
+					len(node.Content))...)
+		}
+		if s.Items != nil {
+			for k, item := range node.Content {
+				itemPath := fmt.Sprintf("%s[%d]", path, k)
+				found = append(found, s.Items.validate(itemPath, item)...)
+			}
+		}
+	case yaml.MappingNode:
+		fields := mappingFields(node)
+		for _, name := range s.Required {
+			if _, ok := fields[name]; !ok {
+				found = append(found,
+					violation("missing required field: %s", name)...)
+			}
+		}
+		for _, kv := range mappingEntries(node) {
+			if p, ok := s.Properties[kv.key]; ok {
+				fieldPath := joinSchemaPath(path, kv.key)
+				found = append(found, p.validate(fieldPath, kv.value)...)
+			}
+		}
+	}
+	return found
+
+	// (*) parseSchema makes sure all refs resolve.
+}
+
+func joinSchemaPath(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	return parent + "." + field
+}
+
+func deref(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+func hasType(node *yaml.Node, types []string) bool {
+	actual := nodeType(node)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(node *yaml.Node, values []interface{}) bool {
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return false
+	}
+	if n, ok := value.(int); ok { // (*)
+		value = float64(n)
+	}
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+
+	// (*) JSON numbers decode to float64.
+}
+
+type mappingEntry struct {
+	key     string
+	keyNode *yaml.Node
+	value   *yaml.Node
+}
+
+func mappingEntries(node *yaml.Node) []mappingEntry {
+	entries := []mappingEntry{}
+	for k := 0; k+1 < len(node.Content); k += 2 {
+		entries = append(entries, mappingEntry{
+			key:     node.Content[k].Value,
+			keyNode: node.Content[k],
+			value:   node.Content[k+1],
+		})
+	}
+	return entries
+}
+
+func mappingFields(node *yaml.Node) map[string]*yaml.Node {
+	fields := map[string]*yaml.Node{}
+	if node == nil {
+		return fields
+	}
+	node = deref(node)
+	if node.Kind != yaml.MappingNode {
+		return fields
+	}
+	for _, kv := range mappingEntries(node) {
+		fields[kv.key] = deref(kv.value)
+	}
+	return fields
+}
@@ -0,0 +1,61 @@
+package pkgr
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func validateYaml(t *testing.T, s *schema, data string) []schemaViolation {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatalf("couldn't parse yaml: %v", err)
+	}
+	return s.validate("", doc.Content[0])
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	fixtures := []string{
+		`{"type": 1}`,
+		`{"pattern": "["}`,
+		`{"$ref": "#/definitions/none"}`,
+		`{"properties": {"x": {"$ref": "http://x"}}}`,
+	}
+	for k, d := range fixtures {
+		if _, err := parseSchema([]byte(d)); err == nil {
+			t.Errorf("[%d] want: error; got: nil", k)
+		}
+	}
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	s, err := parseSchema([]byte(`{
+		"type": "object",
+		"properties": {
+			"n": {"type": "number"},
+			"p": {"type": "string", "pattern": "^v[0-9]$"},
+			"e": {"enum": [1, true, "x"]},
+			"l": {"type": "array", "items": {"$ref": "#/definitions/s"}}
+		},
+		"definitions": {"s": {"type": "string", "minLength": 2}}
+	}`))
+	if err != nil {
+		t.Fatalf("want: schema; got: %v", err)
+	}
+	fixtures := []struct {
+		data string
+		want int
+	}{
+		{"n: 1", 0}, {"n: 1.5", 0}, {"n: x", 1},
+		{"p: v1", 0}, {"p: v10", 1},
+		{"e: 1", 0}, {"e: true", 0}, {"e: x", 0}, {"e: 'true'", 1},
+		{"l: [ab, cd]", 0}, {"l: [ab, c, 1]", 2},
+		{"a: &a ab\nl: [*a]", 0},
+		{"[1]", 1},
+	}
+	for k, d := range fixtures {
+		if got := validateYaml(t, s, d.data); len(got) != d.want {
+			t.Errorf("[%d] want: %d violations; got: %v", k, d.want, got)
+		}
+	}
+}
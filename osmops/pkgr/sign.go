@@ -0,0 +1,56 @@
+package pkgr
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// SignatureFileName is the name of the file holding the detached signature
+// of the SHA-256 checksum file in a signed package. The file contains the
+// Ed25519 signature of the checksum file's bytes, base64-encoded, same as
+// what `cosign sign-blob` outputs. So you can check a package's integrity
+// by verifying the signature of the SHA-256 checksum file with the public
+// key and then each file in the package against its hash in the checksum
+// file.
+const SignatureFileName = "checksums.sha256.txt.sig"
+
+// LoadSigningKey reads an Ed25519 private key from the given PEM file.
+// The key has to be in unencrypted PKCS #8 form, e.g. what you get with
+// `openssl genpkey -algorithm ed25519 -out signing.key`. Typically the
+// file comes from a K8s secret mounted on the OSM Ops pod.
+//
+// NOTE. Cosign keys. Cosign's own key files are encrypted with a password
+// and cosign only generates ECDSA keys, so we can't read them. But cosign
+// can verify our signatures if you give it the Ed25519 public key.
+func LoadSigningKey(keyFile file.AbsPath) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(keyFile.Value())
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%v: no PEM private key found", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", keyFile, err)
+	}
+	if edKey, ok := key.(ed25519.PrivateKey); ok {
+		return edKey, nil
+	}
+	return nil, fmt.Errorf("%v: not an Ed25519 private key", keyFile)
+}
+
+func writeSignatureContent(key ed25519.PrivateKey, manifest []byte) io.Reader {
+	signature := ed25519.Sign(key, manifest)
+	encoded := base64.StdEncoding.EncodeToString(signature)
+	return strings.NewReader(encoded)
+}
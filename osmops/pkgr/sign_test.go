@@ -0,0 +1,177 @@
+package pkgr
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+func archiveFiles(t *testing.T, pkg *Package) map[string][]byte {
+	data, err := pkg.OpenData()
+	if err != nil {
+		t.Fatalf("couldn't open package data: %v", err)
+	}
+	reader, err := tgz.NewReader(data)
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	files := map[string][]byte{}
+	err = reader.IterateEntries(
+		func(archivePath string, fi os.FileInfo, content io.Reader) error {
+			if fi.Mode().IsRegular() {
+				files[archivePath], err = io.ReadAll(content)
+			}
+			return err
+		})
+	if err != nil {
+		t.Fatalf("couldn't read package data: %v", err)
+	}
+	return files
+}
+
+func writeKeyFile(t *testing.T, key interface{}) file.AbsPath {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("couldn't marshal key: %v", err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "signing.key")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(keyFile, pemData, 0600); err != nil {
+		t.Fatalf("couldn't write key file: %v", err)
+	}
+	p, _ := file.ParseAbsPath(keyFile)
+	return p
+}
+
+func TestChecksumFileNameFor(t *testing.T) {
+	fixtures := []struct {
+		alg  u.EnumIx
+		want string
+	}{
+		{HashAlgorithm.MD5, "checksums.txt"},
+		{HashAlgorithm.SHA256, "checksums.sha256.txt"},
+		{HashAlgorithm.SHA512, "checksums.sha512.txt"},
+	}
+	for k, d := range fixtures {
+		if got := ChecksumFileNameFor(d.alg); got != d.want {
+			t.Errorf("[%d] want: %s; got: %s", k, d.want, got)
+		}
+	}
+}
+
+func TestPackWithChecksums(t *testing.T) {
+	source := findTestDataDir("openldap_nested")
+	pkg, err := Pack(source, WithChecksums(HashAlgorithm.SHA256))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	files := archiveFiles(t, pkg)
+	if _, ok := files["openldap_nested/checksums.txt"]; !ok {
+		t.Errorf("want: md5 checksum file; got: none")
+	}
+	if _, ok := files["openldap_nested/checksums.sha512.txt"]; ok {
+		t.Errorf("want: no sha512 checksum file; got: one")
+	}
+	if _, ok := files["openldap_nested/"+SignatureFileName]; ok {
+		t.Errorf("want: no signature; got: one")
+	}
+
+	want := ""
+	for _, p := range pkg.Source.SortedFilePaths() {
+		want += fmt.Sprintf("%x\t%s\n", sha256.Sum256(files[p]), p)
+	}
+	got := string(files["openldap_nested/checksums.sha256.txt"])
+	if want != got {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+}
+
+func TestPackChecksumsDontChangeMd5File(t *testing.T) {
+	source := findTestDataDir("openldap_nested")
+	plain, err := Pack(source)
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer plain.Data.Close()
+	extra, err := Pack(source,
+		WithChecksums(HashAlgorithm.SHA256, HashAlgorithm.SHA512))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer extra.Data.Close()
+
+	want := archiveFiles(t, plain)["openldap_nested/checksums.txt"]
+	got := archiveFiles(t, extra)["openldap_nested/checksums.txt"]
+	if string(want) != string(got) {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+}
+
+func TestPackWithSigningKey(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	key, err := LoadSigningKey(writeKeyFile(t, priv))
+	if err != nil {
+		t.Fatalf("want: key; got: %v", err)
+	}
+
+	source := findTestDataDir("openldap_nested")
+	pkg, err := Pack(source, WithSigningKey(key))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	files := archiveFiles(t, pkg)
+	manifest, ok := files["openldap_nested/checksums.sha256.txt"]
+	if !ok {
+		t.Fatalf("want: sha256 checksum file; got: none")
+	}
+	encoded := files["openldap_nested/"+SignatureFileName]
+	signature, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		t.Fatalf("want: base64 signature; got: %v", err)
+	}
+	if !ed25519.Verify(pub, manifest, signature) {
+		t.Errorf("want: valid signature; got: invalid")
+	}
+}
+
+func TestLoadSigningKeyErrors(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	notPem := filepath.Join(t.TempDir(), "not.pem")
+	os.WriteFile(notPem, []byte("not a key"), 0600)
+	notPemPath, _ := file.ParseAbsPath(notPem)
+	missing, _ := file.ParseAbsPath("no/where/key")
+
+	fixtures := []struct {
+		keyFile file.AbsPath
+		want    string
+	}{
+		{missing, "no such file"},
+		{notPemPath, "no PEM private key"},
+		{writeKeyFile(t, ecKey), "not an Ed25519"},
+	}
+	for k, d := range fixtures {
+		_, err := LoadSigningKey(d.keyFile)
+		if err == nil || !strings.Contains(err.Error(), d.want) {
+			t.Errorf("[%d] want: %s; got: %v", k, d.want, err)
+		}
+	}
+}
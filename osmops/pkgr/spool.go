@@ -0,0 +1,87 @@
+package pkgr
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// NOTE. Streaming packages.
+// OSM NBI wants the MD5 of the package in a header, so we've got to know
+// the hash before we send the first byte of the body. This rules out piping
+// the tarball straight from the writer into the HTTP request. So we spool
+// the tarball to a temp file instead, computing the MD5 as we write it.
+// This way we never hold the package in memory, which matters if it's got
+// big Helm charts or VM images in it, and we can read the package as many
+// times as we like---e.g. to resend the request after a transient error.
+
+// spool is a temp file holding the gzipped tar stream of a package.
+type spool struct {
+	file   *os.File
+	hasher hash.Hash
+	size   int64
+}
+
+func newSpool() (*spool, error) {
+	fd, err := os.CreateTemp("", "osm-pkg-*.tar.gz")
+	if err != nil {
+		return nil, err
+	}
+	return &spool{file: fd, hasher: md5.New()}, nil
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	n, err := s.file.Write(p)
+	s.hasher.Write(p[:n])
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *spool) Close() error {
+	return s.file.Close()
+}
+
+func (s *spool) path() string {
+	return s.file.Name()
+}
+
+func (s *spool) hash() string {
+	return fmt.Sprintf("%x", s.hasher.Sum(nil))
+}
+
+func (s *spool) remove() {
+	s.file.Close()
+	os.Remove(s.path())
+}
+
+// spooledData reads the package tarball from the spool file and deletes
+// the file on Close. Close also runs the cleanup function, if any, to get
+// rid of any other temp file that has to live as long as the package.
+type spooledData struct {
+	*os.File
+	cleanup func()
+}
+
+func (d *spooledData) Close() error {
+	err := d.File.Close()
+	if rmErr := os.Remove(d.Name()); err == nil && !os.IsNotExist(rmErr) {
+		err = rmErr
+	}
+	if d.cleanup != nil {
+		d.cleanup()
+		d.cleanup = nil
+	}
+	return err
+}
+
+func openSpooledData(path string, cleanup func()) (*spooledData, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &spooledData{File: fd, cleanup: cleanup}, nil
+}
+
+var _ io.ReadCloser = &spooledData{}
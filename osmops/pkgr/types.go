@@ -7,7 +7,7 @@ import (
 	"path/filepath"
 	"sort"
 
-	"github.com/fluxcd/source-watcher/osmops/util/bytez"
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
@@ -38,18 +38,62 @@ type Package struct {
 	//     7044f64c16d4ef3eeef7f8668a4dc5a1	my-pkg/knf/vnfd.yaml
 	//     6cbc0db17616eff57c60efa0eb15ac76	my-pkg/nsd.yaml
 	//
+	// If you ask Pack for other hash algorithms, the stream contains a
+	// checksum file with the same format for each of them, e.g.
+	// "r/checksums.sha256.txt". (See: ChecksumFileNameFor) Likewise, if
+	// you give Pack a signing key, the stream contains a detached signature
+	// of the SHA-256 checksum file at "r/checksums.sha256.txt.sig". (See:
+	// SignatureFileName)
+	//
+	// The stream reads from a temp file which gets deleted when you close
+	// the stream. If Pack built the package out of a manifest, closing the
+	// stream also deletes the temp copy of the source files Source points
+	// to, so you can't read the Source files after that.
 	Data io.ReadCloser
 	// MD5 hash of the whole gzipped tar stream.
 	Hash string
+	// Size of the whole gzipped tar stream, in bytes.
+	Size int64
+	// The package format, OSM unless you asked Pack for a CSAR package.
+	// In that case, Data, Hash and Size refer to the zip stream instead.
+	// (See: PackageFormat)
+	Format u.EnumIx
+
+	dataPath string
 }
 
-func makePackage(src PackageSource, data *bytez.Buffer) *Package {
+func makePackage(src PackageSource, data *spool, cleanup func()) (
+	*Package, error) {
+	reader, err := openSpooledData(data.path(), cleanup)
+	if err != nil {
+		return nil, err
+	}
 	return &Package{
-		Name:   src.DirectoryName(),
-		Source: src,
-		Data:   data,
-		Hash:   md5string(data.Bytes()),
+		Name:     src.DirectoryName(),
+		Source:   src,
+		Data:     reader,
+		Hash:     data.hash(),
+		Size:     data.size,
+		dataPath: data.path(),
+	}, nil
+}
+
+// FileName returns the name of the package archive file, i.e. the package
+// name plus ".tar.gz" for an OSM package or ".zip" for a CSAR one.
+func (p *Package) FileName() string {
+	if p.Format == PackageFormat.CSAR {
+		return p.Name + ".zip"
 	}
+	return p.Name + ".tar.gz"
+}
+
+// OpenData returns a new stream to read the gzipped tar data from the
+// start, independently of Data and any other stream OpenData returned
+// before. Use it to read the package more than once. Close each stream
+// when done. Streams opened before closing Data keep on working after
+// that, but OpenData fails once Data is closed.
+func (p *Package) OpenData() (io.ReadCloser, error) {
+	return os.Open(p.dataPath)
 }
 
 // PackageSource provides metadata about an OSM package's source files
@@ -69,6 +113,11 @@ type PackageSource interface {
 	// The filePath argument must be one of the paths returned by
 	// SortedFilePaths.
 	FileHash(filePath string) string
+	// Lookup the hash of a source file in the package computed with the
+	// given HashAlgorithm. It's the empty string if Pack didn't use that
+	// algorithm. The filePath argument must be one of the paths returned
+	// by SortedFilePaths.
+	FileDigest(filePath string, alg u.EnumIx) string
 	// FileContent returns the bytes that make up the specified file in the
 	// package.
 	// The filePath argument must be one of the paths returned by
@@ -77,17 +126,28 @@ type PackageSource interface {
 }
 
 type pkgSrc struct {
-	srcDir        file.AbsPath
-	srcDirName    string
-	pathToHashMap map[string]string
+	srcDir     file.AbsPath
+	srcDirName string
+	algs       []u.EnumIx
+	digests    map[u.EnumIx]map[string]string
+	ignores    file.IgnoreList
 }
 
-func newPkgSrc(srcDir file.AbsPath) *pkgSrc {
-	return &pkgSrc{
-		srcDir:        srcDir,
-		srcDirName:    path.Base(srcDir.Value()),
-		pathToHashMap: make(map[string]string),
+// newPkgSrc creates a pkgSrc to compute the MD5 hashes of the package
+// source files plus their hashes for any other given algorithm.
+func newPkgSrc(srcDir file.AbsPath, algs ...u.EnumIx) *pkgSrc {
+	src := &pkgSrc{
+		srcDir:     srcDir,
+		srcDirName: path.Base(srcDir.Value()),
+		digests:    make(map[u.EnumIx]map[string]string),
+	}
+	for _, alg := range append([]u.EnumIx{HashAlgorithm.MD5}, algs...) {
+		if _, ok := src.digests[alg]; !ok {
+			src.algs = append(src.algs, alg)
+			src.digests[alg] = make(map[string]string)
+		}
 	}
+	return src
 }
 
 func (p *pkgSrc) Directory() file.AbsPath {
@@ -99,8 +159,9 @@ func (p *pkgSrc) DirectoryName() string {
 }
 
 func (p *pkgSrc) SortedFilePaths() []string {
-	keys := make([]string, 0, len(p.pathToHashMap))
-	for k := range p.pathToHashMap {
+	md5s := p.digests[HashAlgorithm.MD5]
+	keys := make([]string, 0, len(md5s))
+	for k := range md5s {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
@@ -109,7 +170,11 @@ func (p *pkgSrc) SortedFilePaths() []string {
 }
 
 func (p *pkgSrc) FileHash(filePath string) string {
-	if hash, ok := p.pathToHashMap[filePath]; ok {
+	return p.FileDigest(filePath, HashAlgorithm.MD5)
+}
+
+func (p *pkgSrc) FileDigest(filePath string, alg u.EnumIx) string {
+	if hash, ok := p.digests[alg][filePath]; ok {
 		return hash
 	}
 	return ""
@@ -119,10 +184,12 @@ func (p *pkgSrc) addFileHash(node file.TreeNode) error {
 	if !node.FsMeta.Mode().IsRegular() {
 		return nil
 	}
-	hash, err := computeChecksum(node.NodePath)
+	hashes, err := computeChecksums(node.NodePath, p.algs...)
 	if err == nil {
 		baseNamePlusPath := path.Join(p.srcDirName, node.RelPath)
-		p.pathToHashMap[baseNamePlusPath] = hash
+		for alg, hash := range hashes {
+			p.digests[alg][baseNamePlusPath] = hash
+		}
 	}
 	return err
 }
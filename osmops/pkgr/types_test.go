@@ -32,6 +32,7 @@ func TestFileContentReadsAllFileIntoMem(t *testing.T) {
 	if err != nil {
 		t.Fatalf("couldn't pack: %v; error: %v", source, err)
 	}
+	defer pkg.Data.Close()
 
 	archiveFilePath := ""
 	for _, p := range pkg.Source.SortedFilePaths() {
@@ -55,6 +56,7 @@ func TestFileContentErrWhenUsingPathNotRetrievedFromSortedFilePaths(t *testing.T
 	if err != nil {
 		t.Fatalf("couldn't pack: %v; error: %v", source, err)
 	}
+	defer pkg.Data.Close()
 
 	// missing pkg dir; SortedFilePaths would've returned:
 	// - openldap_nested/knf/openldap_vnfd.yaml
@@ -0,0 +1,349 @@
+package pkgr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+	"github.com/fluxcd/source-watcher/osmops/util/tgz"
+)
+
+// VerifyError is a problem Unpack found when checking a package's files
+// against its checksum files.
+type VerifyError struct {
+	// Path of the offending file in the archive, e.g. "my-pkg/d/f".
+	File string
+	// What's wrong.
+	Msg string
+}
+
+// Error implements the standard error interface.
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, e.Msg)
+}
+
+// VerifyErrors collects all the problems Unpack found in a package.
+type VerifyErrors []*VerifyError
+
+// Error implements the standard error interface, listing each problem
+// on a separate line.
+func (es VerifyErrors) Error() string {
+	lines := make([]string, len(es))
+	for k, e := range es {
+		lines[k] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type unpackOpts struct {
+	publicKey     ed25519.PublicKey
+	noChecksumsOk bool
+}
+
+// UnpackOption tweaks the way Unpack checks a package.
+type UnpackOption func(opts *unpackOpts)
+
+// WithPublicKey makes Unpack check the package signature with the given
+// key. The package has to contain a signature and a SHA-256 checksum
+// file, otherwise Unpack fails. (See: SignatureFileName)
+func WithPublicKey(key ed25519.PublicKey) UnpackOption {
+	return func(opts *unpackOpts) {
+		opts.publicKey = key
+	}
+}
+
+// AllowMissingChecksums makes Unpack accept packages without the MD5
+// checksum file, e.g. packages someone built by hand and uploaded to OSM.
+// Unpack still checks the files against any checksum files there are.
+func AllowMissingChecksums() UnpackOption {
+	return func(opts *unpackOpts) {
+		opts.noChecksumsOk = true
+	}
+}
+
+// Unpack extracts the OSM package in the given archive stream to the
+// specified directory. This is the inverse of Pack: if the package root
+// directory in the archive is "r", Unpack recreates it as destDir/r and
+// returns a PackageSource for it. The archive can be a gzipped tar, a plain
+// tar or a zip, e.g. what NBI sends back for a multi-file package. Unpack
+// figures out which from the first few bytes. (See: tgz.DetectFormat)
+//
+// Unpack only accepts archives in the OSM package format. (See: Package)
+// All the entries have to be in the same root directory and they can only
+// be regular files or directories. The archive has to have a checksum file
+// listing all the files in the package, each with its MD5 hash. If there
+// are checksum files for other hash algorithms, they have to list the same
+// files. (Use AllowMissingChecksums to accept packages without checksum
+// files.) Unpack checks each file against each checksum file and reports
+// all the problems it finds in a VerifyErrors. If you give it a key through
+// WithPublicKey, Unpack also checks the package signature.
+//
+// Unpack extracts the archive to a staging directory within destDir and
+// only moves it to destDir/r if all checks pass. So destDir/r won't exist
+// if Unpack fails. Unpack also fails if destDir/r already exists.
+func Unpack(tarball io.ReadCloser, destDir file.AbsPath,
+	opts ...UnpackOption) (PackageSource, error) {
+	cfg := &unpackOpts{}
+	for _, setting := range opts {
+		if setting != nil {
+			setting(cfg)
+		}
+	}
+
+	reader, err := tgz.NewDetectingReader(tarball)
+	if err != nil {
+		return nil, err
+	}
+	stagingDir, err := os.MkdirTemp(destDir.Value(), ".osm-pkg-*")
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	unpacker := &pkgUnpacker{
+		stagingDir: stagingDir,
+		digests:    map[string]map[u.EnumIx]string{},
+		manifests:  map[string][]byte{},
+	}
+	if err := reader.IterateEntries(unpacker.extract); err != nil {
+		return nil, err
+	}
+	if unpacker.rootDir == "" {
+		return nil, fmt.Errorf("empty package")
+	}
+	if es := unpacker.verify(cfg); len(es) > 0 {
+		return nil, es
+	}
+	return unpacker.moveTo(destDir)
+}
+
+type pkgUnpacker struct {
+	stagingDir string
+	rootDir    string
+	digests    map[string]map[u.EnumIx]string
+	manifests  map[string][]byte
+}
+
+var unpackHashAlgorithms = []u.EnumIx{
+	HashAlgorithm.MD5, HashAlgorithm.SHA256, HashAlgorithm.SHA512,
+}
+
+func (p *pkgUnpacker) extract(archivePath string, fi os.FileInfo,
+	content io.Reader) error {
+	entryPath, err := p.checkEntryPath(archivePath)
+	if err != nil || fi.IsDir() {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: unsupported entry type: %v", archivePath,
+			fi.Mode().Type())
+	}
+
+	targetPath := filepath.Join(p.stagingDir, filepath.FromSlash(entryPath))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	fd, err := os.OpenFile(targetPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+		fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	if p.isManifest(entryPath) {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		p.manifests[entryPath] = data
+		_, err = fd.Write(data)
+		return err
+	}
+
+	hashes := map[u.EnumIx]hash.Hash{}
+	sinks := []io.Writer{fd}
+	for _, alg := range unpackHashAlgorithms {
+		hashes[alg] = newHash(alg)
+		sinks = append(sinks, hashes[alg])
+	}
+	if _, err := io.Copy(io.MultiWriter(sinks...), content); err != nil {
+		return err
+	}
+	p.digests[entryPath] = map[u.EnumIx]string{}
+	for alg, h := range hashes {
+		p.digests[entryPath][alg] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return nil
+}
+
+// checkEntryPath makes sure the archive path is relative, doesn't climb
+// out of the package root and is in the same root dir as the other entries.
+func (p *pkgUnpacker) checkEntryPath(archivePath string) (string, error) {
+	entryPath := path.Clean(strings.TrimPrefix(archivePath, "./"))
+	if path.IsAbs(entryPath) || entryPath == ".." ||
+		strings.HasPrefix(entryPath, "../") {
+		return "", fmt.Errorf("%s: path outside of package", archivePath)
+	}
+	root := strings.SplitN(entryPath, "/", 2)[0]
+	if p.rootDir == "" {
+		p.rootDir = root
+	}
+	if root != p.rootDir {
+		return "", fmt.Errorf("%s: more than one package root dir", archivePath)
+	}
+	return entryPath, nil
+}
+
+// ManifestFileNames returns the names of the files Pack generates in the
+// package root directory, i.e. the checksum files and the signature. Delete
+// them from an unpacked package before packing it again, otherwise Pack
+// treats them as package source files.
+func ManifestFileNames() []string {
+	names := []string{}
+	for _, alg := range unpackHashAlgorithms {
+		names = append(names, ChecksumFileNameFor(alg))
+	}
+	return append(names, SignatureFileName)
+}
+
+func (p *pkgUnpacker) isManifest(entryPath string) bool {
+	for _, name := range ManifestFileNames() {
+		if entryPath == path.Join(p.rootDir, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pkgUnpacker) verify(cfg *unpackOpts) VerifyErrors {
+	es := VerifyErrors{}
+	for _, alg := range unpackHashAlgorithms {
+		manifestPath := path.Join(p.rootDir, ChecksumFileNameFor(alg))
+		manifest, ok := p.manifests[manifestPath]
+		if !ok {
+			if alg == HashAlgorithm.MD5 && !cfg.noChecksumsOk {
+				es = append(es, &VerifyError{
+					File: manifestPath, Msg: "missing checksum file",
+				})
+			}
+			continue
+		}
+		es = append(es, p.verifyManifest(manifestPath, manifest, alg)...)
+	}
+	if cfg.publicKey != nil {
+		if err := p.verifySignature(cfg.publicKey); err != nil {
+			es = append(es, err)
+		}
+	}
+	return es
+}
+
+var manifestLine = regexp.MustCompile(`^([0-9a-fA-F]+)\s+(.+)$`)
+
+func (p *pkgUnpacker) verifyManifest(manifestPath string, manifest []byte,
+	alg u.EnumIx) VerifyErrors {
+	es := VerifyErrors{}
+	listed := map[string]bool{}
+	lines := bufio.NewScanner(bytes.NewReader(manifest))
+	for n := 1; lines.Scan(); n++ {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" {
+			continue
+		}
+		match := manifestLine.FindStringSubmatch(line)
+		if match == nil {
+			es = append(es, &VerifyError{
+				File: manifestPath,
+				Msg:  fmt.Sprintf("line %d: not a checksum entry", n),
+			})
+			continue
+		}
+		want, filePath := strings.ToLower(match[1]), match[2]
+		listed[filePath] = true
+		digests, ok := p.digests[filePath]
+		if !ok {
+			es = append(es, &VerifyError{
+				File: filePath,
+				Msg:  fmt.Sprintf("listed in %s but not in package", manifestPath),
+			})
+			continue
+		}
+		if got := digests[alg]; got != want {
+			es = append(es, &VerifyError{
+				File: filePath,
+				Msg: fmt.Sprintf("%s mismatch: want: %s; got: %s",
+					HashAlgorithm.LabelOf(alg), want, got),
+			})
+		}
+	}
+	for _, filePath := range p.sortedFilePaths() {
+		if !listed[filePath] {
+			es = append(es, &VerifyError{
+				File: filePath,
+				Msg:  fmt.Sprintf("not listed in %s", manifestPath),
+			})
+		}
+	}
+	return es
+}
+
+func (p *pkgUnpacker) verifySignature(key ed25519.PublicKey) *VerifyError {
+	sigPath := path.Join(p.rootDir, SignatureFileName)
+	encoded, ok := p.manifests[sigPath]
+	if !ok {
+		return &VerifyError{File: sigPath, Msg: "missing signature"}
+	}
+	manifestPath := path.Join(p.rootDir,
+		ChecksumFileNameFor(HashAlgorithm.SHA256))
+	manifest, ok := p.manifests[manifestPath]
+	if !ok {
+		return &VerifyError{File: manifestPath, Msg: "missing checksum file"}
+	}
+	signature, err := base64.StdEncoding.DecodeString(
+		strings.TrimSpace(string(encoded)))
+	if err != nil || !ed25519.Verify(key, manifest, signature) {
+		return &VerifyError{File: sigPath, Msg: "invalid signature"}
+	}
+	return nil
+}
+
+func (p *pkgUnpacker) sortedFilePaths() []string {
+	paths := make([]string, 0, len(p.digests))
+	for k := range p.digests {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (p *pkgUnpacker) moveTo(destDir file.AbsPath) (PackageSource, error) {
+	target := destDir.Join(p.rootDir)
+	if _, err := os.Lstat(target.Value()); err == nil {
+		return nil, fmt.Errorf("%v: already exists", target)
+	}
+	staged := filepath.Join(p.stagingDir, p.rootDir)
+	if err := os.Rename(staged, target.Value()); err != nil {
+		return nil, err
+	}
+
+	src := newPkgSrc(target, unpackHashAlgorithms...)
+	for filePath, digests := range p.digests {
+		for alg, hash := range digests {
+			src.digests[alg][filePath] = hash
+		}
+	}
+	return src, nil
+}
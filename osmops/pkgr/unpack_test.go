@@ -0,0 +1,280 @@
+package pkgr
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+type tarEntry struct {
+	name    string
+	content string
+	mode    int64
+	kind    byte
+}
+
+func makeTarball(t *testing.T, entries ...tarEntry) io.ReadCloser {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name: e.name, Mode: e.mode, Size: int64(len(e.content)),
+			Typeflag: e.kind,
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0644
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("couldn't write tar header: %v", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.content))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return io.NopCloser(buf)
+}
+
+func checksumEntry(files ...tarEntry) tarEntry {
+	content := ""
+	for _, f := range files {
+		content += fmt.Sprintf("%s\t%s\n", md5string([]byte(f.content)),
+			f.name)
+	}
+	return tarEntry{name: "p/" + ChecksumFileName, content: content}
+}
+
+func tempDestDir(t *testing.T) file.AbsPath {
+	dir, _ := file.ParseAbsPath(t.TempDir())
+	return dir
+}
+
+func unpackErrors(t *testing.T, err error) []string {
+	es, ok := err.(VerifyErrors)
+	if !ok {
+		t.Fatalf("want: verify errors; got: %v", err)
+	}
+	got := []string{}
+	for _, e := range es {
+		got = append(got, e.Error())
+	}
+	return got
+}
+
+func TestUnpackWhatPackPacked(t *testing.T) {
+	pkg, err := Pack(findTestDataDir("openldap_nested"),
+		WithChecksums(HashAlgorithm.SHA512))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	destDir := tempDestDir(t)
+
+	src, err := Unpack(pkg.Data, destDir)
+	if err != nil {
+		t.Fatalf("want: package source; got: %v", err)
+	}
+
+	if got := src.Directory(); got != destDir.Join("openldap_nested") {
+		t.Errorf("want: %v; got: %v", destDir.Join("openldap_nested"), got)
+	}
+	want := pkg.Source.SortedFilePaths()
+	if got := src.SortedFilePaths(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	for _, p := range want {
+		if pkg.Source.FileHash(p) != src.FileHash(p) {
+			t.Errorf("[%s] want: %s; got: %s", p, pkg.Source.FileHash(p),
+				src.FileHash(p))
+		}
+		wantContent, _ := pkg.Source.FileContent(p)
+		gotContent, err := src.FileContent(p)
+		if err != nil || !bytes.Equal(wantContent, gotContent) {
+			t.Errorf("[%s] want: same content; got: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(src.Directory().Join(ChecksumFileName).Value()); err != nil {
+		t.Errorf("want: extracted checksum file; got: %v", err)
+	}
+}
+
+func TestUnpackSignedPackage(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	pack := func() io.ReadCloser {
+		pkg, err := Pack(findTestDataDir("openldap_knf"), WithSigningKey(priv))
+		if err != nil {
+			t.Fatalf("want: package; got: %v", err)
+		}
+		return pkg.Data
+	}
+
+	if _, err := Unpack(pack(), tempDestDir(t), WithPublicKey(pub)); err != nil {
+		t.Errorf("want: valid signature; got: %v", err)
+	}
+
+	_, err := Unpack(pack(), tempDestDir(t), WithPublicKey(otherPub))
+	want := []string{"openldap_knf/" + SignatureFileName + ": invalid signature"}
+	if got := unpackErrors(t, err); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestUnpackUnsignedPackageWithPublicKey(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	f := tarEntry{name: "p/f", content: "f"}
+	tarball := makeTarball(t, f, checksumEntry(f))
+
+	_, err := Unpack(tarball, tempDestDir(t), WithPublicKey(pub))
+	want := []string{"p/" + SignatureFileName + ": missing signature"}
+	if got := unpackErrors(t, err); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestUnpackReportsAllChecksumProblems(t *testing.T) {
+	f1 := tarEntry{name: "p/f1", content: "f1"}
+	f2 := tarEntry{name: "p/d/f2", content: "f2"}
+	f3 := tarEntry{name: "p/f3", content: "f3"}
+	tampered := tarEntry{name: "p/f1", content: "tampered"}
+	manifest := checksumEntry(f1, f2)
+	manifest.content += "garbage\nabc123\tp/gone\n"
+	destDir := tempDestDir(t)
+
+	_, err := Unpack(makeTarball(t, tampered, f3, manifest), destDir)
+
+	want := []string{
+		fmt.Sprintf("p/f1: md5 mismatch: want: %s; got: %s",
+			md5string([]byte("f1")), md5string([]byte("tampered"))),
+		"p/d/f2: listed in p/checksums.txt but not in package",
+		"p/checksums.txt: line 3: not a checksum entry",
+		"p/gone: listed in p/checksums.txt but not in package",
+		"p/f3: not listed in p/checksums.txt",
+	}
+	if got := unpackErrors(t, err); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	if _, err := os.Stat(destDir.Join("p").Value()); !os.IsNotExist(err) {
+		t.Errorf("want: nothing extracted; got: %v", err)
+	}
+	if leftovers, _ := os.ReadDir(destDir.Value()); len(leftovers) != 0 {
+		t.Errorf("want: no staging dir left behind; got: %v", leftovers)
+	}
+}
+
+func TestUnpackMissingChecksumFile(t *testing.T) {
+	tarball := makeTarball(t, tarEntry{name: "p/f", content: "f"})
+	_, err := Unpack(tarball, tempDestDir(t))
+	want := []string{"p/checksums.txt: missing checksum file"}
+	if got := unpackErrors(t, err); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestUnpackAllowMissingChecksumFile(t *testing.T) {
+	tarball := makeTarball(t, tarEntry{name: "p/f", content: "f"})
+	dest := tempDestDir(t)
+	src, err := Unpack(tarball, dest, AllowMissingChecksums())
+	if err != nil {
+		t.Fatalf("want: unpacked; got: %v", err)
+	}
+	if got := src.SortedFilePaths(); !reflect.DeepEqual(got, []string{"p/f"}) {
+		t.Errorf("want: [p/f]; got: %v", got)
+	}
+	if content, _ := os.ReadFile(dest.Join("p/f").Value()); string(content) != "f" {
+		t.Errorf("want: f; got: %s", content)
+	}
+}
+
+func TestUnpackAllowMissingChecksumFileStillChecksOthers(t *testing.T) {
+	f := tarEntry{name: "p/f", content: "f"}
+	sha := tarEntry{
+		name:    "p/" + ChecksumFileNameFor(HashAlgorithm.SHA256),
+		content: "abc\tp/f\n",
+	}
+	_, err := Unpack(makeTarball(t, f, sha), tempDestDir(t),
+		AllowMissingChecksums())
+	if got := unpackErrors(t, err); len(got) != 1 ||
+		!strings.HasPrefix(got[0], "p/f: sha256 mismatch") {
+		t.Errorf("want: sha256 mismatch; got: %v", got)
+	}
+}
+
+func TestUnpackRejectsNonPackageArchives(t *testing.T) {
+	f := tarEntry{name: "p/f", content: "f"}
+	fixtures := []struct {
+		entries []tarEntry
+		want    string
+	}{
+		{[]tarEntry{}, "empty package"},
+		{[]tarEntry{f, {name: "../f", content: "x"}}, "outside of package"},
+		{[]tarEntry{f, {name: "/etc/f", content: "x"}}, "outside of package"},
+		{[]tarEntry{f, {name: "q/f", content: "x"}}, "more than one package root"},
+		{[]tarEntry{f, {name: "p/l", kind: tar.TypeSymlink}}, "unsupported entry type"},
+	}
+	for k, d := range fixtures {
+		_, err := Unpack(makeTarball(t, d.entries...), tempDestDir(t))
+		if err == nil || !strings.Contains(err.Error(), d.want) {
+			t.Errorf("[%d] want: %s; got: %v", k, d.want, err)
+		}
+	}
+}
+
+func TestUnpackWontOverwrite(t *testing.T) {
+	f := tarEntry{name: "p/f", content: "f"}
+	destDir := tempDestDir(t)
+	os.Mkdir(destDir.Join("p").Value(), 0755)
+
+	_, err := Unpack(makeTarball(t, f, checksumEntry(f)), destDir)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("want: already exists error; got: %v", err)
+	}
+}
+
+func TestUnpackNotAnArchive(t *testing.T) {
+	tarball := io.NopCloser(strings.NewReader("not an archive"))
+	if _, err := Unpack(tarball, tempDestDir(t)); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestUnpackZip(t *testing.T) {
+	f := tarEntry{name: "p/f", content: "f"}
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, e := range []tarEntry{f, checksumEntry(f)} {
+		w, _ := zw.Create(e.name)
+		w.Write([]byte(e.content))
+	}
+	zw.Close()
+
+	dest := tempDestDir(t)
+	src, err := Unpack(io.NopCloser(buf), dest)
+	if err != nil {
+		t.Fatalf("want: unpacked; got: %v", err)
+	}
+	if got := src.SortedFilePaths(); !reflect.DeepEqual(got, []string{"p/f"}) {
+		t.Errorf("want: [p/f]; got: %v", got)
+	}
+	if content, _ := os.ReadFile(dest.Join("p/f").Value()); string(content) != "f" {
+		t.Errorf("want: f; got: %s", content)
+	}
+}
@@ -0,0 +1,138 @@
+package pkgr
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+// NOTE. Package versions.
+// OSM identifies a package by the ID of the VNFD or NSD in it, so uploading
+// a new version of a package overwrites the old one. To keep each version
+// around, e.g. to roll back, we on-board it under its own descriptor ID,
+// the versioned ID, made out of the descriptor ID and the version. Pack
+// renames the descriptors on the fly (see: WithDescriptorIds) so the package
+// sources never have to change.
+
+var unsafeVersionChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+var versionPattern = regexp.MustCompile(`^[a-zA-Z0-9.-]+$`)
+
+// VersionedId returns the descriptor ID to on-board the given version of
+// the descriptor with, e.g. "openldap_knf-1.0" for version "1.0" of the
+// "openldap_knf" VNFD. Any run of characters in the version other than
+// letters, digits, dots and dashes becomes a dot. Underscores too: package
+// names always have one (see: nbic.Workflow) so IsVersionOf can tell the
+// versions of "a_knf" from those of a package named, say, "a_knf-b_knf".
+func VersionedId(id, version string) string {
+	return id + "-" + unsafeVersionChars.ReplaceAllString(version, ".")
+}
+
+// IsVersionOf tells if versionedId could be the ID VersionedId returned
+// for some version of the descriptor with the given ID, i.e. it's the ID
+// followed by a dash and a version made up of letters, digits, dots and
+// dashes only.
+func IsVersionOf(versionedId, id string) bool {
+	prefix := id + "-"
+	return strings.HasPrefix(versionedId, prefix) &&
+		versionPattern.MatchString(versionedId[len(prefix):])
+}
+
+// WithDescriptorIds makes Pack rename the descriptors in the package
+// according to the given map from descriptor ID to new ID, typically a
+// versioned ID. (See: VersionedId) Pack renames VNFD and NSD IDs as well
+// as the VNFD IDs each NSD references, so an NSD can refer to the new
+// IDs of VNFDs in other packages. If the package name, i.e. the name of
+// the package source directory, is in the map, Pack also names the
+// package after the new ID. Lint and ReadDescriptors look at the renamed
+// descriptors too.
+func WithDescriptorIds(ids map[string]string) PackOption {
+	return func(opts *packOpts) {
+		if opts.descriptorIds == nil {
+			opts.descriptorIds = map[string]string{}
+		}
+		for id, newId := range ids {
+			opts.descriptorIds[id] = newId
+		}
+	}
+}
+
+// renameDescriptors rewrites, in place, the descriptor files in the given
+// directory according to the ids map. (See: WithDescriptorIds)
+func renameDescriptors(dir file.AbsPath, ids map[string]string) error {
+	scanner := file.NewTreeScanner(dir)
+	es := scanner.Visit(func(node file.TreeNode) error {
+		if !isYamlFile(node) || isInArtefactDir(node.RelPath) {
+			return nil
+		}
+		content, err := os.ReadFile(node.NodePath.Value())
+		if err != nil {
+			return err
+		}
+		renamed, ok := renameDescriptorIds(content, ids)
+		if !ok {
+			return nil
+		}
+		return os.WriteFile(node.NodePath.Value(), renamed,
+			node.FsMeta.Mode().Perm())
+	})
+	if len(es) > 0 {
+		return es[0]
+	}
+	return nil
+}
+
+// renameDescriptorIds returns the content with the IDs renamed and true if
+// it's a descriptor with any ID in the map, otherwise nil and false.
+func renameDescriptorIds(content []byte, ids map[string]string) (
+	[]byte, bool) {
+	doc := yaml.Node{}
+	if err := yaml.Unmarshal(content, &doc); err != nil ||
+		len(doc.Content) == 0 {
+		return nil, false // not a descriptor
+	}
+	renamed := false
+	rename := func(ref *yaml.Node) {
+		if ref == nil || ref.Kind != yaml.ScalarNode {
+			return
+		}
+		if newId, ok := ids[ref.Value]; ok {
+			ref.Value = newId
+			renamed = true
+		}
+	}
+
+	fields := mappingFields(deref(doc.Content[0]))
+	rename(mappingFields(fields["vnfd"])["id"])
+	nsds := mappingFields(fields["nsd"])["nsd"]
+	forEachItem(nsds, "", func(_ string, nsd *yaml.Node) {
+		nsdFields := mappingFields(nsd)
+		rename(nsdFields["id"])
+		forEachItem(nsdFields["vnfd-id"], "", func(_ string, ref *yaml.Node) {
+			rename(ref)
+		})
+		forEachItem(nsdFields["df"], "", func(_ string, df *yaml.Node) {
+			profiles := mappingFields(df)["vnf-profile"]
+			forEachItem(profiles, "", func(_ string, profile *yaml.Node) {
+				rename(mappingFields(profile)["vnfd-id"])
+			})
+		})
+	})
+	if !renamed {
+		return nil, false
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, false
+	}
+	encoder.Close()
+	return buf.Bytes(), true
+}
@@ -0,0 +1,148 @@
+package pkgr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionedId(t *testing.T) {
+	cases := []struct {
+		id, version, want string
+	}{
+		{"openldap_knf", "1.0", "openldap_knf-1.0"},
+		{"openldap_knf", "main/6e8a2c1", "openldap_knf-main.6e8a2c1"},
+		{"openldap_ns", "1.0 rc 1", "openldap_ns-1.0.rc.1"},
+		{"openldap_ns", "1.0-rc_1", "openldap_ns-1.0-rc.1"},
+	}
+	for k, c := range cases {
+		got := VersionedId(c.id, c.version)
+		if got != c.want {
+			t.Errorf("[%d] want: %s; got: %s", k, c.want, got)
+		}
+		if !IsVersionOf(got, c.id) {
+			t.Errorf("[%d] want: version of %s; got: false", k, c.id)
+		}
+	}
+}
+
+func TestIsVersionOf(t *testing.T) {
+	cases := []struct {
+		versionedId, id string
+		want            bool
+	}{
+		{"openldap_knf-1.0", "openldap_knf", true},
+		{"openldap_knf-", "openldap_knf", false},
+		{"openldap_knf", "openldap_knf", false},
+		{"openldap_ns-1.0", "openldap_knf", false},
+		{"openldap_knf2-1.0", "openldap_knf", false},
+		{"openldap_knf-1.0-rc1", "openldap_knf", true},
+		{"a_knf-b_knf-1.0", "a_knf", false},
+		{"a_knf-b_knf", "a_knf", false},
+	}
+	for k, c := range cases {
+		if got := IsVersionOf(c.versionedId, c.id); got != c.want {
+			t.Errorf("[%d] want: %v; got: %v", k, c.want, got)
+		}
+	}
+}
+
+func TestRenameDescriptorIdsInNsd(t *testing.T) {
+	content := `nsd:
+  nsd:
+  - id: openldap_ns
+    version: '1.0'
+    df:
+    - id: default-df
+      vnf-profile:
+      - id: openldap
+        vnfd-id: openldap_knf
+    vnfd-id:
+    - openldap_knf
+    - other_knf
+`
+	ids := map[string]string{
+		"openldap_ns":  "openldap_ns-1.0",
+		"openldap_knf": "openldap_knf-2.0",
+	}
+	renamed, ok := renameDescriptorIds([]byte(content), ids)
+	if !ok {
+		t.Fatalf("want: renamed; got: false")
+	}
+
+	descs := &Descriptors{
+		Vnfds: []string{}, Nsds: map[string][]string{},
+		Versions: map[string]string{},
+	}
+	collectDescriptors(renamed, descs)
+	want := map[string][]string{
+		"openldap_ns-1.0": {"openldap_knf-2.0", "other_knf"},
+	}
+	if !reflect.DeepEqual(want, descs.Nsds) {
+		t.Errorf("want: %v; got: %v", want, descs.Nsds)
+	}
+	if !strings.Contains(string(renamed), "vnfd-id: openldap_knf-2.0") {
+		t.Errorf("want: renamed vnf profile; got: %s", renamed)
+	}
+}
+
+func TestRenameDescriptorIdsSkipsOtherFiles(t *testing.T) {
+	ids := map[string]string{"openldap_knf": "openldap_knf-1.0"}
+	for k, content := range []string{
+		"replicaCount: 2", "{{ not yaml", "", "vnfd:\n  id: other_knf\n",
+	} {
+		if _, ok := renameDescriptorIds([]byte(content), ids); ok {
+			t.Errorf("[%d] want: not renamed; got: renamed", k)
+		}
+	}
+}
+
+func TestPackWithDescriptorIds(t *testing.T) {
+	source := writePackage(t, map[string]string{
+		"vnfd.yaml":               validVnfd,
+		"helm-charts/c/x.yaml":    "id: my_knf\n",
+		"helm-charts/c/vnfd.yaml": "vnfd:\n  id: my_knf\n",
+	})
+	ids := map[string]string{"my_knf": VersionedId("my_knf", "1.0")}
+
+	pkg, err := Pack(source, WithDescriptorIds(ids))
+	if err != nil {
+		t.Fatalf("want: package; got: %v", err)
+	}
+	defer pkg.Data.Close()
+
+	if pkg.Name != "my_knf-1.0" {
+		t.Errorf("want: my_knf-1.0; got: %s", pkg.Name)
+	}
+	files := archiveFiles(t, pkg)
+	if got := string(files["my_knf-1.0/vnfd.yaml"]); !strings.Contains(got,
+		"id: my_knf-1.0") {
+		t.Errorf("want: renamed vnfd; got: %s", got)
+	}
+	chartFile := string(files["my_knf-1.0/helm-charts/c/vnfd.yaml"])
+	if chartFile != "vnfd:\n  id: my_knf\n" {
+		t.Errorf("want: chart file as is; got: %s", chartFile)
+	}
+
+	descs, err := ReadDescriptors(source, WithDescriptorIds(ids))
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	wantVnfds := []string{"my_knf", "my_knf-1.0"} // chart file as is
+	if !reflect.DeepEqual(descs.Vnfds, wantVnfds) {
+		t.Errorf("want: %v; got: %v", wantVnfds, descs.Vnfds)
+	}
+	assertLintErrors(t, []string{}, lintErrors(t, source,
+		WithDescriptorIds(ids)))
+}
+
+func TestReadDescriptorVersions(t *testing.T) {
+	descs, err := ReadDescriptors(findTestDataDir("openldap_nested"))
+	if err != nil {
+		t.Fatalf("want: descriptors; got: %v", err)
+	}
+	want := map[string]string{"openldap_knf": "1.0", "openldap_ns": "1.0"}
+	if !reflect.DeepEqual(want, descs.Versions) {
+		t.Errorf("want: %v; got: %v", want, descs.Versions)
+	}
+}
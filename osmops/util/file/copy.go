@@ -0,0 +1,55 @@
+package file
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CopyVisitor returns a Visitor to copy each node it visits to the same
+// relative path in destDir. The Visitor recreates directories, creating
+// destDir too if needed, and copies regular files along with their
+// permission bits. It skips anything else, e.g. symlinks, and fails if
+// a file is already in destDir.
+func CopyVisitor(destDir AbsPath) Visitor {
+	return func(node TreeNode) error {
+		target := destDir.Join(node.RelPath).Value()
+		if node.FsMeta.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !node.FsMeta.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(node.NodePath.Value(), target, node.FsMeta.Mode().Perm())
+	}
+}
+
+func copyFile(source, target string, perm os.FileMode) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	dest, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// CopyTree copies sourceDir and all its contents to destDir. (See:
+// CopyVisitor) It returns the first error it runs into, if any.
+func CopyTree(sourceDir, destDir AbsPath) error {
+	if es := NewTreeScanner(sourceDir).Visit(CopyVisitor(destDir)); len(es) > 0 {
+		return es[0]
+	}
+	return nil
+}
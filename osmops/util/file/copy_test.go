@@ -0,0 +1,83 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func listFiles(t *testing.T, dir AbsPath) []string {
+	found := []string{}
+	NewTreeScanner(dir).Visit(func(node TreeNode) error {
+		if node.FsMeta.Mode().IsRegular() {
+			found = append(found, filepath.ToSlash(node.RelPath))
+		}
+		return nil
+	})
+	sort.Strings(found)
+	return found
+}
+
+func TestCopyTree(t *testing.T) {
+	source := findTestDataDir(2)
+	dest, _ := ParseAbsPath(filepath.Join(t.TempDir(), "copy"))
+	if err := CopyTree(source, dest); err != nil {
+		t.Fatalf("want: copy; got: %v", err)
+	}
+
+	want := []string{"d1/f2", "d1/f3", "d2/d3/f6", "d2/f4", "d2/f5", "f1"}
+	if got := listFiles(t, dest); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+	for _, f := range want {
+		wantContent, _ := os.ReadFile(source.Join(f).Value())
+		gotContent, _ := os.ReadFile(dest.Join(f).Value())
+		if !reflect.DeepEqual(wantContent, gotContent) {
+			t.Errorf("[%s] want: %s; got: %s", f, wantContent, gotContent)
+		}
+	}
+}
+
+func TestCopyTreeKeepsPermissions(t *testing.T) {
+	source, _ := ParseAbsPath(t.TempDir())
+	os.WriteFile(source.Join("run.sh").Value(), []byte("#!/bin/sh"), 0750)
+	dest, _ := ParseAbsPath(filepath.Join(t.TempDir(), "copy"))
+	if err := CopyTree(source, dest); err != nil {
+		t.Fatalf("want: copy; got: %v", err)
+	}
+	info, err := os.Stat(dest.Join("run.sh").Value())
+	if err != nil {
+		t.Fatalf("want: run.sh; got: %v", err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("want: 0750; got: %o", info.Mode().Perm())
+	}
+}
+
+func TestCopyTreeWontOverwrite(t *testing.T) {
+	source := findTestDataDir(2)
+	dest, _ := ParseAbsPath(t.TempDir())
+	os.WriteFile(dest.Join("f1").Value(), []byte("mine"), 0644)
+	if err := CopyTree(source, dest); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	if content, _ := os.ReadFile(dest.Join("f1").Value()); string(content) != "mine" {
+		t.Errorf("want: mine; got: %s", content)
+	}
+}
+
+func TestCopyVisitorWithFilter(t *testing.T) {
+	source := findTestDataDir(2)
+	rules := parseRules(t, source.Value(), "d2/")
+	dest, _ := ParseAbsPath(t.TempDir())
+	es := NewTreeScanner(source).Visit(IgnoreList{rules}.Filter(CopyVisitor(dest)))
+	if len(es) > 0 {
+		t.Fatalf("want: copy; got: %v", es)
+	}
+	want := []string{"d1/f2", "d1/f3", "f1"}
+	if got := listFiles(t, dest); !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
@@ -0,0 +1,247 @@
+package file
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreRules holds the patterns of an ignore file using gitignore syntax.
+// Patterns are relative to the directory containing the ignore file, the
+// rules' base directory. We support the whole gitignore pattern syntax:
+// blank lines and "#" comments, "!" negation, "/" anchoring, trailing "/"
+// for directories only, "*", "?", "[...]" and "**" wildcards, and "\" to
+// escape special characters. See:
+// - https://git-scm.com/docs/gitignore#_pattern_format
+type IgnoreRules struct {
+	base  AbsPath
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ParseIgnoreRules reads gitignore patterns from the given content. The
+// patterns are relative to the given base directory.
+func ParseIgnoreRules(base AbsPath, content io.Reader) (*IgnoreRules, error) {
+	rules := &IgnoreRules{base: base}
+	lines := bufio.NewScanner(content)
+	for lines.Scan() {
+		if rule, ok := parseIgnoreRule(lines.Text()); ok {
+			rules.rules = append(rules.rules, rule)
+		}
+	}
+	return rules, lines.Err()
+}
+
+// LoadIgnoreFile reads the gitignore patterns in the given file. The file's
+// directory is the rules' base directory. If the file doesn't exist, you
+// get empty rules that don't ignore anything.
+func LoadIgnoreFile(ignoreFile AbsPath) (*IgnoreRules, error) {
+	base := AbsPath{data: filepath.Dir(ignoreFile.Value())}
+	fd, err := os.Open(ignoreFile.Value())
+	if errors.Is(err, fs.ErrNotExist) {
+		return &IgnoreRules{base: base}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return ParseIgnoreRules(base, fd)
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+	line = strings.TrimSuffix(line, "\r")
+	line = trimUnescapedTrailingSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, `\/`) {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+
+	anchored := strings.Contains(line, "/") // (*)
+	line = strings.TrimPrefix(line, "/")
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	pattern, err := regexp.Compile("^" + expr + "$")
+	if err != nil { // (**)
+		return rule, false
+	}
+	rule.pattern = pattern
+	return rule, true
+
+	// (*) a slash at the beginning or in the middle makes the pattern
+	// relative to the base dir; otherwise it matches at any level.
+	// (**) git silently skips patterns it can't make sense of, so do we.
+}
+
+func trimUnescapedTrailingSpace(line string) string {
+	trimmed := strings.TrimRight(line, " ")
+	if strings.HasSuffix(trimmed, `\`) && len(trimmed) < len(line) {
+		return trimmed + " "
+	}
+	return trimmed
+}
+
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/") &&
+			(i == 0 || glob[i-1] == '/'):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case c == '*' && glob[i:] == "**" && (i == 0 || glob[i-1] == '/'):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end >= 0 {
+				class := glob[i+1 : i+1+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+				i += end + 1
+			} else {
+				expr.WriteString(`\[`)
+			}
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
+
+// match tells if the rules exclude (1), re-include (-1) or say nothing
+// about (0) the given path, relative to the base dir. The last matching
+// rule wins, like in git.
+func (r *IgnoreRules) match(relPath string, isDir bool) int {
+	verdict := 0
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(relPath) {
+			if rule.negate {
+				verdict = -1
+			} else {
+				verdict = 1
+			}
+		}
+	}
+	return verdict
+}
+
+func (r *IgnoreRules) relPath(target AbsPath) (string, bool) {
+	rel, err := filepath.Rel(r.base.Value(), target.Value())
+	if err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// IgnoreList stacks the rules of several ignore files, e.g. one in a
+// directory and another in one of its sub-directories. Like in git, the
+// rules of ignore files further down the tree take precedence, so the
+// list goes from the outermost to the innermost ignore file.
+type IgnoreList []*IgnoreRules
+
+// Ignores tells if the given path should be ignored. That's the case if
+// the last matching rule excludes it or if one of its parent directories
+// is excluded---like in git, you can't re-include a file if its parent
+// directory is excluded. Rules only apply to paths within their base dir.
+func (l IgnoreList) Ignores(target AbsPath, isDir bool) bool {
+	for _, parent := range l.parentDirs(target) {
+		if l.excludes(parent, true) {
+			return true
+		}
+	}
+	return l.excludes(target, isDir)
+}
+
+func (l IgnoreList) excludes(target AbsPath, isDir bool) bool {
+	excluded := false
+	for _, rules := range l {
+		if rules == nil {
+			continue
+		}
+		if rel, ok := rules.relPath(target); ok {
+			switch rules.match(rel, isDir) {
+			case 1:
+				excluded = true
+			case -1:
+				excluded = false
+			}
+		}
+	}
+	return excluded
+}
+
+func (l IgnoreList) parentDirs(target AbsPath) []AbsPath {
+	parents := []AbsPath{}
+	for dir := filepath.Dir(target.Value()); ; dir = filepath.Dir(dir) {
+		p := AbsPath{data: dir}
+		if !l.contains(p) {
+			break
+		}
+		parents = append([]AbsPath{p}, parents...)
+	}
+	return parents
+}
+
+func (l IgnoreList) contains(target AbsPath) bool {
+	for _, rules := range l {
+		if rules == nil {
+			continue
+		}
+		if _, ok := rules.relPath(target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter wraps the given Visitor so it only gets called on the nodes
+// the list doesn't ignore. Filter skips ignored directories altogether,
+// without visiting any of their contents.
+func (l IgnoreList) Filter(visit Visitor) Visitor {
+	return func(node TreeNode) error {
+		isDir := node.FsMeta != nil && node.FsMeta.IsDir()
+		if node.RelPath != "" && l.Ignores(node.NodePath, isDir) {
+			if isDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return visit(node)
+	}
+}
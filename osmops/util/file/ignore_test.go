@@ -0,0 +1,170 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func parseRules(t *testing.T, base string, lines ...string) *IgnoreRules {
+	baseDir, _ := ParseAbsPath(base)
+	content := strings.NewReader(strings.Join(lines, "\n"))
+	rules, err := ParseIgnoreRules(baseDir, content)
+	if err != nil {
+		t.Fatalf("want: rules; got: %v", err)
+	}
+	return rules
+}
+
+func absPath(p string) AbsPath {
+	return AbsPath{data: p}
+}
+
+var ignoreFixtures = []struct {
+	patterns []string
+	target   string
+	isDir    bool
+	want     bool
+}{
+	{[]string{}, "/b/x.txt", false, false},
+	{[]string{"# x.txt", ""}, "/b/x.txt", false, false},
+	{[]string{"x.txt"}, "/b/x.txt", false, true},
+	{[]string{"x.txt"}, "/b/d/x.txt", false, true},
+	{[]string{"/x.txt"}, "/b/d/x.txt", false, false},
+	{[]string{"/x.txt"}, "/b/x.txt", false, true},
+	{[]string{"d/x.txt"}, "/b/d/x.txt", false, true},
+	{[]string{"d/x.txt"}, "/b/e/d/x.txt", false, false},
+	{[]string{"*.md"}, "/b/d/README.md", false, true},
+	{[]string{"*.md"}, "/b/d/README.mdx", false, false},
+	{[]string{"?.md"}, "/b/a.md", false, true},
+	{[]string{"?.md"}, "/b/ab.md", false, false},
+	{[]string{"[ab].md"}, "/b/b.md", false, true},
+	{[]string{"[!ab].md"}, "/b/b.md", false, false},
+	{[]string{"**/x.txt"}, "/b/d/e/x.txt", false, true},
+	{[]string{"**/x.txt"}, "/b/x.txt", false, true},
+	{[]string{"d/**"}, "/b/d/e/x.txt", false, true},
+	{[]string{"d/**/x.txt"}, "/b/d/e/f/x.txt", false, true},
+	{[]string{"d/**/x.txt"}, "/b/d/x.txt", false, true},
+	{[]string{"d/"}, "/b/d", true, true},
+	{[]string{"d/"}, "/b/d", false, false},
+	{[]string{"d/"}, "/b/d/x.txt", false, true},
+	{[]string{"d"}, "/b/d/x.txt", false, true},
+	{[]string{"*.txt", "!x.txt"}, "/b/x.txt", false, false},
+	{[]string{"!x.txt", "*.txt"}, "/b/x.txt", false, true},
+	{[]string{"d/", "!d/x.txt"}, "/b/d/x.txt", false, true},
+	{[]string{`\#x.txt`}, "/b/#x.txt", false, true},
+	{[]string{`\!x.txt`}, "/b/!x.txt", false, true},
+	{[]string{"x.txt  "}, "/b/x.txt", false, true},
+	{[]string{"x.txt"}, "/c/x.txt", false, false},
+	{[]string{"*"}, "/b", true, false},
+}
+
+func TestIgnores(t *testing.T) {
+	for k, d := range ignoreFixtures {
+		rules := parseRules(t, "/b", d.patterns...)
+		got := IgnoreList{rules}.Ignores(absPath(d.target), d.isDir)
+		if got != d.want {
+			t.Errorf("[%d] want: %v; got: %v", k, d.want, got)
+		}
+	}
+}
+
+func TestInnerIgnoreRulesTakePrecedence(t *testing.T) {
+	outer := parseRules(t, "/b", "*.txt")
+	inner := parseRules(t, "/b/d", "!x.txt")
+	list := IgnoreList{outer, inner}
+
+	if list.Ignores(absPath("/b/d/x.txt"), false) {
+		t.Errorf("want: inner rules re-include /b/d/x.txt; got: ignored")
+	}
+	if !list.Ignores(absPath("/b/x.txt"), false) {
+		t.Errorf("want: /b/x.txt ignored; got: not ignored")
+	}
+	if !list.Ignores(absPath("/b/d/y.txt"), false) {
+		t.Errorf("want: /b/d/y.txt ignored; got: not ignored")
+	}
+}
+
+func TestIgnoreListSkipsNilRules(t *testing.T) {
+	list := IgnoreList{nil, parseRules(t, "/b", "x.txt"), nil}
+	if !list.Ignores(absPath("/b/x.txt"), false) {
+		t.Errorf("want: /b/x.txt ignored; got: not ignored")
+	}
+}
+
+func TestLoadMissingIgnoreFile(t *testing.T) {
+	dir, _ := ParseAbsPath(t.TempDir())
+	rules, err := LoadIgnoreFile(dir.Join(".ignore"))
+	if err != nil {
+		t.Fatalf("want: empty rules; got: %v", err)
+	}
+	if IgnoreList([]*IgnoreRules{rules}).Ignores(dir.Join("x"), false) {
+		t.Errorf("want: nothing ignored; got: x ignored")
+	}
+}
+
+func TestLoadIgnoreFile(t *testing.T) {
+	dir, _ := ParseAbsPath(t.TempDir())
+	ignoreFile := dir.Join(".ignore")
+	os.WriteFile(ignoreFile.Value(), []byte("# comment\r\n*.md\r\n"), 0644)
+
+	rules, err := LoadIgnoreFile(ignoreFile)
+	if err != nil {
+		t.Fatalf("want: rules; got: %v", err)
+	}
+	list := IgnoreList{rules}
+	if !list.Ignores(dir.Join("README.md"), false) {
+		t.Errorf("want: README.md ignored; got: not ignored")
+	}
+	if list.Ignores(dir.Join("comment"), false) {
+		t.Errorf("want: comment not ignored; got: ignored")
+	}
+}
+
+func TestLoadIgnoreFileError(t *testing.T) {
+	dir, _ := ParseAbsPath(t.TempDir())
+	if _, err := LoadIgnoreFile(dir); err == nil {
+		t.Errorf("want: read error; got: nil")
+	}
+}
+
+func TestFilterSkipsIgnoredNodes(t *testing.T) {
+	targetDir := findTestDataDir(2)
+	rules := parseRules(t, targetDir.Value(), "d1/", "f5")
+	got := []string{}
+	scanner := NewTreeScanner(targetDir)
+	es := scanner.Visit(IgnoreList{rules}.Filter(func(node TreeNode) error {
+		got = append(got, filepath.ToSlash(node.RelPath))
+		return nil
+	}))
+	if len(es) > 0 {
+		t.Fatalf("want: no errors; got: %v", es)
+	}
+
+	want := []string{"", "d2", "d2/d3", "d2/d3/f6", "d2/f4", "f1"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
+
+func TestFilterWithNoRulesVisitsAll(t *testing.T) {
+	targetDir := findTestDataDir(2)
+	collect := func(visitor func(Visitor) Visitor) []string {
+		paths := []string{}
+		NewTreeScanner(targetDir).Visit(visitor(func(node TreeNode) error {
+			paths = append(paths, node.RelPath)
+			return nil
+		}))
+		sort.Strings(paths)
+		return paths
+	}
+	want := collect(func(v Visitor) Visitor { return v })
+	got := collect(IgnoreList{}.Filter)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %v; got: %v", want, got)
+	}
+}
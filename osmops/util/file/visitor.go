@@ -26,7 +26,8 @@ type TreeNode struct {
 }
 
 // Visitor is a function the TreeScanner calls on traversing each node
-// in a given directory tree.
+// in a given directory tree. If the node is a directory, the Visitor can
+// return filepath.SkipDir to make the TreeScanner skip its contents.
 type Visitor func(TreeNode) error
 
 // TreeScanner traverses a directory tree calling a visitor on each node.
@@ -92,6 +93,9 @@ func (s *scanner) visitAllAndCollectErrors(
 			FsMeta:   info,
 		}
 		if err := visit(node); err != nil {
+			if err == filepath.SkipDir && info.IsDir() {
+				return err
+			}
 			*acc = appendVisitError(path, err, *acc)
 		}
 		return nil
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error a CircuitBreaker returns, without sending the
+// request, while the circuit is open.
+var ErrCircuitOpen = errors.New("circuit breaker open: server unavailable")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker stops sending requests to a server that keeps on failing,
+// so we don't pile up requests that are bound to fail---e.g. while OSM is
+// being upgraded. A request fails if there's a network error or the server
+// replies with a 5xx.
+//
+// The circuit starts closed: requests go through. After a number of failures
+// in a row, the circuit opens: requests fail straight away with ErrCircuitOpen.
+// Once the open timeout has elapsed, the circuit goes half-open: it lets one
+// request through to probe the server. If that request succeeds, the circuit
+// closes again, otherwise it opens for another timeout period.
+//
+// CircuitBreaker is safe for concurrent use, so you can share it among all
+// the ReqSenders talking to the same server.
+type CircuitBreaker struct {
+	mutex            sync.Mutex
+	failureThreshold int
+	openTimeout      time.Duration
+	state            circuitState
+	failures         int
+	openedAt         time.Time
+	probing          bool
+	now              func() time.Time
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker that opens after the
+// given number of consecutive failures and stays open for openTimeout.
+// A failureThreshold less than 1 is the same as 1.
+func NewCircuitBreaker(failureThreshold int,
+	openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		now:              time.Now,
+	}
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures += 1
+	if b.state == circuitHalfOpen || b.failures >= b.failureThreshold {
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Wrap decorates the given ReqSender so that requests go through the
+// circuit breaker. If you also use Retry, put the breaker inside, i.e.
+// Retry(breaker.Wrap(send), policy), so each attempt counts.
+func (b *CircuitBreaker) Wrap(send ReqSender) ReqSender {
+	return func(req *http.Request) (*http.Response, error) {
+		if send == nil {
+			return nil, errors.New("nil ReqSender")
+		}
+		if !b.allow() {
+			return nil, ErrCircuitOpen
+		}
+
+		res, err := send(req)
+		b.record(err != nil || res == nil ||
+			res.StatusCode >= http.StatusInternalServerError)
+		return res, err
+	}
+}
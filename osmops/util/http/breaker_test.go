@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) time() time.Time { return c.now }
+
+func newTestBreaker(threshold int) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	breaker := NewCircuitBreaker(threshold, time.Minute)
+	breaker.now = clock.time
+	return breaker, clock
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	breaker, _ := newTestBreaker(2)
+	server := &flakyServer{replies: []int{503, 0, 200}}
+	send := breaker.Wrap(server.send)
+
+	send(&http.Request{})
+	send(&http.Request{})
+	if _, err := send(&http.Request{}); err != ErrCircuitOpen {
+		t.Errorf("want: circuit open; got: %v", err)
+	}
+	if len(server.received) != 2 {
+		t.Errorf("want: 2 requests; got: %d", len(server.received))
+	}
+}
+
+func TestBreakerResetsCountOnSuccess(t *testing.T) {
+	breaker, _ := newTestBreaker(2)
+	server := &flakyServer{replies: []int{503, 200, 503, 200}}
+	send := breaker.Wrap(server.send)
+
+	for k := 0; k < 4; k++ {
+		if _, err := send(&http.Request{}); err == ErrCircuitOpen {
+			t.Errorf("[%d] want: request sent; got: circuit open", k)
+		}
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	breaker, clock := newTestBreaker(1)
+	server := &flakyServer{replies: []int{500, 500, 200, 200}}
+	send := breaker.Wrap(server.send)
+
+	send(&http.Request{})
+	if _, err := send(&http.Request{}); err != ErrCircuitOpen {
+		t.Fatalf("want: circuit open; got: %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if res, _ := send(&http.Request{}); res == nil || res.StatusCode != 500 {
+		t.Fatalf("want: failed probe; got: %v", res)
+	}
+	if _, err := send(&http.Request{}); err != ErrCircuitOpen {
+		t.Fatalf("want: circuit open again; got: %v", err)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if res, _ := send(&http.Request{}); res == nil || res.StatusCode != 200 {
+		t.Fatalf("want: successful probe; got: %v", res)
+	}
+	if res, _ := send(&http.Request{}); res == nil || res.StatusCode != 200 {
+		t.Errorf("want: circuit closed; got: %v", res)
+	}
+}
+
+func TestRetryDoesNotRetryOpenCircuit(t *testing.T) {
+	waits := recordSleeps(t)
+	breaker, _ := newTestBreaker(1)
+	server := &flakyServer{replies: []int{503}}
+	send := Retry(breaker.Wrap(server.send), DefaultRetryPolicy())
+
+	if _, err := Request(GET).RunWith(context.TODO(), send); err != ErrCircuitOpen {
+		t.Errorf("want: circuit open; got: %v", err)
+	}
+	if len(server.received) != 1 || len(*waits) != 1 {
+		t.Errorf("want: 1 request, 1 wait; got: %d, %d",
+			len(server.received), len(*waits))
+	}
+}
@@ -31,6 +31,11 @@ var PUT = func(request *http.Request) error {
 	return nil
 }
 
+var DELETE = func(request *http.Request) error {
+	request.Method = "DELETE"
+	return nil
+}
+
 func At(url *url.URL) ReqBuilder {
 	return func(request *http.Request) error {
 		if url == nil {
@@ -44,13 +49,14 @@ func At(url *url.URL) ReqBuilder {
 
 var MediaType = struct {
 	u.StrEnum
-	JSON, YAML, GZIP u.EnumIx
+	JSON, YAML, GZIP, ZIP u.EnumIx
 }{
 	StrEnum: u.NewStrEnum("application/json", "application/yaml",
-		"application/gzip"),
+		"application/gzip", "application/zip"),
 	JSON: 0,
 	YAML: 1,
 	GZIP: 2,
+	ZIP:  3,
 }
 
 func Content(mediaType u.EnumIx) ReqBuilder {
@@ -121,6 +127,28 @@ func Body(content []byte) ReqBuilder {
 	}
 }
 
+// StreamBody builds a ReqBuilder to set the request body to the stream the
+// given open function returns. The stream must yield exactly size bytes.
+// StreamBody calls open to get the initial body and then sets it as the
+// request's GetBody function, so the request can be sent more than once
+// without buffering the whole body in memory. Each call to open should
+// return a fresh stream reading the content from the start.
+func StreamBody(size int64, open func() (io.ReadCloser, error)) ReqBuilder {
+	return func(request *http.Request) error {
+		if open == nil {
+			return errors.New("nil body stream opener")
+		}
+		body, err := open()
+		if err != nil {
+			return err
+		}
+		request.ContentLength = size
+		request.Body = body
+		request.GetBody = open
+		return nil
+	}
+}
+
 func JsonBody(content interface{}) ReqBuilder {
 	return func(request *http.Request) error {
 		var json = jsoniter.ConfigCompatibleWithStandardLibrary // (*)
@@ -148,10 +176,6 @@ func JsonBody(content interface{}) ReqBuilder {
 	// - https://stackoverflow.com/questions/35377477
 }
 
-// TODO also implement streaming body? most of the standard libs aren't built
-// w/ streaming in mind, so in practice you'll likely have the whole body in
-// memory most of the time for common cases---e.g. JSON, YAML.
-
 // TODO nil pointer checks. Mostly not implemented!! Catch all occurrences
 // of slices, i/f, function args and return an error if nil gets passed in.
 // Then write test cases for each. What a schlep!
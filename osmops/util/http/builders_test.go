@@ -2,9 +2,12 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"gopkg.in/yaml.v2"
@@ -15,7 +18,7 @@ import (
 func TestSimpleGetRequest(t *testing.T) {
 	hp, _ := u.ParseHostAndPort("x:80")
 	url, _ := hp.Http("/a/b")
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		GET, At(url),
 	)
 
@@ -43,7 +46,7 @@ func TestSimplePostRequest(t *testing.T) {
 	hp, _ := u.ParseHostAndPort("x:80")
 	url, _ := hp.Http("/a/b")
 	content := []byte("42")
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		POST, At(url),
 		Body(content),
 	)
@@ -93,11 +96,27 @@ func TestSimplePostRequest(t *testing.T) {
 	}
 }
 
+func TestSimpleDeleteRequest(t *testing.T) {
+	hp, _ := u.ParseHostAndPort("x:80")
+	url, _ := hp.Http("/a/b")
+	req, err := BuildRequest(context.TODO(), DELETE, At(url))
+
+	if err != nil {
+		t.Fatalf("want request, but got error: %v", err)
+	}
+	if wantMethod := "DELETE"; req.Method != wantMethod {
+		t.Errorf("want: %s; got: %s", wantMethod, req.Method)
+	}
+	if req.URL.Path != "/a/b" {
+		t.Errorf("want: /a/b; got: %s", req.URL.Path)
+	}
+}
+
 func TestSimplePutRequest(t *testing.T) {
 	hp, _ := u.ParseHostAndPort("x:80")
 	url, _ := hp.Http("/a/b")
 	content := []byte("42")
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		PUT, At(url),
 		Body(content),
 	)
@@ -127,7 +146,7 @@ func TestSimplePutRequest(t *testing.T) {
 
 func TestEmptyBody(t *testing.T) {
 	content := []byte("")
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		Body(content),
 	)
 
@@ -161,8 +180,47 @@ func TestEmptyBody(t *testing.T) {
 	}
 }
 
+func TestStreamBody(t *testing.T) {
+	opened := 0
+	open := func() (io.ReadCloser, error) {
+		opened++
+		return ioutil.NopCloser(strings.NewReader("wada")), nil
+	}
+	req, err := BuildRequest(context.TODO(), POST, StreamBody(4, open))
+	if err != nil {
+		t.Fatalf("want: request; got: %v", err)
+	}
+	if req.ContentLength != 4 {
+		t.Errorf("want: 4; got: %d", req.ContentLength)
+	}
+	for k := 0; k < 2; k++ {
+		body := req.Body
+		if k > 0 {
+			body, _ = req.GetBody()
+		}
+		if got, _ := ioutil.ReadAll(body); string(got) != "wada" {
+			t.Errorf("[%d] want: wada; got: %s", k, got)
+		}
+	}
+	if opened != 2 {
+		t.Errorf("want: 2 streams; got: %d", opened)
+	}
+}
+
+func TestStreamBodyOpenError(t *testing.T) {
+	open := func() (io.ReadCloser, error) {
+		return nil, errors.New("gone")
+	}
+	if _, err := BuildRequest(context.TODO(), StreamBody(1, open)); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	if _, err := BuildRequest(context.TODO(), StreamBody(1, nil)); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
 func TestJsonBodyNilContent(t *testing.T) {
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		JsonBody(nil),
 	)
 	if err != nil {
@@ -180,7 +238,7 @@ func TestJsonBodyNilContent(t *testing.T) {
 
 func TestJsonBodyNonNilContent(t *testing.T) {
 	content := "yo!"
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		JsonBody(content),
 	)
 	if err != nil {
@@ -209,7 +267,7 @@ func TestJsonBodyMarshalUnknownType(t *testing.T) {
 		t.Fatalf("unmarshal: %v", err)
 	}
 
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		JsonBody(content),
 	)
 	if err != nil {
@@ -227,7 +285,7 @@ func TestJsonBodyMarshalUnknownType(t *testing.T) {
 
 func TestJsonBodyMarshalError(t *testing.T) {
 	notSerializable := func() {}
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		JsonBody(notSerializable),
 	)
 	if err == nil {
@@ -249,7 +307,7 @@ var acceptHeaderFixtures = []struct {
 
 func TestAcceptHeader(t *testing.T) {
 	for k, d := range acceptHeaderFixtures {
-		req, err := BuildRequest(
+		req, err := BuildRequest(context.TODO(),
 			Accept(d.in...),
 		)
 		if err != nil {
@@ -284,11 +342,15 @@ var contentTypeHeaderFixtures = []struct {
 		in:   MediaType.GZIP,
 		want: "Content-Type: application/gzip\r\n",
 	},
+	{
+		in:   MediaType.ZIP,
+		want: "Content-Type: application/zip\r\n",
+	},
 }
 
 func TestContentTypeHeader(t *testing.T) {
 	for k, d := range contentTypeHeaderFixtures {
-		req, err := BuildRequest(
+		req, err := BuildRequest(context.TODO(),
 			Content(d.in),
 		)
 		if err != nil {
@@ -309,7 +371,7 @@ func TestContentTypeHeader(t *testing.T) {
 
 func TestBearerTokenHeader(t *testing.T) {
 	tokenProvider := func() (string, error) { return "token", nil }
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		BearerToken(tokenProvider),
 	)
 
@@ -332,7 +394,7 @@ func TestBearerTokenHeader(t *testing.T) {
 
 func TestBearerTokenHeaderFail(t *testing.T) {
 	tokenProvider := func() (string, error) { return "", errors.New("ouch!") }
-	req, err := BuildRequest(
+	req, err := BuildRequest(context.TODO(),
 		BearerToken(tokenProvider),
 	)
 
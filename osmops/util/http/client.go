@@ -8,7 +8,7 @@
 // functions. Request building reads like an HTTP request on the wire and is
 // more type-safe than doing it the Go way. Example:
 //
-//     req, err := BuildRequest(
+//     req, err := BuildRequest(ctx,
 //         POST, At(url),
 //         Content(MediaType.JSON),
 //         Body(content),
@@ -43,7 +43,7 @@
 //         Body(content),
 //     ).
 //     SetHandler(ExpectSuccess(), ReadJsonResponse(&responseData)).
-//     RunWith(client.Do)
+//     RunWith(ctx, client.Do)
 //
 // Notice RunWith takes a ReqSender so you can easily unit-test your code by
 // swapping out an actual HTTP call with a stub. Example:
@@ -51,11 +51,23 @@
 //     send := func(req *http.Request) (*http.Response, error) {
 //         return &http.Response{StatusCode: 200}, nil
 //     }
-//     Request(...).RunWith(send)
+//     Request(...).RunWith(ctx, send)
+//
+// Sender decorators
+//
+// Since a ReqSender is just a function, you can wrap it to add behaviour
+// to every exchange. ReAuthenticate logs in again when the server rejects
+// a token, Retry resends requests that failed because of transient errors
+// and CircuitBreaker stops calling a server that keeps on failing. Example:
+//
+//     breaker := NewCircuitBreaker(5, time.Minute)
+//     send := Retry(breaker.Wrap(client.Do), DefaultRetryPolicy())
+//     Request(...).RunWith(ctx, send)
 //
 package http
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -68,19 +80,25 @@ import (
 // a poor man's monomorphic either+IO monad stack---ask Google.
 type ReqBuilder func(request *http.Request) error
 
-func emptyRequest() *http.Request {
+func emptyRequest(ctx context.Context) *http.Request {
 	bare := &http.Request{}
-	withCtx := bare.WithContext(bare.Context())
+	withCtx := bare.WithContext(ctx)
 	withCtx.Header = make(http.Header)
 	return withCtx
 }
 
-// BuildRequest runs the given builders to assemble an HTTP request.
+// BuildRequest runs the given builders to assemble an HTTP request bound
+// to the given context. The context governs the whole exchange: if it gets
+// cancelled or its deadline expires, the HTTP client aborts the request.
 // If all the builders run successfully, then the returned request is okay.
 // Otherwise BuildRequest stops as soon as a builder errors out, returning
 // that error.
-func BuildRequest(builders ...ReqBuilder) (*http.Request, error) {
-	request := emptyRequest()
+func BuildRequest(ctx context.Context, builders ...ReqBuilder) (
+	*http.Request, error) {
+	if ctx == nil {
+		return nil, errors.New("nil context")
+	}
+	request := emptyRequest(ctx)
 	for _, build := range builders {
 		if err := build(request); err != nil {
 			return request, err
@@ -150,7 +168,8 @@ type ReqSender func(*http.Request) (*http.Response, error)
 
 // RunWith performs the HTTP message Exchange by building the request,
 // invoking the given send function with it, and finally processing the
-// response.
+// response. The request is bound to the given context, so you can use it
+// to cancel the Exchange or set a deadline for it.
 //
 // The request gets built by calling the ReqBuilder functions passed to
 // the Request factory function. If there's a request build error, then
@@ -170,12 +189,13 @@ type ReqSender func(*http.Request) (*http.Response, error)
 // returned with a nil error. Otherwise the response gets returned with
 // the error output by the first failed handler---RunWith won't call any
 // handlers following the failed one.
-func (e *Exchange) RunWith(send ReqSender) (*http.Response, error) {
+func (e *Exchange) RunWith(ctx context.Context, send ReqSender) (
+	*http.Response, error) {
 	if send == nil {
 		return nil, errors.New("nil ReqSender")
 	}
 
-	req, err := BuildRequest(e.builders...)
+	req, err := BuildRequest(ctx, e.builders...)
 	if err != nil {
 		return nil, err
 	}
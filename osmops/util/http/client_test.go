@@ -2,13 +2,14 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"net/http"
 	"testing"
 )
 
 func TestExchangeRequestBuilderFailure(t *testing.T) {
-	res, err := Request(GET, At(nil)).RunWith(http.DefaultClient.Do)
+	res, err := Request(GET, At(nil)).RunWith(context.TODO(), http.DefaultClient.Do)
 	if res != nil {
 		t.Errorf("want nil response; got: %v", res)
 	}
@@ -21,7 +22,7 @@ func TestExchangeStopOnSendFailure(t *testing.T) {
 	send := func(req *http.Request) (*http.Response, error) {
 		return &http.Response{}, errors.New("ouch!")
 	}
-	res, err := Request(GET).RunWith(send)
+	res, err := Request(GET).RunWith(context.TODO(), send)
 	if res == nil {
 		t.Errorf("want empty response; got: nil")
 	}
@@ -34,7 +35,7 @@ func TestExchangeErrorsOnNilResponse(t *testing.T) {
 	send := func(req *http.Request) (*http.Response, error) {
 		return nil, nil
 	}
-	res, err := Request(GET).RunWith(send)
+	res, err := Request(GET).RunWith(context.TODO(), send)
 	if res != nil {
 		t.Errorf("want nil response; got: %v", res)
 	}
@@ -47,7 +48,7 @@ func TestExchangeNoHandleIfNoHandlers(t *testing.T) {
 	send := func(req *http.Request) (*http.Response, error) {
 		return &http.Response{}, nil
 	}
-	res, err := Request(GET).RunWith(send)
+	res, err := Request(GET).RunWith(context.TODO(), send)
 	if res == nil {
 		t.Errorf("want empty response; got: nil")
 	}
@@ -70,7 +71,7 @@ func TestExchangeCloseBody(t *testing.T) {
 	send := func(req *http.Request) (*http.Response, error) {
 		return &http.Response{Body: &EmptyBody{}}, nil
 	}
-	res, err := Request(GET).RunWith(send)
+	res, err := Request(GET).RunWith(context.TODO(), send)
 	if res == nil {
 		t.Errorf("want response; got: nil")
 	}
@@ -107,7 +108,7 @@ func TestExchangeHandleResponseSuccessfully(t *testing.T) {
 	statusCodeGrabber := &GrabStatusCode{}
 	res, err := Request(GET).
 		SetHandler(statusCodeGrabber).
-		RunWith(send)
+		RunWith(context.TODO(), send)
 
 	if res == nil {
 		t.Errorf("want response; got: nil")
@@ -127,7 +128,7 @@ func TestExchangeHandleResponseFailure(t *testing.T) {
 	statusCodeGrabber := &GrabStatusCode{}
 	res, err := Request(GET).
 		SetHandler(&FailingHandler{}, statusCodeGrabber).
-		RunWith(send)
+		RunWith(context.TODO(), send)
 
 	if res == nil {
 		t.Errorf("want response; got: nil")
@@ -141,7 +142,7 @@ func TestExchangeHandleResponseFailure(t *testing.T) {
 }
 
 func TestRunWithNilReqSender(t *testing.T) {
-	if _, err := Request().RunWith(nil); err == nil {
+	if _, err := Request().RunWith(context.TODO(), nil); err == nil {
 		t.Errorf("want error; got: nil")
 	}
 }
@@ -157,3 +158,27 @@ func TestHandleResponseErrorOnNilHandler(t *testing.T) {
 		t.Errorf("want: error; got: nil")
 	}
 }
+
+type ctxKey struct{}
+
+func TestRunWithBindsRequestToContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+	var got context.Context
+	send := func(req *http.Request) (*http.Response, error) {
+		got = req.Context()
+		return &http.Response{StatusCode: 200}, nil
+	}
+	if _, err := Request(GET).RunWith(ctx, send); err != nil {
+		t.Fatalf("want: response; got: %v", err)
+	}
+	if got == nil || got.Value(ctxKey{}) != "v" {
+		t.Errorf("want: request bound to ctx; got: %v", got)
+	}
+}
+
+func TestBuildRequestErrorOnNilContext(t *testing.T) {
+	//lint:ignore SA1012 testing nil context on purpose
+	if _, err := BuildRequest(nil, GET); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
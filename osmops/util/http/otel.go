@@ -0,0 +1,55 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/fluxcd/source-watcher/osmops/util/tracing"
+)
+
+// SpanAttributes extracts, from the given request, any attributes to add
+// to the request span on top of the standard HTTP ones.
+type SpanAttributes func(req *http.Request) []attribute.KeyValue
+
+// Instrument wraps the given ReqSender to record an OpenTelemetry client
+// span for each request it sends. The span is a child of the span in the
+// request context, if any, and Instrument propagates it to the server
+// through the headers of the global propagator---e.g. W3C traceparent.
+// The span gets the request method and URL, the response status code and
+// any error send returns, plus whatever attributes the given functions
+// extract from the request.
+func Instrument(send ReqSender, attrs ...SpanAttributes) ReqSender {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx, span := tracing.Tracer().Start(req.Context(),
+			fmt.Sprintf("HTTP %s", req.Method),
+			trace.WithSpanKind(trace.SpanKindClient))
+
+		span.SetAttributes(semconv.HTTPMethodKey.String(req.Method))
+		if req.URL != nil {
+			span.SetAttributes(semconv.HTTPURLKey.String(req.URL.Redacted()))
+		}
+		for _, extract := range attrs {
+			span.SetAttributes(extract(req)...)
+		}
+
+		traced := req.WithContext(ctx)
+		traced.Header = req.Header.Clone()
+		otel.GetTextMapPropagator().Inject(ctx,
+			propagation.HeaderCarrier(traced.Header))
+
+		res, err := send(traced)
+		if res != nil {
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(
+				res.StatusCode)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(
+				res.StatusCode))
+		}
+		return res, tracing.End(span, err)
+	}
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(
+		sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+func buildTestRequest(t *testing.T) *http.Request {
+	target, _ := url.Parse("http://osm/nsd/v1/ns_descriptors")
+	req, err := BuildRequest(context.TODO(), GET, At(target))
+	if err != nil {
+		t.Fatalf("want: request; got: %v", err)
+	}
+	return req
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, a := range attrs {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInstrumentRecordsSpanAndInjectsHeaders(t *testing.T) {
+	recorder := recordSpans()
+	var sent *http.Request
+	send := func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: 200}, nil
+	}
+	endpoint := attribute.Key("endpoint")
+	attrs := func(req *http.Request) []attribute.KeyValue {
+		return []attribute.KeyValue{endpoint.String(req.URL.Path)}
+	}
+
+	req := buildTestRequest(t)
+	if _, err := Instrument(send, attrs)(req); err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want: 1 span; got: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "HTTP GET" {
+		t.Errorf("want: HTTP GET; got: %s", span.Name())
+	}
+	got := span.Attributes()
+	for _, want := range []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+		attribute.String("http.url", "http://osm/nsd/v1/ns_descriptors"),
+		attribute.Int("http.status_code", 200),
+		endpoint.String("/nsd/v1/ns_descriptors"),
+	} {
+		if !hasAttribute(got, want) {
+			t.Errorf("want: %v; got: %v", want, got)
+		}
+	}
+
+	traceparent := sent.Header.Get("traceparent")
+	wantId := span.SpanContext().SpanID().String()
+	if len(traceparent) != 55 || traceparent[36:52] != wantId {
+		t.Errorf("want: traceparent w/ span %s; got: %s", wantId, traceparent)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Errorf("want: original request untouched; got: %v", req.Header)
+	}
+}
+
+func TestInstrumentRecordsSendError(t *testing.T) {
+	recorder := recordSpans()
+	send := func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}
+
+	if _, err := Instrument(send)(buildTestRequest(t)); err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want: 1 span; got: %d", len(spans))
+	}
+	if got := spans[0].Status(); got.Code != codes.Error {
+		t.Errorf("want: error status; got: %v", got)
+	}
+}
+
+func TestInstrumentFlagsServerErrors(t *testing.T) {
+	recorder := recordSpans()
+	send := func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: 503}, nil
+	}
+
+	if _, err := Instrument(send)(buildTestRequest(t)); err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+
+	if got := recorder.Ended()[0].Status(); got.Code != codes.Error {
+		t.Errorf("want: error status; got: %v", got)
+	}
+}
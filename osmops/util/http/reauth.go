@@ -0,0 +1,68 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ReAuthenticate decorates the given ReqSender to recover from revoked
+// credentials. If the server replies with a 401 to a request carrying an
+// Authorization header, the returned ReqSender calls reset to discard the
+// current credentials---e.g. clear the token store---then runs authorize
+// on a copy of the request to set fresh credentials and sends the request
+// again. It only replays the request once, so if the second attempt gets
+// a 401 too, that's the response you get back.
+//
+// To be able to replay the request, ReAuthenticate needs to read the body
+// twice. If the request has a GetBody function---e.g. the Body builder sets
+// one---ReAuthenticate uses it to get a fresh copy of the body. Otherwise
+// it buffers the whole body in memory before sending the first request.
+//
+// Example.
+//
+//     tokens := sec.NewTokenManager(...)
+//     bearer := BearerToken(...)   // get token from tokens
+//     send := ReAuthenticate(client.Do, tokens.ClearAccessToken, bearer)
+//     Request(
+//         GET, At(url),
+//         bearer,
+//     ).
+//     RunWith(ctx, send)
+//
+func ReAuthenticate(send ReqSender, reset func(),
+	authorize ReqBuilder) ReqSender {
+	return func(req *http.Request) (*http.Response, error) {
+		if send == nil {
+			return nil, errors.New("nil ReqSender")
+		}
+		if req == nil || req.Header.Get("Authorization") == "" {
+			return send(req)
+		}
+		if err := makeReplayable(req); err != nil {
+			return nil, err
+		}
+
+		res, err := send(req)
+		if err != nil || res == nil || res.StatusCode != http.StatusUnauthorized {
+			return res, err
+		}
+
+		retry, err := cloneRequest(req)
+		if err != nil {
+			return res, nil // (*)
+		}
+		discard(res)
+
+		if reset != nil {
+			reset()
+		}
+		if authorize != nil {
+			if err := authorize(retry); err != nil {
+				return nil, err
+			}
+		}
+		return send(retry)
+	}
+	// (*) can't replay. Not much we can do except for returning the 401
+	// and let the caller deal with it.
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type authServer struct {
+	validToken string
+	received   []*http.Request
+	bodies     []string
+}
+
+func (s *authServer) send(req *http.Request) (*http.Response, error) {
+	s.received = append(s.received, req)
+	body := ""
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		body = string(data)
+	}
+	s.bodies = append(s.bodies, body)
+
+	status := http.StatusOK
+	if req.Header.Get("Authorization") != "Bearer "+s.validToken {
+		status = http.StatusUnauthorized
+	}
+	return &http.Response{StatusCode: status, Body: stringReader("")}, nil
+}
+
+type tokenSource struct {
+	tokens  []string
+	resets  int
+	current int
+}
+
+func (t *tokenSource) reset() {
+	t.resets += 1
+	t.current += 1
+}
+
+func (t *tokenSource) bearer() ReqBuilder {
+	return BearerToken(func() (string, error) {
+		return t.tokens[t.current], nil
+	})
+}
+
+// streamingBody hides the underlying reader's type so http.NewRequest
+// can't set up GetBody.
+type streamingBody struct{ r *strings.Reader }
+
+func (b *streamingBody) Read(p []byte) (int, error) { return b.r.Read(p) }
+func (b *streamingBody) Close() error               { return nil }
+
+func TestReAuthenticateReplaysOnce(t *testing.T) {
+	server := &authServer{validToken: "fresh"}
+	tokens := &tokenSource{tokens: []string{"revoked", "fresh"}}
+	send := ReAuthenticate(server.send, tokens.reset, tokens.bearer())
+
+	res, err := Request(
+		POST, tokens.bearer(), Body([]byte("data")),
+	).SetHandler(ExpectSuccess()).RunWith(context.TODO(), send)
+	if err != nil {
+		t.Fatalf("want: success; got: %v", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("want: 200; got: %d", res.StatusCode)
+	}
+	if tokens.resets != 1 {
+		t.Errorf("want: 1 reset; got: %d", tokens.resets)
+	}
+	if len(server.bodies) != 2 || server.bodies[1] != "data" {
+		t.Errorf("want: body sent twice; got: %v", server.bodies)
+	}
+}
+
+func TestReAuthenticateReplaysStreamingBody(t *testing.T) {
+	server := &authServer{validToken: "fresh"}
+	tokens := &tokenSource{tokens: []string{"revoked", "fresh"}}
+	send := ReAuthenticate(server.send, tokens.reset, tokens.bearer())
+
+	req, _ := http.NewRequest("POST", "http://x",
+		&streamingBody{strings.NewReader("stream")})
+	tokens.bearer()(req)
+
+	res, err := send(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("want: 200; got: %v, %v", res, err)
+	}
+	if len(server.bodies) != 2 || server.bodies[0] != "stream" ||
+		server.bodies[1] != "stream" {
+		t.Errorf("want: body sent twice; got: %v", server.bodies)
+	}
+}
+
+func TestReAuthenticateGivesUpAfterSecond401(t *testing.T) {
+	server := &authServer{validToken: "never"}
+	tokens := &tokenSource{tokens: []string{"t1", "t2", "t3"}}
+	send := ReAuthenticate(server.send, tokens.reset, tokens.bearer())
+
+	_, err := Request(GET, tokens.bearer()).
+		SetHandler(ExpectSuccess()).RunWith(context.TODO(), send)
+	if err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+	if len(server.received) != 2 {
+		t.Errorf("want: 2 requests; got: %d", len(server.received))
+	}
+}
+
+func TestReAuthenticateSkipsRequestsWithoutCredentials(t *testing.T) {
+	server := &authServer{validToken: "x"}
+	tokens := &tokenSource{tokens: []string{"x"}}
+	send := ReAuthenticate(server.send, tokens.reset, tokens.bearer())
+
+	res, _ := Request(GET).RunWith(context.TODO(), send)
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want: 401; got: %d", res.StatusCode)
+	}
+	if tokens.resets != 0 || len(server.received) != 1 {
+		t.Errorf("want: no replay; got: %d resets, %d requests",
+			tokens.resets, len(server.received))
+	}
+}
+
+func TestReAuthenticateNilSender(t *testing.T) {
+	if _, err := ReAuthenticate(nil, nil, nil)(&http.Request{}); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 
 	jsoniter "github.com/json-iterator/go"
@@ -46,12 +47,37 @@ func (r *jsonResReader) Handle(res *http.Response) error {
 //         Accept(MediaType.JSON),
 //     ).
 //     SetHandler(ExpectSuccess(), ReadJsonResponse(target)).
-//     RunWith(client.Do)
+//     RunWith(ctx, client.Do)
 //
 func ReadJsonResponse(target interface{}) ResHandler {
 	return &jsonResReader{deserialized: target}
 }
 
+type copyResponse struct {
+	sink io.Writer
+}
+
+func (r *copyResponse) Handle(res *http.Response) error {
+	if res == nil {
+		return fmt.Errorf("nil response")
+	}
+	if r.sink == nil {
+		return fmt.Errorf("nil sink")
+	}
+	if res.Body == nil {
+		return nil
+	}
+	_, err := io.Copy(r.sink, res.Body)
+	return err
+}
+
+// CopyResponse builds a ResHandler to stream the response body to the given
+// sink, e.g. to download a file without holding it in memory. It returns
+// any error that stopped it from copying the whole body.
+func CopyResponse(sink io.Writer) ResHandler {
+	return &copyResponse{sink: sink}
+}
+
 type expectSuccessfulResponse struct{}
 
 func (e expectSuccessfulResponse) Handle(res *http.Response) error {
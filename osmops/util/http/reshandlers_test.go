@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"strings"
@@ -45,7 +46,7 @@ func TestJsonReaderErrorOnUnexpectedResponseCode(t *testing.T) {
 	}
 	_, err := Request(GET).
 		SetHandler(ExpectSuccess(), ReadJsonResponse(&target)).
-		RunWith(send(response))
+		RunWith(context.TODO(), send(response))
 	if err == nil {
 		t.Errorf("want: error; got: nil")
 	}
@@ -58,7 +59,7 @@ func TestJsonReaderGetData(t *testing.T) {
 	}
 	res, err := Request(GET).
 		SetHandler(ReadJsonResponse(&target)).
-		RunWith(send(response))
+		RunWith(context.TODO(), send(response))
 
 	if err != nil {
 		t.Errorf("want: deserialized JSON; got: %v", err)
@@ -78,13 +79,53 @@ func TestJsonReaderGetData(t *testing.T) {
 	}
 }
 
+func TestCopyResponseErrorOnNilResponse(t *testing.T) {
+	if err := CopyResponse(&strings.Builder{}).Handle(nil); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestCopyResponseErrorOnNilSink(t *testing.T) {
+	if err := CopyResponse(nil).Handle(&http.Response{}); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestCopyResponseStreamBody(t *testing.T) {
+	sink := &strings.Builder{}
+	response := &http.Response{
+		StatusCode: 200,
+		Body:       stringReader("some binary data"),
+	}
+	_, err := Request(GET).
+		SetHandler(ExpectSuccess(), CopyResponse(sink)).
+		RunWith(context.TODO(), send(response))
+
+	if err != nil {
+		t.Errorf("want: copied body; got: %v", err)
+	}
+	if sink.String() != "some binary data" {
+		t.Errorf("want: some binary data; got: %s", sink.String())
+	}
+}
+
+func TestCopyResponseNoBody(t *testing.T) {
+	sink := &strings.Builder{}
+	if err := CopyResponse(sink).Handle(&http.Response{}); err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+	if sink.Len() != 0 {
+		t.Errorf("want: empty; got: %s", sink.String())
+	}
+}
+
 func TestExpectSuccess(t *testing.T) {
 	response := &http.Response{}
 	for code := 200; code < 300; code++ {
 		response.StatusCode = code
 		_, err := Request(GET).
 			SetHandler(ExpectSuccess()).
-			RunWith(send(response))
+			RunWith(context.TODO(), send(response))
 		if err != nil {
 			t.Errorf("want: success; got: %v", err)
 		}
@@ -93,7 +134,7 @@ func TestExpectSuccess(t *testing.T) {
 		response.StatusCode = code
 		_, err := Request(GET).
 			SetHandler(ExpectSuccess()).
-			RunWith(send(response))
+			RunWith(context.TODO(), send(response))
 		if err == nil {
 			t.Errorf("[%d] want: error; got: nil", code)
 		}
@@ -107,7 +148,7 @@ func TestExpectStatusCodeOneOf(t *testing.T) {
 		response.StatusCode = code
 		_, err := Request(GET).
 			SetHandler(ExpectStatusCodeOneOf(want...)).
-			RunWith(send(response))
+			RunWith(context.TODO(), send(response))
 		if err != nil {
 			t.Errorf("want: success; got: %v", err)
 		}
@@ -116,7 +157,7 @@ func TestExpectStatusCodeOneOf(t *testing.T) {
 		response.StatusCode = code
 		_, err := Request(GET).
 			SetHandler(ExpectStatusCodeOneOf(want...)).
-			RunWith(send(response))
+			RunWith(context.TODO(), send(response))
 		if err == nil {
 			t.Errorf("[%d] want: error; got: nil", code)
 		}
@@ -127,7 +168,7 @@ func TestExpectStatusCodeNone(t *testing.T) {
 	response := &http.Response{StatusCode: 200}
 	_, err := Request(GET).
 		SetHandler(ExpectStatusCodeOneOf()).
-		RunWith(send(response))
+		RunWith(context.TODO(), send(response))
 	if err == nil {
 		t.Errorf("want: error; got: nil")
 	}
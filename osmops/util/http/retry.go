@@ -0,0 +1,193 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/source-watcher/osmops/util"
+)
+
+// RetryPolicy tells when and how often to resend a request that failed
+// because of a network error or a server-side hiccup.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times to send a request,
+	// including the first attempt. A value less than 2 means no retries.
+	MaxAttempts int
+	// Methods are the HTTP methods of the requests that can be retried.
+	// Only list idempotent methods here, otherwise a retry could e.g.
+	// create the same resource twice.
+	Methods []string
+	// StatusCodes are the response codes that trigger a retry.
+	StatusCodes util.IntSet
+	// BaseDelay is how long to wait before the first retry. The delay
+	// doubles at each following retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts, including any delay
+	// the server asks for through the Retry-After header.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy retries idempotent requests up to three times, with
+// a delay starting at half a second and capped at 30 seconds, if there's
+// a network error or the server replies with a 429, 502, 503 or 504.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		Methods: []string{
+			http.MethodGet, http.MethodHead, http.MethodOptions,
+			http.MethodPut, http.MethodDelete,
+		},
+		StatusCodes: util.ToIntSet(
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		),
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  30 * time.Second,
+	}
+}
+
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, req.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) shouldRetry(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrCircuitOpen)
+	}
+	return res != nil && p.StatusCodes.Contains(res.StatusCode)
+}
+
+// backoff computes how long to wait before the given retry---1 for the
+// first retry, 2 for the second and so on. It uses exponential backoff
+// with jitter unless the response carries a Retry-After header.
+func (p RetryPolicy) backoff(retry int, res *http.Response) time.Duration {
+	if wait, ok := retryAfter(res); ok {
+		return p.capDelay(wait)
+	}
+
+	delay := p.BaseDelay
+	for k := 1; k < retry && delay < p.MaxDelay; k++ {
+		delay *= 2
+	}
+	delay = p.capDelay(delay)
+
+	half := int64(delay / 2) // (*)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+
+	// (*) jitter. Pick a random delay between half and all of the computed
+	// delay so clients that failed at the same time don't all come back
+	// at the same time.
+}
+
+func (p RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		return p.MaxDelay
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// retryAfter parses the Retry-After header, which can either be a number
+// of seconds or an HTTP date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	value := strings.TrimSpace(res.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when), true
+	}
+	return 0, false
+}
+
+// sleep waits for the given duration or until the context is done,
+// whichever comes first. It returns the context error in the latter case.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retry decorates the given ReqSender to resend requests according to the
+// given policy. Retry only resends requests whose method is in the policy's
+// list and only if the previous attempt failed with a network error or a
+// response code in the policy's list. It returns the outcome of the last
+// attempt.
+//
+// Between attempts, Retry waits as long as the server asked through the
+// Retry-After header or, if there's no such header, for an exponentially
+// growing, randomised delay. If the request context gets cancelled while
+// waiting, Retry gives up and returns the context error.
+//
+// Example.
+//
+//     send := Retry(client.Do, DefaultRetryPolicy())
+//     Request(
+//         GET, At(url),
+//     ).
+//     RunWith(ctx, send)
+//
+func Retry(send ReqSender, policy RetryPolicy) ReqSender {
+	return func(req *http.Request) (*http.Response, error) {
+		if send == nil {
+			return nil, errors.New("nil ReqSender")
+		}
+		if req == nil || policy.MaxAttempts < 2 || !policy.canRetry(req) {
+			return send(req)
+		}
+		if err := makeReplayable(req); err != nil {
+			return nil, err
+		}
+
+		attempt := req
+		for k := 1; ; k++ {
+			res, err := send(attempt)
+			if k >= policy.MaxAttempts || !policy.shouldRetry(res, err) {
+				return res, err
+			}
+
+			next, cloneErr := cloneRequest(req)
+			if cloneErr != nil {
+				return res, err
+			}
+			wait := policy.backoff(k, res)
+			if res != nil {
+				discard(res)
+			}
+			if sleepErr := sleep(req.Context(), wait); sleepErr != nil {
+				return nil, sleepErr
+			}
+			attempt = next
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type flakyServer struct {
+	replies  []int
+	received []string
+}
+
+func (s *flakyServer) send(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		data := make([]byte, 64)
+		n, _ := req.Body.Read(data)
+		body = string(data[:n])
+	}
+	s.received = append(s.received, body)
+
+	k := len(s.received) - 1
+	if k >= len(s.replies) {
+		k = len(s.replies) - 1
+	}
+	if s.replies[k] == 0 {
+		return nil, errors.New("connection reset by peer")
+	}
+	return &http.Response{
+		StatusCode: s.replies[k],
+		Header:     http.Header{},
+		Body:       stringReader(""),
+	}, nil
+}
+
+func recordSleeps(t *testing.T) *[]time.Duration {
+	waits := &[]time.Duration{}
+	original := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { sleep = original })
+	return waits
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	waits := recordSleeps(t)
+	server := &flakyServer{replies: []int{503, 0, 200}}
+	send := Retry(server.send, DefaultRetryPolicy())
+
+	res, err := Request(PUT, Body([]byte("data"))).RunWith(context.TODO(), send)
+	if err != nil || res.StatusCode != 200 {
+		t.Fatalf("want: 200; got: %v, %v", res, err)
+	}
+	if len(server.received) != 3 {
+		t.Fatalf("want: 3 attempts; got: %d", len(server.received))
+	}
+	for k, body := range server.received {
+		if body != "data" {
+			t.Errorf("[%d] want: data; got: %s", k, body)
+		}
+	}
+	if len(*waits) != 2 {
+		t.Errorf("want: 2 waits; got: %v", *waits)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	recordSleeps(t)
+	server := &flakyServer{replies: []int{502}}
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+
+	res, _ := Retry(server.send, policy)(&http.Request{Method: "GET"})
+	if res.StatusCode != 502 {
+		t.Errorf("want: 502; got: %d", res.StatusCode)
+	}
+	if len(server.received) != 3 {
+		t.Errorf("want: 3 attempts; got: %d", len(server.received))
+	}
+}
+
+func TestRetrySkipsNonIdempotentMethods(t *testing.T) {
+	recordSleeps(t)
+	server := &flakyServer{replies: []int{503, 200}}
+
+	res, _ := Request(POST).RunWith(context.TODO(), Retry(server.send, DefaultRetryPolicy()))
+	if res.StatusCode != 503 || len(server.received) != 1 {
+		t.Errorf("want: one 503; got: %d after %d attempts",
+			res.StatusCode, len(server.received))
+	}
+}
+
+func TestRetrySkipsOtherStatusCodes(t *testing.T) {
+	recordSleeps(t)
+	server := &flakyServer{replies: []int{500, 200}}
+
+	res, _ := Request(GET).RunWith(context.TODO(), Retry(server.send, DefaultRetryPolicy()))
+	if res.StatusCode != 500 || len(server.received) != 1 {
+		t.Errorf("want: one 500; got: %d after %d attempts",
+			res.StatusCode, len(server.received))
+	}
+}
+
+func TestRetryStopsOnCancelledContext(t *testing.T) {
+	recordSleeps(t)
+	server := &flakyServer{replies: []int{503, 200}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://x", nil)
+
+	if _, err := Retry(server.send, DefaultRetryPolicy())(req); err == nil {
+		t.Errorf("want: context error; got: nil")
+	}
+	if len(server.received) != 1 {
+		t.Errorf("want: 1 attempt; got: %d", len(server.received))
+	}
+}
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	fixtures := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{3, 2 * time.Second, 4 * time.Second},
+		{10, 2500 * time.Millisecond, 5 * time.Second},
+	}
+	for k, d := range fixtures {
+		got := policy.backoff(d.retry, nil)
+		if got < d.min || got > d.max {
+			t.Errorf("[%d] want: [%v, %v]; got: %v", k, d.min, d.max, got)
+		}
+	}
+}
+
+func TestBackoffHonoursRetryAfter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	res := &http.Response{Header: http.Header{}}
+
+	res.Header.Set("Retry-After", "7")
+	if got := policy.backoff(1, res); got != 7*time.Second {
+		t.Errorf("want: 7s; got: %v", got)
+	}
+
+	res.Header.Set("Retry-After", "120")
+	if got := policy.backoff(1, res); got != 10*time.Second {
+		t.Errorf("want: 10s; got: %v", got)
+	}
+
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	res.Header.Set("Retry-After", date)
+	if got := policy.backoff(1, res); got != 10*time.Second {
+		t.Errorf("want: 10s; got: %v", got)
+	}
+}
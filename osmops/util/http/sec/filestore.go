@@ -0,0 +1,125 @@
+package sec
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileTokenStore keeps the token in a file so it can outlive the process
+// that acquired it. It's safe for concurrent use.
+//
+// The file only ever holds one token in JSON format and gets written with
+// owner-only permissions since the token is a credential. Reads come from
+// an in-memory copy after the first load, so the file only gets touched
+// when the token changes.
+type FileTokenStore struct {
+	mutex  sync.Mutex
+	path   string
+	loaded bool
+	token  *Token
+}
+
+type tokenFileView struct {
+	Data      string `json:"token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// NewFileTokenStore creates a store to keep the token at the given path.
+// The file doesn't have to exist.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// Get retrieves the stored token, if any. Get returns nil if the token
+// file is missing or can't be read.
+func (s *FileTokenStore) Get() *Token {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.loaded {
+		s.token = readTokenFile(s.path)
+		s.loaded = true
+	}
+	return s.token
+}
+
+// Set stores the token, replacing the content of the token file. If the
+// file can't be written, the token still gets stored in memory.
+func (s *FileTokenStore) Set(t *Token) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.token, s.loaded = t, true
+	if t == nil {
+		os.Remove(s.path)
+		return
+	}
+	writeTokenFile(s.path, t) // (*)
+
+	// (*) not much we can do if this fails, except for keeping the token
+	// in memory which is what MemoryTokenStore does anyway.
+}
+
+// Clear removes the token from memory and deletes the token file.
+func (s *FileTokenStore) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.token, s.loaded = nil, true
+	os.Remove(s.path)
+}
+
+func readTokenFile(path string) *Token {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	view := tokenFileView{}
+	if err := json.Unmarshal(content, &view); err != nil {
+		return nil
+	}
+	return &Token{
+		expiresAt: time.Unix(view.ExpiresAt, 0),
+		data:      view.Data,
+	}
+}
+
+func writeTokenFile(path string, t *Token) error {
+	content, err := json.Marshal(tokenFileView{
+		Data:      t.data,
+		ExpiresAt: t.expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".*.tmp") // (1)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path) // (2)
+
+	// NOTE
+	// 1. Permissions. TempFile creates the file with 0600 permissions,
+	// which is what we want for a credential.
+	// 2. Atomic update. Rename is atomic on POSIX file systems, so another
+	// process reading the file sees either the old or the new token, never
+	// a half-written one.
+}
@@ -0,0 +1,96 @@
+package sec
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempTokenFile(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "token-store-test")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "sub", "token.json")
+}
+
+func TestFileTokenStoreEmptyIfNoFile(t *testing.T) {
+	store := NewFileTokenStore(tempTokenFile(t))
+	if got := store.Get(); got != nil {
+		t.Errorf("want: nil; got: %v", got)
+	}
+}
+
+func TestFileTokenStoreSurvivesNewInstance(t *testing.T) {
+	path := tempTokenFile(t)
+	token := NewToken("secret", secondsAfterNow(600))
+	NewFileTokenStore(path).Set(token)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("want: token file; got: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("want: 0600; got: %o", perm)
+	}
+
+	got := NewFileTokenStore(path).Get()
+	if got == nil {
+		t.Fatalf("want: token; got: nil")
+	}
+	if got.String() != token.String() ||
+		!got.expiresAt.Equal(token.expiresAt) {
+		t.Errorf("want: %+v; got: %+v", token, got)
+	}
+}
+
+func TestFileTokenStoreClear(t *testing.T) {
+	path := tempTokenFile(t)
+	store := NewFileTokenStore(path)
+	store.Set(NewToken("secret", secondsAfterNow(600)))
+	store.Clear()
+
+	if got := store.Get(); got != nil {
+		t.Errorf("want: nil; got: %v", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("want: no token file; got: %v", err)
+	}
+	if got := NewFileTokenStore(path).Get(); got != nil {
+		t.Errorf("want: nil; got: %v", got)
+	}
+}
+
+func TestFileTokenStoreIgnoresGarbage(t *testing.T) {
+	path := tempTokenFile(t)
+	os.MkdirAll(filepath.Dir(path), 0700)
+	ioutil.WriteFile(path, []byte("{not json"), 0600)
+
+	if got := NewFileTokenStore(path).Get(); got != nil {
+		t.Errorf("want: nil; got: %v", got)
+	}
+}
+
+func TestTokenManagerWithFileStore(t *testing.T) {
+	path := tempTokenFile(t)
+	provider := &fakeProvider{}
+
+	mngr, _ := NewTokenManager(provider.fetchNewValidToken,
+		NewFileTokenStore(path))
+	first, _ := mngr.GetAccessToken()
+
+	mngr, _ = NewTokenManager(provider.fetchNewValidToken,
+		NewFileTokenStore(path))
+	second, err := mngr.GetAccessToken()
+	if err != nil {
+		t.Fatalf("want: token; got: %v", err)
+	}
+	if second.String() != first.String() {
+		t.Errorf("want: %s; got: %s", first, second)
+	}
+	if provider.callCount != 1 {
+		t.Errorf("want: 1; got: %d", provider.callCount)
+	}
+}
@@ -4,9 +4,13 @@ package sec
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 )
 
 // TokenStore defines the how to store and retrieve token data between calls.
+// Implementations must be safe for concurrent use since TokenManager may
+// call them from different goroutines.
 type TokenStore interface {
 	// Get retrieves the the previously stored token if any. A nil return
 	// value means there's no token in the store.
@@ -17,37 +21,66 @@ type TokenStore interface {
 	Clear()
 }
 
-// MemoryTokenStore stores tokens in memory.
+// MemoryTokenStore stores tokens in memory. It's safe for concurrent use.
 type MemoryTokenStore struct {
+	mutex sync.RWMutex
 	token *Token
 }
 
-// NOTE. We can implement a file system store too if needed, but I don't
-// think it's going to be any useful at this stage.
+// NOTE. If tokens have to outlive the process, use FileTokenStore instead.
 
 func (s *MemoryTokenStore) Get() *Token {
-	if s.token == nil {
-		return nil
-	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 	return s.token
 }
 
 func (s *MemoryTokenStore) Set(t *Token) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.token = t
 }
 
 func (s *MemoryTokenStore) Clear() {
-	s.token = nil
+	s.Set(nil)
 }
 
 // TokenProvider acquires a fresh token from an auth endpoint, returning
 // an error if something goes wrong.
 type TokenProvider func() (*Token, error)
 
-// TokenManager manages the storage and lifecycle of tokens.
+const (
+	// expiryThreshold is the minimum number of seconds a token must still
+	// be valid for TokenManager to hand it out.
+	expiryThreshold = 30
+	// renewalThreshold is the number of seconds before expiry at which
+	// TokenManager starts renewing a token in the background.
+	renewalThreshold = 120
+	// renewalInterval is the minimum number of seconds between background
+	// renewals, so TokenManager doesn't try logging in on every call while
+	// the auth endpoint is down.
+	renewalInterval = 30
+)
+
+// refreshCall is a token refresh in flight.
+type refreshCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// TokenManager manages the storage and lifecycle of tokens. It's safe for
+// concurrent use.
 type TokenManager struct {
 	acquireToken TokenProvider
 	store        TokenStore
+	now          func() time.Time // (*)
+	mutex        sync.Mutex
+	inflight     *refreshCall
+	renewal      chan struct{}
+	lastRenewal  time.Time
+
+	// (*) added for testability, so we can fake the passing of time.
 }
 
 // NewTokenManager instantiates a TokenManager, returning an error if any of
@@ -63,6 +96,7 @@ func NewTokenManager(provider TokenProvider, store TokenStore) (
 	return &TokenManager{
 		acquireToken: provider,
 		store:        store,
+		now:          time.Now,
 	}, nil
 }
 
@@ -74,13 +108,109 @@ func NewTokenManager(provider TokenProvider, store TokenStore) (
 // provider can acquire a valid token, then the token gets stored in the
 // TokenStore before returning it. In all other cases, GetAccessToken returns
 // an error.
+//
+// Concurrent calls share the same refresh: only one goroutine at a time
+// calls the TokenProvider while the others wait for its outcome. Also, if
+// the stored token is still valid but about to expire (2 minutes or less
+// left), GetAccessToken returns it straight away but starts renewing it in
+// the background, so callers hardly ever have to wait for a refresh. It
+// starts at most one background renewal every 30 seconds, whether the
+// previous one failed or not.
 func (m *TokenManager) GetAccessToken() (*Token, error) {
-	currentToken := m.store.Get()
-	if currentToken != nil && currentToken.SecondsLeftBeforeExpiry() > 30 {
-		return currentToken, nil
+	current := m.store.Get()
+	if secondsLeft(current) > expiryThreshold {
+		if secondsLeft(current) <= renewalThreshold {
+			m.renewInBackground()
+		}
+		return current, nil
 	}
+	return m.refresh(expiryThreshold, true)
+}
 
+// ClearAccessToken discards the stored token, so the next GetAccessToken
+// call acquires a fresh one. Use it when the server rejects a token that
+// hasn't expired yet, e.g. because it got revoked.
+func (m *TokenManager) ClearAccessToken() {
 	m.store.Clear()
+}
+
+func secondsLeft(t *Token) uint64 {
+	if t == nil {
+		return 0
+	}
+	return t.SecondsLeftBeforeExpiry()
+}
+
+// Wait blocks until the last background renewal GetAccessToken started,
+// if any, is over.
+func (m *TokenManager) Wait() {
+	m.mutex.Lock()
+	renewal := m.renewal
+	m.mutex.Unlock()
+	if renewal != nil {
+		<-renewal
+	}
+}
+
+func (m *TokenManager) renewInBackground() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	if now.Sub(m.lastRenewal) < renewalInterval*time.Second {
+		return
+	}
+	m.lastRenewal = now
+
+	done := make(chan struct{})
+	m.renewal = done
+	go func() {
+		defer close(done)
+		m.refresh(renewalThreshold, false) // (*)
+	}()
+
+	// (*) ignore errors. The current token is still good and if renewal
+	// keeps on failing, GetAccessToken will eventually refresh the token
+	// in the foreground and return the error.
+}
+
+// refresh acquires a new token unless the stored one still has more than
+// minSecondsLeft before expiry---e.g. because another goroutine refreshed
+// it in the meantime. If a refresh is already in flight, refresh waits
+// for it to complete and returns its outcome instead of starting another
+// one. If clear is true, refresh removes the stored token before asking
+// the TokenProvider for a new one.
+func (m *TokenManager) refresh(minSecondsLeft uint64, clear bool) (
+	*Token, error) {
+	m.mutex.Lock()
+	if call := m.inflight; call != nil {
+		m.mutex.Unlock()
+		<-call.done
+		return call.token, call.err
+	}
+	call := &refreshCall{done: make(chan struct{})}
+	m.inflight = call
+	m.mutex.Unlock()
+
+	call.token, call.err = m.doRefresh(minSecondsLeft, clear)
+
+	m.mutex.Lock()
+	m.inflight = nil
+	m.mutex.Unlock()
+	close(call.done)
+
+	return call.token, call.err
+}
+
+func (m *TokenManager) doRefresh(minSecondsLeft uint64, clear bool) (
+	*Token, error) {
+	current := m.store.Get()
+	if secondsLeft(current) > minSecondsLeft {
+		return current, nil
+	}
+
+	if clear {
+		m.store.Clear()
+	}
 	if newToken, err := m.acquireToken(); err != nil {
 		return nil, err
 	} else {
@@ -3,6 +3,8 @@ package sec
 import (
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -128,3 +130,172 @@ func TestFetchError(t *testing.T) {
 		t.Errorf("want: error; got: %v", token)
 	}
 }
+
+func TestClearAccessTokenForcesRefresh(t *testing.T) {
+	provider := &fakeProvider{}
+	store := &MemoryTokenStore{
+		token: NewToken("revoked", secondsAfterNow(600)),
+	}
+	mngr, _ := NewTokenManager(provider.fetchNewValidToken, store)
+
+	mngr.ClearAccessToken()
+	token, err := mngr.GetAccessToken()
+	if err != nil {
+		t.Fatalf("want: token; got: %v", err)
+	}
+	if token != provider.lastToken || provider.callCount != 1 {
+		t.Errorf("want: %v; got: %v", provider.lastToken, token)
+	}
+}
+
+type slowProvider struct {
+	callCount int32
+	delay     time.Duration
+}
+
+func (p *slowProvider) fetch() (*Token, error) {
+	n := atomic.AddInt32(&p.callCount, 1)
+	time.Sleep(p.delay)
+	data := fmt.Sprintf("secret-%d", n)
+	return NewToken(data, secondsAfterNow(600)), nil
+}
+
+func (p *slowProvider) calls() int32 {
+	return atomic.LoadInt32(&p.callCount)
+}
+
+func getTokensConcurrently(mngr *TokenManager, howMany int) (
+	[]*Token, []error) {
+	tokens := make([]*Token, howMany)
+	errs := make([]error, howMany)
+	wg := sync.WaitGroup{}
+	for k := 0; k < howMany; k++ {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			tokens[k], errs[k] = mngr.GetAccessToken()
+		}(k)
+	}
+	wg.Wait()
+	return tokens, errs
+}
+
+func TestConcurrentCallsShareRefresh(t *testing.T) {
+	provider := &slowProvider{delay: 50 * time.Millisecond}
+	mngr, _ := NewTokenManager(provider.fetch, &MemoryTokenStore{})
+
+	tokens, errs := getTokensConcurrently(mngr, 50)
+	for k := range tokens {
+		if errs[k] != nil {
+			t.Fatalf("[%d] want: token; got: %v", k, errs[k])
+		}
+		if tokens[k].String() != "secret-1" {
+			t.Errorf("[%d] want: secret-1; got: %s", k, tokens[k])
+		}
+	}
+	if provider.calls() != 1 {
+		t.Errorf("want: 1; got: %d", provider.calls())
+	}
+}
+
+func TestConcurrentCallsShareRefreshError(t *testing.T) {
+	calls := int32(0)
+	provider := func() (*Token, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return nil, errors.New("ouch!")
+	}
+	mngr, _ := NewTokenManager(provider, &MemoryTokenStore{})
+
+	_, errs := getTokensConcurrently(mngr, 20)
+	for k, err := range errs {
+		if err == nil {
+			t.Errorf("[%d] want: error; got: nil", k)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got >= 20 {
+		t.Errorf("want: shared refresh calls; got: %d", got)
+	}
+}
+
+func TestRenewTokenInBackgroundBeforeThreshold(t *testing.T) {
+	provider := &slowProvider{delay: 10 * time.Millisecond}
+	current := NewToken("current", secondsAfterNow(60))
+	store := &MemoryTokenStore{token: current}
+	mngr, _ := NewTokenManager(provider.fetch, store)
+
+	tokens, errs := getTokensConcurrently(mngr, 20)
+	for k := range tokens {
+		if errs[k] != nil {
+			t.Fatalf("[%d] want: token; got: %v", k, errs[k])
+		}
+		if tokens[k] != current && tokens[k].String() != "secret-1" {
+			t.Errorf("[%d] want: current or renewed; got: %s", k, tokens[k])
+		}
+	}
+
+	mngr.Wait()
+	if got := store.Get(); got == nil || got.String() != "secret-1" {
+		t.Errorf("want: renewed token; got: %v", got)
+	}
+	if provider.calls() != 1 {
+		t.Errorf("want: 1; got: %d", provider.calls())
+	}
+}
+
+func TestConcurrentStoreAndClear(t *testing.T) {
+	provider := &slowProvider{}
+	mngr, _ := NewTokenManager(provider.fetch, &MemoryTokenStore{})
+
+	wg := sync.WaitGroup{}
+	for k := 0; k < 20; k++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := mngr.GetAccessToken(); err != nil {
+				t.Errorf("want: token; got: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			mngr.ClearAccessToken()
+		}()
+	}
+	wg.Wait()
+	mngr.Wait()
+}
+
+func TestRenewTokenInBackgroundAtMostOncePerInterval(t *testing.T) {
+	calls := int32(0)
+	provider := func() (*Token, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("auth endpoint down")
+	}
+	current := NewToken("current", secondsAfterNow(60))
+	mngr, _ := NewTokenManager(provider, &MemoryTokenStore{token: current})
+	now := time.Now()
+	mngr.now = func() time.Time { return now }
+
+	for k := 0; k < 10; k++ {
+		if token, err := mngr.GetAccessToken(); err != nil || token != current {
+			t.Fatalf("[%d] want: current token; got: %v, %v", k, token, err)
+		}
+		mngr.Wait()
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("want: 1 renewal; got: %d", got)
+	}
+
+	now = now.Add(renewalInterval * time.Second)
+	mngr.GetAccessToken()
+	mngr.Wait()
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("want: 2 renewals; got: %d", got)
+	}
+}
+
+func TestWaitWithoutRenewal(t *testing.T) {
+	mngr, _ := NewTokenManager((&fakeProvider{}).fetchNewValidToken,
+		&MemoryTokenStore{})
+	mngr.Wait()
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Redacted replaces any secret Trace would otherwise log.
+const Redacted = "[REDACTED]"
+
+// TraceOptions configures what Trace logs.
+type TraceOptions struct {
+	// Logger is where to log exchanges whose request context doesn't carry
+	// a logger. If the context has a logger (see logr.NewContext), Trace
+	// uses that instead, so the trace shows up along with any key-value
+	// pairs the logger carries. Nil means don't log those exchanges.
+	Logger logr.Logger
+	// MaxBodySize is the maximum number of body bytes to log. Trace logs
+	// the first MaxBodySize bytes of request and response bodies and
+	// leaves out the rest. Zero means don't log bodies. Trace never logs
+	// binary bodies, e.g. a gzipped package, but only their content type
+	// and size. (See: textBody)
+	MaxBodySize int
+	// RedactHeaders lists the headers whose value Trace replaces with
+	// Redacted. Trace always redacts the Authorization header.
+	RedactHeaders []string
+	// RedactJsonFields lists the names of the JSON fields whose value
+	// Trace replaces with Redacted in request and response bodies.
+	RedactJsonFields []string
+	// SecretBodies tells if the given request or its response carries a
+	// secret in its body Trace can't redact by field, e.g. a token; Trace
+	// won't log any of those bodies. Nil means no such requests.
+	SecretBodies func(*http.Request) bool
+}
+
+// DefaultTraceOptions logs up to 1KiB of each body and redacts the
+// Authorization header as well as any "password" JSON field.
+func DefaultTraceOptions() TraceOptions {
+	return TraceOptions{
+		MaxBodySize:      1024,
+		RedactJsonFields: []string{"password"},
+	}
+}
+
+type tracer struct {
+	opts          TraceOptions
+	redactHeaders map[string]bool
+	redactFields  *regexp.Regexp
+}
+
+func newTracer(opts TraceOptions) *tracer {
+	t := &tracer{
+		opts: opts,
+		redactHeaders: map[string]bool{
+			http.CanonicalHeaderKey("Authorization"): true,
+		},
+	}
+	for _, h := range opts.RedactHeaders {
+		t.redactHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	fields := []string{}
+	for _, f := range opts.RedactJsonFields {
+		fields = append(fields, regexp.QuoteMeta(f))
+	}
+	if len(fields) > 0 {
+		t.redactFields = regexp.MustCompile( // (*)
+			`("(?:` + strings.Join(fields, "|") + `)"\s*:\s*)` +
+				`("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return t
+
+	// (*) redaction regex. We match field values in the raw text rather than
+	// parsing the body as JSON since the body we log may be truncated. The
+	// value is either a string, possibly cut off before the closing quote,
+	// or a literal like a number.
+}
+
+func (t *tracer) logger(req *http.Request) logr.Logger {
+	if log := logr.FromContext(req.Context()); log != nil {
+		return log
+	}
+	return t.opts.Logger
+}
+
+func (t *tracer) headers(h http.Header) map[string]string {
+	out := map[string]string{}
+	for name, values := range h {
+		if t.redactHeaders[http.CanonicalHeaderKey(name)] {
+			out[name] = Redacted
+		} else {
+			out[name] = strings.Join(values, ", ")
+		}
+	}
+	return out
+}
+
+func (t *tracer) redact(req *http.Request, body []byte, truncated bool) string {
+	if t.opts.SecretBodies != nil && t.opts.SecretBodies(req) {
+		return Redacted
+	}
+	text := string(body)
+	if t.redactFields != nil {
+		text = t.redactFields.ReplaceAllString(text, `${1}"`+Redacted+`"`)
+	}
+	if truncated {
+		text += "..."
+	}
+	return text
+}
+
+func (t *tracer) requestBody(req *http.Request) string {
+	if t.opts.MaxBodySize <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "" && !textBody(contentType) {
+		return bodySummary(contentType, req.ContentLength)
+	}
+	if req.GetBody == nil {
+		return "[streamed]" // (1)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(
+		io.LimitReader(body, int64(t.opts.MaxBodySize)+1))
+	if sniffed := http.DetectContentType(data); contentType == "" &&
+		!textBody(sniffed) { // (2)
+		return bodySummary(sniffed, req.ContentLength)
+	}
+	prefix, truncated := cutPrefix(data, t.opts.MaxBodySize)
+	return t.redact(req, prefix, truncated)
+
+	// NOTE.
+	// 1. We can't peek at the body without consuming it.
+	// 2. No content type. Sniff it from the first few bytes, so we don't
+	// log binary data just b/c the sender didn't say what it is.
+}
+
+func (t *tracer) responseBody(req *http.Request, res *http.Response) string {
+	if t.opts.MaxBodySize <= 0 || res.Body == nil || res.Body == http.NoBody {
+		return ""
+	}
+	contentType := res.Header.Get("Content-Type")
+	if contentType != "" && !textBody(contentType) {
+		return bodySummary(contentType, res.ContentLength) // (1)
+	}
+	consumed, _ := ioutil.ReadAll(
+		io.LimitReader(res.Body, int64(t.opts.MaxBodySize)+1))
+	res.Body = &prefixedBody{ // (2)
+		Reader: io.MultiReader(bytes.NewReader(consumed), res.Body),
+		closer: res.Body,
+	}
+	if sniffed := http.DetectContentType(consumed); contentType == "" &&
+		!textBody(sniffed) {
+		return bodySummary(sniffed, res.ContentLength)
+	}
+	prefix, truncated := cutPrefix(consumed, t.opts.MaxBodySize)
+	return t.redact(req, prefix, truncated)
+
+	// NOTE.
+	// 1. Leave the body alone. There's no point in reading binary data we
+	// won't log.
+	// 2. Put back what we've read so the response handlers see the whole
+	// body.
+}
+
+// textBody tells if the given content type is text we can log, i.e.
+// "text/*" or JSON, YAML, XML and form data.
+func textBody(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"json", "yaml", "xml", "x-www-form-urlencoded"} {
+		if strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodySummary stands in for a binary body in the trace, e.g.
+// "[application/gzip, 1024 bytes]". Size is negative if unknown.
+func bodySummary(contentType string, size int64) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	if size < 0 {
+		return fmt.Sprintf("[%s]", mediaType)
+	}
+	return fmt.Sprintf("[%s, %d bytes]", mediaType, size)
+}
+
+// cutPrefix returns the first max bytes of data and tells if there's more.
+func cutPrefix(data []byte, max int) ([]byte, bool) {
+	if len(data) > max {
+		return data[:max], true
+	}
+	return data, false
+}
+
+type prefixedBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (b *prefixedBody) Close() error {
+	return b.closer.Close()
+}
+
+// Trace decorates the given ReqSender to log each exchange: request method,
+// URL, headers and body; then response status, headers and body, or the
+// send error, along with how long the exchange took. Trace redacts secrets
+// according to the given options, only logs the beginning of large bodies
+// and summarises binary ones as content type and size. To log a request body, Trace needs the request's GetBody function
+// (see Body) so as not to consume the body before sending it.
+//
+// Example.
+//
+//     opts := DefaultTraceOptions()
+//     opts.Logger = log.V(1)
+//     send := Trace(client.Do, opts)
+//     Request(...).RunWith(ctx, send)
+//
+func Trace(send ReqSender, opts TraceOptions) ReqSender {
+	t := newTracer(opts)
+	return func(req *http.Request) (*http.Response, error) {
+		if send == nil {
+			return nil, errors.New("nil ReqSender")
+		}
+		if req == nil {
+			return send(req)
+		}
+		log := t.logger(req)
+		if log == nil || !log.Enabled() {
+			return send(req)
+		}
+
+		url := ""
+		if req.URL != nil {
+			url = req.URL.String()
+		}
+		log.Info("HTTP request",
+			"method", req.Method,
+			"url", url,
+			"headers", sortedKeyValues(t.headers(req.Header)),
+			"body", t.requestBody(req))
+
+		started := time.Now()
+		res, err := send(req)
+		elapsed := time.Since(started)
+
+		if err != nil {
+			log.Error(err, "HTTP request failed",
+				"method", req.Method,
+				"url", url,
+				"duration", elapsed.String())
+			return res, err
+		}
+		if res == nil {
+			return res, err
+		}
+		log.Info("HTTP response",
+			"method", req.Method,
+			"url", url,
+			"status", res.StatusCode,
+			"duration", elapsed.String(),
+			"headers", sortedKeyValues(t.headers(res.Header)),
+			"body", t.responseBody(req, res))
+		return res, err
+	}
+}
+
+func sortedKeyValues(m map[string]string) []string {
+	out := []string{}
+	for k, v := range m {
+		out = append(out, fmt.Sprintf("%s: %s", k, v))
+	}
+	sort.Strings(out)
+	return out
+}
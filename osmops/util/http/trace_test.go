@@ -0,0 +1,252 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+type traceEntry struct {
+	msg string
+	err error
+	kvs map[string]interface{}
+}
+
+type traceLogger struct {
+	entries []traceEntry
+}
+
+func (l *traceLogger) add(err error, msg string, kvs ...interface{}) {
+	e := traceEntry{msg: msg, err: err, kvs: map[string]interface{}{}}
+	for k := 0; k < len(kvs)-1; k += 2 {
+		e.kvs[kvs[k].(string)] = kvs[k+1]
+	}
+	l.entries = append(l.entries, e)
+}
+
+func (l *traceLogger) Enabled() bool { return true }
+func (l *traceLogger) Info(msg string, kvs ...interface{}) {
+	l.add(nil, msg, kvs...)
+}
+func (l *traceLogger) Error(err error, msg string, kvs ...interface{}) {
+	l.add(err, msg, kvs...)
+}
+func (l *traceLogger) V(level int) logr.Logger                   { return l }
+func (l *traceLogger) WithValues(kvs ...interface{}) logr.Logger { return l }
+func (l *traceLogger) WithName(name string) logr.Logger          { return l }
+
+func (l *traceLogger) joined(ix int, key string) string {
+	switch v := l.entries[ix].kvs[key].(type) {
+	case []string:
+		return strings.Join(v, "\n")
+	case string:
+		return v
+	}
+	return ""
+}
+
+func echoSender(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body: stringReader(
+			`{"id": "tok3n", "password": "p4ss", "name": "ldap"}`),
+	}, nil
+}
+
+func tracedRequest(t *testing.T, ctx context.Context, opts TraceOptions,
+	send ReqSender) string {
+	u, _ := url.Parse("http://osm/tokens")
+	data := ""
+	res, err := Request(
+		POST, At(u),
+		Authorization("Bearer s3cret"),
+		Body([]byte(`{"username": "admin", "password": "pa\"ss"}`)),
+	).
+		SetHandler(ReadJsonResponse(&map[string]interface{}{})).
+		RunWith(ctx, send)
+	if err != nil {
+		t.Fatalf("want: response; got: %v", err)
+	}
+	if res != nil && res.Body != nil {
+		raw, _ := ioutil.ReadAll(res.Body)
+		data = string(raw)
+	}
+	return data
+}
+
+func TestTraceLogsAndRedacts(t *testing.T) {
+	logger := &traceLogger{}
+	opts := DefaultTraceOptions()
+	opts.Logger = logger
+	opts.RedactJsonFields = append(opts.RedactJsonFields, "id")
+
+	tracedRequest(t, context.TODO(), opts, Trace(echoSender, opts))
+
+	if len(logger.entries) != 2 {
+		t.Fatalf("want: 2 entries; got: %d", len(logger.entries))
+	}
+	all := logger.joined(0, "headers") + logger.joined(0, "body") +
+		logger.joined(1, "body")
+	for _, secret := range []string{"s3cret", `pa\"ss`, "p4ss", "tok3n"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("want: %s redacted; got: %s", secret, all)
+		}
+	}
+	if !strings.Contains(logger.joined(0, "body"), `"username": "admin"`) {
+		t.Errorf("want: username logged; got: %s", logger.joined(0, "body"))
+	}
+	if !strings.Contains(logger.joined(1, "body"), `"name": "ldap"`) {
+		t.Errorf("want: name logged; got: %s", logger.joined(1, "body"))
+	}
+	if logger.entries[1].kvs["status"] != 200 {
+		t.Errorf("want: status 200; got: %v", logger.entries[1].kvs["status"])
+	}
+	if _, ok := logger.entries[1].kvs["duration"]; !ok {
+		t.Errorf("want: duration; got: none")
+	}
+}
+
+func TestTraceUsesContextLogger(t *testing.T) {
+	fallback, fromCtx := &traceLogger{}, &traceLogger{}
+	opts := DefaultTraceOptions()
+	opts.Logger = fallback
+	ctx := logr.NewContext(context.Background(), fromCtx)
+
+	tracedRequest(t, ctx, opts, Trace(echoSender, opts))
+
+	if len(fallback.entries) != 0 || len(fromCtx.entries) != 2 {
+		t.Errorf("want: ctx logger; got: %d fallback, %d ctx entries",
+			len(fallback.entries), len(fromCtx.entries))
+	}
+}
+
+func TestTraceTruncatesBodiesButHandlersSeeAll(t *testing.T) {
+	logger := &traceLogger{}
+	opts := TraceOptions{Logger: logger, MaxBodySize: 4}
+	var handled map[string]interface{}
+	send := Trace(echoSender, opts)
+
+	_, err := Request(GET).
+		SetHandler(ReadJsonResponse(&handled)).
+		RunWith(context.TODO(), send)
+	if err != nil {
+		t.Fatalf("want: response; got: %v", err)
+	}
+	if got := logger.joined(1, "body"); got != `{"id...` {
+		t.Errorf("want: truncated body; got: %s", got)
+	}
+	if handled["name"] != "ldap" {
+		t.Errorf("want: whole body; got: %v", handled)
+	}
+}
+
+func TestTraceSecretBodies(t *testing.T) {
+	logger := &traceLogger{}
+	opts := DefaultTraceOptions()
+	opts.Logger = logger
+	opts.SecretBodies = func(req *http.Request) bool {
+		return strings.HasSuffix(req.URL.Path, "/tokens")
+	}
+
+	tracedRequest(t, context.TODO(), opts, Trace(echoSender, opts))
+
+	for k := range logger.entries {
+		if got := logger.joined(k, "body"); got != Redacted {
+			t.Errorf("[%d] want: redacted body; got: %s", k, got)
+		}
+	}
+}
+
+func TestTraceLogsSendError(t *testing.T) {
+	logger := &traceLogger{}
+	send := func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("ouch!")
+	}
+	opts := TraceOptions{Logger: logger}
+
+	if _, err := Request(GET).RunWith(context.TODO(), Trace(send, opts)); err == nil {
+		t.Fatalf("want: error; got: nil")
+	}
+	if len(logger.entries) != 2 || logger.entries[1].err == nil {
+		t.Errorf("want: error entry; got: %+v", logger.entries)
+	}
+}
+
+func TestTraceWithoutLogger(t *testing.T) {
+	res, err := Trace(echoSender, TraceOptions{})(&http.Request{})
+	if err != nil || res.StatusCode != 200 {
+		t.Errorf("want: response; got: %v, %v", res, err)
+	}
+}
+
+func TestTraceSummarisesBinaryBodies(t *testing.T) {
+	logger := &traceLogger{}
+	opts := DefaultTraceOptions()
+	opts.Logger = logger
+	gzipped := []byte{0x1f, 0x8b, 0x08, 0, 0, 0, 0, 0, 0, 0xff}
+	send := func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    200,
+			Header:        http.Header{"Content-Type": {"application/zip"}},
+			ContentLength: 3,
+			Body:          stringReader("PK\x03"),
+		}, nil
+	}
+
+	res, err := Request(
+		POST,
+		Content(MediaType.GZIP),
+		StreamBody(int64(len(gzipped)), func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(gzipped)), nil
+		}),
+	).RunWith(context.TODO(), Trace(send, opts))
+	if err != nil {
+		t.Fatalf("want: response; got: %v", err)
+	}
+	if want, got := "[application/gzip, 10 bytes]",
+		logger.joined(0, "body"); want != got {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+	if want, got := "[application/zip, 3 bytes]",
+		logger.joined(1, "body"); want != got {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+	if data, _ := ioutil.ReadAll(res.Body); string(data) != "PK\x03" {
+		t.Errorf("want: whole body; got: %q", data)
+	}
+}
+
+func TestTraceSniffsBodiesWithoutContentType(t *testing.T) {
+	logger := &traceLogger{}
+	opts := DefaultTraceOptions()
+	opts.Logger = logger
+	gzipped := []byte{0x1f, 0x8b, 0x08, 0, 0, 0, 0, 0, 0, 0xff}
+	send := func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    200,
+			ContentLength: -1,
+			Body:          ioutil.NopCloser(bytes.NewReader(gzipped)),
+		}, nil
+	}
+
+	_, err := Request(POST, Body([]byte(`{"name": "ldap"}`))).
+		RunWith(context.TODO(), Trace(send, opts))
+	if err != nil {
+		t.Fatalf("want: response; got: %v", err)
+	}
+	if want, got := `{"name": "ldap"}`, logger.joined(0, "body"); want != got {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+	if want, got := "[application/x-gzip]", logger.joined(1, "body"); want != got {
+		t.Errorf("want: %s; got: %s", want, got)
+	}
+}
@@ -0,0 +1,64 @@
+package tgz
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+)
+
+// Format enumerates the archive formats Writer and Reader support: gzipped
+// tar, plain tar and zip. TGZ is the default since that's the format OSM
+// packages come in, but some vendors ship SOL004 packages as zip files.
+var Format = struct {
+	u.StrEnum
+	TGZ, TAR, ZIP u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("tgz", "tar", "zip"),
+	TGZ:     0,
+	TAR:     1,
+	ZIP:     2,
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+	zipEmpty  = []byte("PK\x05\x06")
+)
+
+// DetectFormat figures out the format of the archive starting with the
+// given bytes. It looks at the gzip and zip magic numbers, so a handful
+// of bytes will do. Anything else is assumed to be a plain tar.
+func DetectFormat(prefix []byte) u.EnumIx {
+	if bytes.HasPrefix(prefix, gzipMagic) {
+		return Format.TGZ
+	}
+	if bytes.HasPrefix(prefix, zipMagic) || bytes.HasPrefix(prefix, zipEmpty) {
+		return Format.ZIP
+	}
+	return Format.TAR
+}
+
+// NewDetectingReader creates a Reader to process the entries of the archive
+// in the given stream, figuring out the archive format from the first few
+// bytes in the stream. (See: DetectFormat)
+func NewDetectingReader(source io.ReadCloser) (Reader, error) {
+	if source == nil {
+		return NewFormatReader(Format.TGZ, source)
+	}
+	buffered := bufio.NewReader(source)
+	prefix, _ := buffered.Peek(len(zipMagic)) // (*)
+	return NewFormatReader(DetectFormat(prefix), &peekedSource{
+		Reader: buffered,
+		Closer: source,
+	})
+
+	// (*) Peek returns what it could read along with any error, e.g. EOF
+	// for an empty stream. The Reader will get the same error anyway.
+}
+
+type peekedSource struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,150 @@
+package tgz
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+	"github.com/fluxcd/source-watcher/osmops/util/bytez"
+	"github.com/fluxcd/source-watcher/osmops/util/file"
+)
+
+func writeFormatArchive(t *testing.T, format u.EnumIx) *bytez.Buffer {
+	buf := bytez.NewBuffer()
+	if es := writeArchive(buf, WithFormat(format)); len(es) > 0 {
+		t.Fatalf("couldn't write %s archive: %v", Format.LabelOf(format), es)
+	}
+	return buf
+}
+
+func checkReaderEntries(t *testing.T, reader Reader) {
+	paths := []string{}
+	err := reader.IterateEntries(
+		func(archivePath string, fi os.FileInfo, entry io.Reader) error {
+			paths = append(paths, archivePath)
+			return checkEntryContent(archivePath, fi, entry)
+		})
+	if err != nil {
+		t.Errorf("entry content should be the same as entry name: %v", err)
+	}
+	checkArchivePaths(t, paths)
+}
+
+func TestWriteThenReadEachFormat(t *testing.T) {
+	for _, format := range []u.EnumIx{Format.TGZ, Format.TAR, Format.ZIP} {
+		buf := writeFormatArchive(t, format)
+		reader, err := NewFormatReader(format, buf)
+		if err != nil {
+			t.Fatalf("[%s] want: reader; got: %v", Format.LabelOf(format), err)
+		}
+		checkReaderEntries(t, reader)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for _, format := range []u.EnumIx{Format.TGZ, Format.TAR, Format.ZIP} {
+		buf := writeFormatArchive(t, format)
+		if got := DetectFormat(buf.Bytes()); got != format {
+			t.Errorf("want: %s; got: %s", Format.LabelOf(format),
+				Format.LabelOf(got))
+		}
+
+		reader, err := NewDetectingReader(buf)
+		if err != nil {
+			t.Fatalf("[%s] want: reader; got: %v", Format.LabelOf(format), err)
+		}
+		checkReaderEntries(t, reader)
+	}
+}
+
+func TestDetectFormatOfShortPrefix(t *testing.T) {
+	if got := DetectFormat([]byte{}); got != Format.TAR {
+		t.Errorf("want: tar; got: %s", Format.LabelOf(got))
+	}
+	if got := DetectFormat([]byte("P")); got != Format.TAR {
+		t.Errorf("want: tar; got: %s", Format.LabelOf(got))
+	}
+}
+
+func TestNewFormatReaderErrOnUnknownFormat(t *testing.T) {
+	if _, err := NewFormatReader(u.EnumIx(9), bytez.NewBuffer()); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestNewWriterErrOnUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("", bytez.NewBuffer(), WithFormat(9)); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestNewFormatReaderErrOnNonZipSource(t *testing.T) {
+	buf := bytez.NewBuffer()
+	buf.Write([]byte("not a zip"))
+	if _, err := NewFormatReader(Format.ZIP, buf); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestZipThenExtractArchive(t *testing.T) {
+	withTempDir(t, func(tempDirPath string) {
+		sourceDir := findTestDataDir()
+		archivePath, _ := file.ParseAbsPath(path.Join(tempDirPath, "test.zip"))
+		extractedDir := path.Join(tempDirPath, ArchiveTestDirName)
+
+		sink, err := os.Create(archivePath.Value())
+		if err != nil {
+			t.Fatalf("want: archive file; got: %v", err)
+		}
+		writer, _ := NewWriter(ArchiveTestDirName, sink, WithFormat(Format.ZIP))
+		scanner := file.NewTreeScanner(sourceDir)
+		if es := scanner.Visit(writer.Visitor()); len(es) > 0 {
+			t.Fatalf("want: archive; got: %v", es)
+		}
+		writer.Close()
+
+		if err := ExtractArchive(archivePath, tempDirPath); err != nil {
+			t.Fatalf("want: extract; got: %v", err)
+		}
+		checkExtractedPaths(t, sourceDir, extractedDir)
+		checkExtractedFiles(t, extractedDir)
+	})
+}
+
+func TestZipClampsEntryTimesToZipEpoch(t *testing.T) {
+	for k, when := range []time.Time{
+		time.Unix(0, 0), time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC),
+	} {
+		buf := bytez.NewBuffer()
+		es := writeArchive(buf, WithFormat(Format.ZIP),
+			WithReproducibleEntries(when))
+		if len(es) > 0 {
+			t.Fatalf("[%d] couldn't write zip archive: %v", k, es)
+		}
+		data := buf.Bytes()
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("[%d] want: zip; got: %v", k, err)
+		}
+
+		want := when.UTC()
+		if want.Before(zipEpoch) {
+			want = zipEpoch
+		}
+		for _, entry := range archive.File {
+			if got := entry.Modified.UTC(); !got.Equal(want) {
+				t.Errorf("[%d] %s: want: %v; got: %v", k, entry.Name, want, got)
+			}
+			dosYear := 1980 + int(entry.ModifiedDate>>9)
+			if dosYear != want.Year() {
+				t.Errorf("[%d] %s: want DOS year: %d; got: %d",
+					k, entry.Name, want.Year(), dosYear)
+			}
+		}
+	}
+}
@@ -6,17 +6,19 @@ import (
 	"fmt"
 	"io"
 	"os"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
 )
 
-// EntryReader processes an entry in a tar archive.
+// EntryReader processes an entry in an archive.
 // The entry is at archivePath and has an associated file metadata whereas
 // the content should only be read if the entry is a regular file.
 type EntryReader func(
 	archivePath string, fi os.FileInfo, content io.Reader) error
 
-// Reader calls an EntryReader on each entry in a tar archive.
+// Reader calls an EntryReader on each entry in an archive.
 type Reader interface {
-	// IterateEntries calls process on each tar entry.
+	// IterateEntries calls process on each archive entry.
 	// Regardless of errors, IterateEntries closes the archive stream,
 	// making the Reader unusable.
 	IterateEntries(process EntryReader) error
@@ -25,37 +27,72 @@ type Reader interface {
 	Close()
 }
 
+// entryIterator steps through the entries of an archive in a given format.
+// next returns io.EOF after the last entry.
+type entryIterator interface {
+	next() (archivePath string, fi os.FileInfo, content io.Reader, err error)
+}
+
 type rdr struct {
-	source        io.ReadCloser
-	deflateStream *gzip.Reader
-	archive       *tar.Reader
-	closed        bool
+	entries entryIterator
+	closers []io.Closer
+	closed  bool
 }
 
 // NewReader creates a Reader to process entries contained in the given
 // gzip-compressed tar archive.
 func NewReader(source io.ReadCloser) (Reader, error) {
+	return NewFormatReader(Format.TGZ, source)
+}
+
+// NewFormatReader creates a Reader to process entries contained in the
+// given archive, which must be in the specified Format. Notice the zip
+// format keeps its directory at the end of the archive, so the Reader has
+// to load the whole archive in memory before it can process any entry.
+// The Reader owns source from here on, so if NewFormatReader fails, it
+// closes source before returning the error.
+func NewFormatReader(format u.EnumIx, source io.ReadCloser) (Reader, error) {
 	if source == nil {
 		return nil, fmt.Errorf("nil source")
 	}
-	deflateStream, err := gzip.NewReader(source)
-	if err != nil {
-		return nil, err
+	switch format {
+	case Format.TGZ:
+		deflateStream, err := gzip.NewReader(source)
+		if err != nil {
+			source.Close()
+			return nil, err
+		}
+		return &rdr{
+			entries: &tarEntries{archive: tar.NewReader(deflateStream)},
+			closers: []io.Closer{source, deflateStream},
+		}, nil
+	case Format.TAR:
+		return &rdr{
+			entries: &tarEntries{archive: tar.NewReader(source)},
+			closers: []io.Closer{source},
+		}, nil
+	case Format.ZIP:
+		entries, err := newZipEntries(source)
+		if err != nil {
+			source.Close()
+			return nil, err
+		}
+		return &rdr{
+			entries: entries,
+			closers: []io.Closer{source, entries},
+		}, nil
 	}
-	return &rdr{
-		source:        source,
-		deflateStream: deflateStream,
-		archive:       tar.NewReader(deflateStream),
-		closed:        false,
-	}, nil
+	source.Close()
+	return nil, fmt.Errorf("unsupported archive format: %v", format)
 }
 
 func (r *rdr) Close() {
 	if r.closed {
 		return
 	}
-	r.source.Close()
-	r.deflateStream.Close()
+	for _, c := range r.closers {
+		c.Close()
+	}
 	r.closed = true
 }
 
@@ -74,16 +111,28 @@ func (r *rdr) IterateEntries(process EntryReader) error {
 
 func (r *rdr) forEachEntry(process EntryReader) error {
 	for {
-		header, err := r.archive.Next()
+		archivePath, fi, content, err := r.entries.next()
 		if err == io.EOF {
 			return nil
 		}
 		if err != nil {
 			return err
 		}
-		err = process(header.Name, header.FileInfo(), r.archive)
+		err = process(archivePath, fi, content)
 		if err != nil {
 			return err
 		}
 	}
 }
+
+type tarEntries struct {
+	archive *tar.Reader
+}
+
+func (t *tarEntries) next() (string, os.FileInfo, io.Reader, error) {
+	header, err := t.archive.Next()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return header.Name, header.FileInfo(), t.archive, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"testing"
 
+	u "github.com/fluxcd/source-watcher/osmops/util"
 	"github.com/fluxcd/source-watcher/osmops/util/bytez"
 )
 
@@ -25,6 +26,28 @@ func TestNewReaderErrOnNonGzipSource(t *testing.T) {
 	}
 }
 
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewFormatReaderClosesSourceOnErr(t *testing.T) {
+	for k, format := range []u.EnumIx{Format.TGZ, Format.ZIP, u.EnumIx(9)} {
+		src := &closeTracker{Reader: strings.NewReader("not an archive")}
+		if got, err := NewFormatReader(format, src); err == nil {
+			t.Errorf("[%d] want error; got: %v", k, got)
+		}
+		if !src.closed {
+			t.Errorf("[%d] want: source closed; got: still open", k)
+		}
+	}
+}
+
 func TestIterateEntriesErrOnNilProcess(t *testing.T) {
 	reader := writeArchiveAndCreateReader(t)
 	if err := reader.IterateEntries(nil); err == nil {
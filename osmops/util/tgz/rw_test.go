@@ -14,10 +14,10 @@ import (
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
-func writeArchive(sink io.WriteCloser) []error {
+func writeArchive(sink io.WriteCloser, opts ...WriterOption) []error {
 	errs := []error{}
 
-	writer, err := NewWriter("", sink, WithBestSpeed())
+	writer, err := NewWriter("", sink, append(opts, WithBestSpeed())...)
 	if err != nil {
 		errs = append(errs, err)
 		return errs
@@ -31,12 +31,24 @@ import (
 // the path of file f in the archive, ExtractTarball will try creating a
 // directory "destDirPath/d" if it doesn't exist and then put f in there.
 func ExtractTarball(tarballPath file.AbsPath, destDirPath string) error {
-	source, err := os.Open(tarballPath.Value())
+	return extract(tarballPath, destDirPath, NewReader)
+}
+
+// ExtractArchive works like ExtractTarball but the archive can be in any
+// of the supported formats, which ExtractArchive figures out from the
+// archive content. (See: DetectFormat)
+func ExtractArchive(archivePath file.AbsPath, destDirPath string) error {
+	return extract(archivePath, destDirPath, NewDetectingReader)
+}
+
+func extract(archivePath file.AbsPath, destDirPath string,
+	newReader func(io.ReadCloser) (Reader, error)) error {
+	source, err := os.Open(archivePath.Value())
 	if err != nil {
 		return err
 	}
 
-	reader, err := NewReader(source)
+	reader, err := newReader(source)
 	if err != nil {
 		return err
 	}
@@ -10,10 +10,10 @@ import (
 	"github.com/fluxcd/source-watcher/osmops/util/file"
 )
 
-// Writer writes data to a compressed tar stream.
-// The tar format is PAX and the compression is gzip.
-// You create a Writer with a sink stream where the compressed tar data
-// gets written.
+// Writer writes data to an archive stream.
+// By default, the archive is a PAX tar compressed with gzip, but you can
+// also have a plain tar or a zip. (See: WithFormat) You create a Writer
+// with a sink stream where the archive data gets written.
 //
 // Example. Archiving all the files in "some/dir" and its sub-directories.
 //
@@ -51,11 +51,21 @@ type Writer interface {
 	Close()
 }
 
-type tarball struct {
-	contentStream    *tar.Writer
-	compressedStream *gzip.Writer
-	sink             io.WriteCloser
-	setHeaderFields  tarHeaderSetter
+// entryWriter writes entries to an archive stream in a given format.
+// Whatever the format, the Writer describes each entry with a tar header,
+// so the same WriterOptions work for all formats. The entryWriter maps
+// the header fields to its own format as best it can.
+type entryWriter interface {
+	// createEntry writes the entry header to the archive and returns the
+	// stream to write the entry content to.
+	createEntry(hdr *tar.Header) (io.Writer, error)
+	close()
+}
+
+type archive struct {
+	entries         entryWriter
+	sink            io.WriteCloser
+	setHeaderFields tarHeaderSetter
 }
 
 func NewWriter(archiveBaseDirName string, sink io.WriteCloser,
@@ -65,34 +75,51 @@ func NewWriter(archiveBaseDirName string, sink io.WriteCloser,
 	}
 
 	cfg := makeWriterCfg(archiveBaseDirName, opts...)
-	gzipStream, err := gzip.NewWriterLevel(sink, cfg.compressionLevel)
+	entries, err := newEntryWriter(cfg, sink)
 	if err != nil {
 		return nil, err
 	}
-	tarStream := tar.NewWriter(gzipStream)
 
-	return &tarball{
-		contentStream:    tarStream,
-		compressedStream: gzipStream,
-		sink:             sink,
-		setHeaderFields:  cfg.setHeaderFields,
+	return &archive{
+		entries:         entries,
+		sink:            sink,
+		setHeaderFields: cfg.setHeaderFields,
 	}, nil
 }
 
-func (t *tarball) Close() {
-	t.contentStream.Close()
-	t.compressedStream.Close()
+func newEntryWriter(cfg *writerOpts, sink io.Writer) (entryWriter, error) {
+	switch cfg.format {
+	case Format.TGZ:
+		gzipStream, err := gzip.NewWriterLevel(sink, cfg.compressionLevel)
+		if err != nil {
+			return nil, err
+		}
+		return &tarEntryWriter{
+			contentStream:    tar.NewWriter(gzipStream),
+			compressedStream: gzipStream,
+		}, nil
+	case Format.TAR:
+		return &tarEntryWriter{contentStream: tar.NewWriter(sink)}, nil
+	case Format.ZIP:
+		return newZipEntryWriter(sink, cfg.compressionLevel)
+	}
+	return nil, fmt.Errorf("unsupported archive format: %v", cfg.format)
+}
+
+func (t *archive) Close() {
+	t.entries.close()
 	t.sink.Close()
 }
 
-func (t *tarball) writeHeader(archivePath string, hdr *tar.Header) error {
+func (t *archive) writeHeader(archivePath string, hdr *tar.Header) (
+	io.Writer, error) {
 	if err := t.setHeaderFields(archivePath, hdr); err != nil {
-		return err
+		return nil, err
 	}
-	return t.contentStream.WriteHeader(hdr)
+	return t.entries.createEntry(hdr)
 }
 
-func (t *tarball) AddEntry(archivePath string, content io.Reader) error {
+func (t *archive) AddEntry(archivePath string, content io.Reader) error {
 	contentBytes, err := io.ReadAll(content) // (*) see note below
 	if err != nil {
 		return err
@@ -102,11 +129,12 @@ func (t *tarball) AddEntry(archivePath string, content io.Reader) error {
 		Mode: int64(0644),
 		Size: int64(len(contentBytes)), // (*) see note below
 	}
-	if err := t.writeHeader(archivePath, header); err != nil {
+	contentStream, err := t.writeHeader(archivePath, header)
+	if err != nil {
 		return err
 	}
 
-	_, err = t.contentStream.Write(contentBytes)
+	_, err = contentStream.Write(contentBytes)
 	return err
 
 	// NOTE. Sucking all content into memory. It sucks. But I don't think
@@ -116,9 +144,7 @@ func (t *tarball) AddEntry(archivePath string, content io.Reader) error {
 	// you've got no way to tell beforehand how much data you can read.
 }
 
-func (t *tarball) AddFile(archivePath, filePath string, fi os.FileInfo) error {
-	var err error
-
+func (t *archive) AddFile(archivePath, filePath string, fi os.FileInfo) error {
 	if fi == nil || !fi.Mode().IsRegular() {
 		return nil
 	}
@@ -127,7 +153,7 @@ func (t *tarball) AddFile(archivePath, filePath string, fi os.FileInfo) error {
 	if err != nil {
 		return err
 	}
-	err = t.writeHeader(archivePath, header)
+	contentStream, err := t.writeHeader(archivePath, header)
 	if err != nil {
 		return err
 	}
@@ -136,14 +162,33 @@ func (t *tarball) AddFile(archivePath, filePath string, fi os.FileInfo) error {
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(t.contentStream, fd)
+	_, err = io.Copy(contentStream, fd)
 	fd.Close()
 
 	return err
 }
 
-func (t *tarball) Visitor() file.Visitor {
+func (t *archive) Visitor() file.Visitor {
 	return func(node file.TreeNode) error {
 		return t.AddFile(node.RelPath, node.NodePath.Value(), node.FsMeta)
 	}
 }
+
+type tarEntryWriter struct {
+	contentStream    *tar.Writer
+	compressedStream *gzip.Writer // nil for plain tar
+}
+
+func (t *tarEntryWriter) createEntry(hdr *tar.Header) (io.Writer, error) {
+	if err := t.contentStream.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return t.contentStream, nil
+}
+
+func (t *tarEntryWriter) close() {
+	t.contentStream.Close()
+	if t.compressedStream != nil {
+		t.compressedStream.Close()
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"path"
 	"time"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
 )
 
 // tarHeaderSetter is a function to tweak tar headers the Writer puts in
@@ -16,6 +18,7 @@ type tarHeaderSetter func(archivePath string, hdr *tar.Header) error
 
 type writerOpts struct {
 	baseDirName      string
+	format           u.EnumIx
 	compressionLevel int
 	setHeaderFields  tarHeaderSetter
 }
@@ -35,6 +38,7 @@ type WriterOption func(opts *writerOpts)
 func baseWriterOpts(baseDirName string) *writerOpts {
 	return &writerOpts{
 		baseDirName:      baseDirName,
+		format:           Format.TGZ,
 		compressionLevel: gzip.BestCompression,
 		setHeaderFields: func(archivePath string, hdr *tar.Header) error {
 			hdr.Name = path.Join(baseDirName, archivePath)
@@ -55,6 +59,15 @@ func makeWriterCfg(baseDirName string, opts ...WriterOption) *writerOpts {
 	return cfg
 }
 
+// Write the archive in the given Format rather than as a gzipped tar.
+// Compression options only apply to compressed formats, i.e. gzipped tar
+// and zip, since zip deflates each entry with the same algorithm as gzip.
+func WithFormat(format u.EnumIx) WriterOption {
+	return func(opts *writerOpts) {
+		opts.format = format
+	}
+}
+
 // Use gzip's default compression level when writing the archive.
 func WithDefaultCompression() WriterOption {
 	return func(opts *writerOpts) {
@@ -88,3 +101,56 @@ func WithEntryTime(when time.Time) WriterOption {
 		})
 	}
 }
+
+// Make each tar header owned by root, i.e. set uid and gid to 0 and clear
+// user and group names, regardless of who owns the file on the host.
+func WithNormalizedOwner() WriterOption {
+	return func(opts *writerOpts) {
+		opts.chainHdrSetter(func(archivePath string, hdr *tar.Header) error {
+			hdr.Uid, hdr.Gid = 0, 0
+			hdr.Uname, hdr.Gname = "", ""
+			return nil
+		})
+	}
+}
+
+// Set the permissions of each tar header to 0755 if the owner can execute
+// the file, 0644 otherwise, regardless of the file's mode bits on the host.
+// This way umask and checkout settings don't leak into the archive.
+func WithNormalizedMode() WriterOption {
+	return func(opts *writerOpts) {
+		opts.chainHdrSetter(func(archivePath string, hdr *tar.Header) error {
+			if hdr.Mode&0100 != 0 {
+				hdr.Mode = 0755
+			} else {
+				hdr.Mode = 0644
+			}
+			return nil
+		})
+	}
+}
+
+// Make the archive reproducible: the same files always produce the same
+// bytes, no matter when, where or by whom the archive gets written. This
+// option sets the mod time of each tar header to the given time point,
+// truncated to the second, normalises owner and permissions, and drops
+// any host-specific metadata like access/change time and PAX records.
+// Notice the Writer doesn't sort entries, so you've got to add them in
+// a stable order---a TreeScanner visits files in lexical order, so the
+// Visitor is fine.
+func WithReproducibleEntries(when time.Time) WriterOption {
+	modTime := when.Truncate(time.Second)
+	normalizeOwner, normalizeMode := WithNormalizedOwner(), WithNormalizedMode()
+	return func(opts *writerOpts) {
+		opts.chainHdrSetter(func(archivePath string, hdr *tar.Header) error {
+			hdr.ModTime = modTime
+			hdr.AccessTime = time.Time{}
+			hdr.ChangeTime = time.Time{}
+			hdr.PAXRecords = nil
+			hdr.Devmajor, hdr.Devminor = 0, 0
+			return nil
+		})
+		normalizeOwner(opts)
+		normalizeMode(opts)
+	}
+}
@@ -116,3 +116,60 @@ func TestChainSettersBailOutOnFirstErr(t *testing.T) {
 			"want: name setter not called b/c of previous setter err; got: called")
 	}
 }
+
+func TestNormalizedOwnerOpt(t *testing.T) {
+	cfg := makeWriterCfg("baseDir", WithNormalizedOwner())
+	hdr := &tar.Header{Uid: 1000, Gid: 1000, Uname: "andrea", Gname: "staff"}
+	cfg.setHeaderFields("some/file", hdr)
+
+	if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+		t.Errorf("want: root owner; got: %+v", hdr)
+	}
+}
+
+func TestNormalizedModeOpt(t *testing.T) {
+	cfg := makeWriterCfg("baseDir", WithNormalizedMode())
+	for k, d := range []struct {
+		mode int64
+		want int64
+	}{
+		{0600, 0644}, {0664, 0644}, {0444, 0644}, {0700, 0755}, {0775, 0755},
+		{0744, 0755}, {0611, 0644},
+	} {
+		hdr := &tar.Header{Mode: d.mode}
+		cfg.setHeaderFields("some/file", hdr)
+		if hdr.Mode != d.want {
+			t.Errorf("[%d] want: %o; got: %o", k, d.want, hdr.Mode)
+		}
+	}
+}
+
+func TestReproducibleEntriesOpt(t *testing.T) {
+	when := time.Date(2021, 9, 1, 12, 0, 0, 500, time.UTC)
+	cfg := makeWriterCfg("baseDir", WithReproducibleEntries(when))
+	hdr := &tar.Header{
+		Mode:       0600,
+		Uid:        1000,
+		Uname:      "andrea",
+		ModTime:    time.Now(),
+		AccessTime: time.Now(),
+		ChangeTime: time.Now(),
+		PAXRecords: map[string]string{"SCHILY.xattr.user.x": "y"},
+	}
+	checkBaseHdrFields(t, cfg)
+	cfg.setHeaderFields("some/file", hdr)
+
+	if !hdr.ModTime.Equal(when.Truncate(time.Second)) {
+		t.Errorf("want mod time: %v; got: %v", when, hdr.ModTime)
+	}
+	if !hdr.AccessTime.IsZero() || !hdr.ChangeTime.IsZero() {
+		t.Errorf("want: no access/change time; got: %v, %v",
+			hdr.AccessTime, hdr.ChangeTime)
+	}
+	if hdr.PAXRecords != nil {
+		t.Errorf("want: no PAX records; got: %v", hdr.PAXRecords)
+	}
+	if hdr.Mode != 0644 || hdr.Uid != 0 || hdr.Uname != "" {
+		t.Errorf("want: normalized mode and owner; got: %+v", hdr)
+	}
+}
@@ -0,0 +1,106 @@
+package tgz
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// zipEpoch is the earliest mod time zip can store. The DOS date fields
+// start at 1980, so Go would wrap earlier times, e.g. the Unix epoch
+// becomes 2098-01-01.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type zipEntryWriter struct {
+	archive *zip.Writer
+}
+
+func newZipEntryWriter(sink io.Writer, compressionLevel int) (
+	entryWriter, error) {
+	if _, err := flate.NewWriter(io.Discard, compressionLevel); err != nil {
+		return nil, err // (*)
+	}
+	archive := zip.NewWriter(sink)
+	archive.RegisterCompressor(zip.Deflate,
+		func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, compressionLevel)
+		})
+	return &zipEntryWriter{archive: archive}, nil
+
+	// (*) fail early on a bogus level, same as gzip.NewWriterLevel does.
+}
+
+func (z *zipEntryWriter) createEntry(hdr *tar.Header) (io.Writer, error) {
+	if hdr.Size < 0 {
+		return nil, fmt.Errorf("archive/zip: negative size: %d", hdr.Size)
+	}
+	zipHdr, err := zip.FileInfoHeader(hdr.FileInfo())
+	if err != nil {
+		return nil, err
+	}
+	zipHdr.Name = hdr.Name
+	zipHdr.Method = zip.Deflate // (1)
+	if zipHdr.Modified.Before(zipEpoch) { // (2)
+		zipHdr.Modified = zipEpoch
+	}
+
+	return z.archive.CreateHeader(zipHdr)
+
+	// NOTE.
+	// 1. FileInfoHeader picks the name, size, mode and mod time out of the
+	// tar header but leaves the entry uncompressed. Zip has no access or
+	// change times nor owners, so those tar header fields just get dropped.
+	// 2. Typically the Unix epoch from WithReproducibleEntries.
+}
+
+func (z *zipEntryWriter) close() {
+	z.archive.Close()
+}
+
+type zipEntries struct {
+	files   []*zip.File
+	current io.ReadCloser
+}
+
+func newZipEntries(source io.Reader) (*zipEntries, error) {
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipEntries{files: archive.File}, nil
+}
+
+func (z *zipEntries) next() (string, os.FileInfo, io.Reader, error) {
+	z.Close()
+	if len(z.files) == 0 {
+		return "", nil, nil, io.EOF
+	}
+	entry := z.files[0]
+	z.files = z.files[1:]
+
+	content, err := entry.Open()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("%s: %w", entry.Name, err)
+	}
+	z.current = content
+	return entry.Name, entry.FileInfo(), content, nil
+}
+
+// Close releases the content stream of the current entry, if any.
+func (z *zipEntries) Close() error {
+	if z.current == nil {
+		return nil
+	}
+	err := z.current.Close()
+	z.current = nil
+	return err
+}
@@ -0,0 +1,138 @@
+// Distributed tracing with OpenTelemetry.
+// OsmOps records spans for the work it does when reconciling a source---
+// fetching the artifact, packing OSM packages, calling NBI---and propagates
+// the trace context to NBI in W3C Trace Context headers. Spans go to the
+// global tracer provider, which does nothing until you call Setup.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	u "github.com/fluxcd/source-watcher/osmops/util"
+)
+
+// TracerName identifies the OsmOps instrumentation library.
+const TracerName = "github.com/fluxcd/source-watcher/osmops"
+
+// ServiceName is the name OsmOps reports to the tracing backend.
+const ServiceName = "osmops"
+
+// NOTE. LCM operations. OsmOps doesn't wait for the NS LCM operations NBI
+// starts to complete, so there are no polling spans yet. When we add that,
+// polling should run in a span child of the operation that kicked it off.
+
+// Span attribute keys.
+const (
+	SourceKindKey      = attribute.Key("osmops.source.kind")
+	SourceNamespaceKey = attribute.Key("osmops.source.namespace")
+	SourceNameKey      = attribute.Key("osmops.source.name")
+	RevisionKey        = attribute.Key("osmops.revision")
+	PackageNameKey     = attribute.Key("osmops.package.name")
+	GitOpsFileKey      = attribute.Key("osmops.file")
+	NsNameKey          = attribute.Key("osmops.ns.name")
+	NbiEndpointKey     = attribute.Key("osmops.nbi.endpoint")
+)
+
+// Exporter enumerates the supported span exporters.
+var Exporter = struct {
+	u.StrEnum
+	NONE, STDOUT, OTLP u.EnumIx
+}{
+	StrEnum: u.NewStrEnum("none", "stdout", "otlp"),
+	NONE:    0,
+	STDOUT:  1,
+	OTLP:    2,
+}
+
+// Shutdown flushes any buffered spans and releases the exporter.
+type Shutdown func(ctx context.Context) error
+
+func noShutdown(context.Context) error { return nil }
+
+func newExporter(ctx context.Context, exporter u.EnumIx, endpoint string) (
+	sdktrace.SpanExporter, error) {
+	switch exporter {
+	case Exporter.STDOUT:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case Exporter.OTLP:
+		opts := []otlptracehttp.Option{}
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint),
+				otlptracehttp.WithInsecure())
+		} // (*)
+		return otlptracehttp.New(ctx, opts...)
+	}
+	return nil, fmt.Errorf("unsupported exporter index: %d", exporter)
+
+	// (*) w/o an explicit endpoint, the exporter reads the standard OTEL
+	// environment variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT, and falls
+	// back to https://localhost:4318.
+}
+
+// Setup configures the global tracer provider to send spans to the named
+// exporter---one of Exporter's labels---and makes W3C Trace Context the
+// global propagator. The endpoint is the host:port of the OTLP collector;
+// it's ignored for the other exporters. If the exporter is "none", Setup
+// leaves the tracer provider alone, so spans are no-ops. Call the returned
+// Shutdown on exit to flush any spans still in the pipeline.
+func Setup(ctx context.Context, exporter string, endpoint string) (
+	Shutdown, error) {
+	if err := Exporter.Validate(exporter); err != nil {
+		return noShutdown, err
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ix := Exporter.IndexOf(exporter)
+	if ix == Exporter.NONE {
+		return noShutdown, nil
+	}
+	exp, err := newExporter(ctx, ix, endpoint)
+	if err != nil {
+		return noShutdown, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the OsmOps tracer from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Start starts a span with the given name and attributes as a child of
+// the span in ctx, if any. It returns the span along with a context
+// holding it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (
+	context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err in span, if err isn't nil, and then ends span.
+// It returns err so you can end a span and return in one go.
+func End(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
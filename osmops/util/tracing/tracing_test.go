@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(
+		sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+}
+
+func TestSetupRejectUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.TODO(), "jaeger", ""); err == nil {
+		t.Errorf("want: error; got: nil")
+	}
+}
+
+func TestSetupNoExporter(t *testing.T) {
+	shutdown, err := Setup(context.TODO(), "none", "")
+	if err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+	if err := shutdown(context.TODO()); err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+	if fields := otel.GetTextMapPropagator().Fields(); len(fields) == 0 {
+		t.Errorf("want: trace context propagator; got: no fields")
+	}
+}
+
+func TestSetupStdoutExporter(t *testing.T) {
+	shutdown, err := Setup(context.TODO(), "STDOUT", "")
+	if err != nil {
+		t.Fatalf("want: nil; got: %v", err)
+	}
+	if err := shutdown(context.TODO()); err != nil {
+		t.Errorf("want: nil; got: %v", err)
+	}
+}
+
+func TestStartSpanWithAttributes(t *testing.T) {
+	recorder := recordSpans()
+
+	ctx, parent := Start(context.TODO(), "parent")
+	_, child := Start(ctx, "child", NsNameKey.String("ldap"))
+	End(child, nil)
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("want: 2 spans; got: %d", len(spans))
+	}
+	got := spans[0]
+	if got.Name() != "child" {
+		t.Errorf("want: child; got: %s", got.Name())
+	}
+	if got.Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Errorf("want: child of parent; got: %v", got.Parent())
+	}
+	attrs := got.Attributes()
+	if len(attrs) != 1 || attrs[0] != NsNameKey.String("ldap") {
+		t.Errorf("want: ns name attribute; got: %v", attrs)
+	}
+	if got.Status().Code != codes.Unset {
+		t.Errorf("want: unset status; got: %v", got.Status())
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := recordSpans()
+
+	boom := errors.New("boom")
+	_, span := Start(context.TODO(), "op")
+	if got := End(span, boom); got != boom {
+		t.Errorf("want: %v; got: %v", boom, got)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("want: 1 span; got: %d", len(spans))
+	}
+	status := spans[0].Status()
+	if status.Code != codes.Error || status.Description != "boom" {
+		t.Errorf("want: error status; got: %v", status)
+	}
+	if len(spans[0].Events()) != 1 {
+		t.Errorf("want: error event; got: %v", spans[0].Events())
+	}
+}